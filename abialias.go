@@ -0,0 +1,22 @@
+package goloader
+
+// maxABIAliasDepth bounds the chain of aliases followed by resolveABIAlias.
+const maxABIAliasDepth = 8
+
+// resolveABIAlias returns the function implementing an ABI alias symbol,
+// or name if it is not an alias. An ABI alias (go1.12-1.16) is an empty
+// symbol with a single relocation to the implementation of the other ABI,
+// a call through it must go straight to the implementation, both in the
+// module and in the host, where functions are registered by name. Both
+// ABIs of these versions pass arguments on the stack, the register ABI of
+// go1.17 is not supported.
+func (linker *Linker) resolveABIAlias(name string) string {
+	for i := 0; i < maxABIAliasDepth; i++ {
+		objsym, ok := linker.objsymbolMap[name]
+		if !ok || objsym.Kind != SABIALIAS || len(objsym.Reloc) != 1 {
+			return name
+		}
+		name = objsym.Reloc[0].Sym.Name
+	}
+	return name
+}
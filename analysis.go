@@ -0,0 +1,158 @@
+package goloader
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// categories of DefaultAnalysisRules
+const (
+	CategorySyscall         = "syscall"
+	CategoryExec            = "exec"
+	CategoryUnsafeReflect   = "unsafe-reflect"
+	CategoryRuntimeInternal = "runtime-internal"
+)
+
+// AnalysisRule flags the references to the symbols of the host matching
+// Patterns, patterns as in SymbolPolicy, as Category.
+type AnalysisRule struct {
+	Category string
+	Patterns []string
+}
+
+// DefaultAnalysisRules flag the sensitive areas of the host: system calls,
+// starting processes, reflection writing through pointers and the runtime
+// state a loader, not a plugin, needs. Calls the compiler emits, e.g.
+// runtime.newobject, are not flagged.
+var DefaultAnalysisRules = []AnalysisRule{
+	{CategorySyscall, []string{"syscall", "golang.org/x/sys/unix", "golang.org/x/sys/windows", "internal/syscall/*"}},
+	{CategoryExec, []string{"os/exec", "os.StartProcess", "syscall.Exec", "syscall.ForkExec", "syscall.StartProcess", "plugin"}},
+	{CategoryUnsafeReflect, []string{"reflect.NewAt", "reflect.Value.UnsafeAddr", "reflect.Value.UnsafePointer",
+		"reflect.Value.Pointer", "reflect.Value.SetPointer", "reflect.Value.InterfaceData"}},
+	{CategoryRuntimeInternal, []string{"runtime.firstmoduledata", "runtime.lastmoduledatap", "runtime.modulesinit",
+		"runtime.moduledataverify1", "runtime.allgs", "runtime.allm", "runtime.sched", "runtime.findfunc",
+		"runtime.mheap_", "runtime.stopTheWorld", "runtime.startTheWorld", "runtime.sysAlloc", "runtime.sysFree", "runtime/internal/*", "github.com/pkujhd/goloader"}},
+}
+
+// Finding is a symbol of the host the objects refer to flagged by a rule,
+// Callers are the symbols of the objects referring to it.
+type Finding struct {
+	Category string
+	Symbol   string
+	Callers  []string
+}
+
+// AnalysisReport lists the findings of Analyze sorted by category and symbol.
+type AnalysisReport struct {
+	Findings []Finding
+}
+
+// Analyze flags the references of the objects of linker to the symbols of
+// the host matching rules, DefaultAnalysisRules if rules is nil. A symbol
+// matching several rules is reported once for each.
+func Analyze(linker *Linker, rules []AnalysisRule) *AnalysisReport {
+	if rules == nil {
+		rules = DefaultAnalysisRules
+	}
+	callers := make(map[string][]string)
+	for name, objsym := range linker.objsymbolMap {
+		for _, loc := range objsym.Reloc {
+			if _, ok := linker.objsymbolMap[loc.Sym.Name]; !ok {
+				callers[loc.Sym.Name] = append(callers[loc.Sym.Name], name)
+			}
+		}
+	}
+	report := &AnalysisReport{}
+	for _, target := range linker.references() {
+		for _, rule := range rules {
+			if matchAny(rule.Patterns, target) {
+				report.Findings = append(report.Findings, Finding{Category: rule.Category, Symbol: target, Callers: uniqueSorted(callers[target])})
+			}
+		}
+	}
+	sort.SliceStable(report.Findings, func(i, j int) bool {
+		return report.Findings[i].Category < report.Findings[j].Category
+	})
+	return report
+}
+
+func uniqueSorted(names []string) []string {
+	sort.Strings(names)
+	unique := names[:0]
+	for i, name := range names {
+		if i == 0 || name != names[i-1] {
+			unique = append(unique, name)
+		}
+	}
+	return unique
+}
+
+// Categories returns the sorted categories with findings.
+func (report *AnalysisReport) Categories() []string {
+	categories := make([]string, 0)
+	for i, finding := range report.Findings {
+		if i == 0 || finding.Category != report.Findings[i-1].Category {
+			categories = append(categories, finding.Category)
+		}
+	}
+	return categories
+}
+
+// Has reports whether the report has findings of one of categories, of any
+// category if none is given.
+func (report *AnalysisReport) Has(categories ...string) bool {
+	for _, finding := range report.Findings {
+		if len(categories) == 0 || matchCategory(categories, finding.Category) {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes a finding by line, "category symbol <- callers".
+func (report *AnalysisReport) Print(w io.Writer) error {
+	for _, finding := range report.Findings {
+		if _, err := fmt.Fprintf(w, "%s %s <- %s\n", finding.Category, finding.Symbol, strings.Join(finding.Callers, ", ")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AnalysisVerifier rejects the linkers with findings of Deny, of any category
+// if Deny is empty, by Rules, DefaultAnalysisRules if nil. Report, if not
+// nil, receives the report of every linker verified, e.g. to queue a plugin
+// for review instead.
+type AnalysisVerifier struct {
+	Rules  []AnalysisRule
+	Deny   []string
+	Report func(report *AnalysisReport)
+}
+
+func (v *AnalysisVerifier) Verify(linker *Linker) error {
+	report := Analyze(linker, v.Rules)
+	if v.Report != nil {
+		v.Report(report)
+	}
+	if report.Has(v.Deny...) {
+		denied := make([]string, 0)
+		for _, finding := range report.Findings {
+			if len(v.Deny) == 0 || matchCategory(v.Deny, finding.Category) {
+				denied = append(denied, finding.Category+" "+finding.Symbol)
+			}
+		}
+		return fmt.Errorf("analysis flagged %s", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+func matchCategory(categories []string, category string) bool {
+	for _, c := range categories {
+		if c == category {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,247 @@
+package goloader
+
+import (
+	"fmt"
+	"io"
+	"reflect"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// APIDoc describes the exported functions, variables and types of the
+// packages of a set of objects, read from their symbols and type metadata
+// without loading them, e.g. to review what a plugin exposes before it is
+// deployed.
+type APIDoc struct {
+	Packages []PkgDoc
+}
+
+// PkgDoc is the exported API of one package, sorted by name.
+type PkgDoc struct {
+	Path  string
+	Funcs []FuncDoc
+	Vars  []VarDoc
+	Types []TypeDoc
+}
+
+// FuncDoc is an exported function, Signature is its parameters and results,
+// e.g. "(int) error", or "" if the object does not record its type.
+type FuncDoc struct {
+	Name      string
+	Signature string
+}
+
+// VarDoc is an exported package-level variable, Type is "" if the object
+// does not record its type.
+type VarDoc struct {
+	Name string
+	Type string
+}
+
+// TypeDoc is an exported named type with the exported methods of its
+// method set and of the method set of its pointer type.
+type TypeDoc struct {
+	Name    string
+	Kind    reflect.Kind
+	Methods []MethodDoc
+}
+
+// MethodDoc is a method of a named type, the signature is reconstructed
+// from the type.func symbol of the method table of the type.
+type MethodDoc struct {
+	Name        string
+	Signature   string
+	PtrReceiver bool
+}
+
+// mask of the kind in the kind byte of a _type
+const typeKindMask = (1 << 5) - 1
+
+// Describe returns the exported API of the objects read by the linker.
+func (linker *Linker) Describe() *APIDoc {
+	return DescribeSymbols(linker.objsymbolMap)
+}
+
+// DescribeSymbols returns the exported API of the packages defining syms,
+// e.g. the Syms of a Pkg returned by ParseObj.
+func DescribeSymbols(syms map[string]*ObjSymbol) *APIDoc {
+	pkgs := make(map[string]*PkgDoc)
+	pkgDoc := func(path string) *PkgDoc {
+		if _, ok := pkgs[path]; !ok {
+			pkgs[path] = &PkgDoc{Path: path}
+		}
+		return pkgs[path]
+	}
+	types := make(map[string]*TypeDoc)
+	for name, objsym := range syms {
+		pkg, ident := splitExported(name)
+		if pkg == EmptyString {
+			continue
+		}
+		switch objsym.Kind {
+		case STEXT:
+			pkgDoc(pkg).Funcs = append(pkgDoc(pkg).Funcs,
+				FuncDoc{Name: ident, Signature: strings.TrimPrefix(objsym.Type, TypePrefix+"func")})
+		case SDATA, SBSS, SNOPTRDATA, SNOPTRBSS:
+			if !objsym.DupOK {
+				pkgDoc(pkg).Vars = append(pkgDoc(pkg).Vars, VarDoc{Name: ident, Type: strings.TrimPrefix(objsym.Type, TypePrefix)})
+			}
+		}
+	}
+	// method sets of the value types first, a method of the pointer type
+	// not in the value type has a pointer receiver
+	for _, ptr := range []bool{false, true} {
+		for name, objsym := range syms {
+			if !strings.HasPrefix(name, TypePrefix) || strings.HasPrefix(name, TypeDoubleDotPrefix) {
+				continue
+			}
+			typeName := name[len(TypePrefix):]
+			if strings.HasPrefix(typeName, "*") != ptr {
+				continue
+			}
+			pkg, ident := splitExported(strings.TrimPrefix(typeName, "*"))
+			if pkg == EmptyString {
+				continue
+			}
+			key := pkg + "." + ident
+			doc, ok := types[key]
+			if !ok {
+				doc = &TypeDoc{Name: ident}
+				types[key] = doc
+			}
+			if !ptr {
+				doc.Kind = typeKind(objsym)
+			}
+			for _, method := range typeMethods(objsym) {
+				if !hasMethod(doc, method.Name) {
+					method.PtrReceiver = ptr
+					doc.Methods = append(doc.Methods, method)
+				}
+			}
+		}
+	}
+	for key, doc := range types {
+		if doc.Kind == reflect.Invalid && len(doc.Methods) == 0 {
+			continue
+		}
+		sort.Slice(doc.Methods, func(i, j int) bool { return doc.Methods[i].Name < doc.Methods[j].Name })
+		pkg := pkgDoc(key[:len(key)-len(doc.Name)-1])
+		pkg.Types = append(pkg.Types, *doc)
+	}
+	apiDoc := &APIDoc{}
+	for _, pkg := range pkgs {
+		sort.Slice(pkg.Funcs, func(i, j int) bool { return pkg.Funcs[i].Name < pkg.Funcs[j].Name })
+		sort.Slice(pkg.Vars, func(i, j int) bool { return pkg.Vars[i].Name < pkg.Vars[j].Name })
+		sort.Slice(pkg.Types, func(i, j int) bool { return pkg.Types[i].Name < pkg.Types[j].Name })
+		apiDoc.Packages = append(apiDoc.Packages, *pkg)
+	}
+	sort.Slice(apiDoc.Packages, func(i, j int) bool { return apiDoc.Packages[i].Path < apiDoc.Packages[j].Path })
+	return apiDoc
+}
+
+// splitExported splits a symbol name into its package path and its
+// identifier, pkg is "" if the identifier is not exported or the symbol
+// is not a package-level function, variable or type, e.g. a method,
+// a closure or a symbol generated by the compiler.
+func splitExported(name string) (pkg, ident string) {
+	pkg = symbolPkg(name)
+	if pkg == EmptyString || strings.ContainsAny(pkg, "[]*(){}, ") {
+		return EmptyString, EmptyString
+	}
+	ident = name[len(pkg)+1:]
+	r, _ := utf8.DecodeRuneInString(ident)
+	if !unicode.IsUpper(r) {
+		return EmptyString, EmptyString
+	}
+	for _, c := range ident {
+		if !unicode.IsLetter(c) && !unicode.IsDigit(c) && c != '_' {
+			return EmptyString, EmptyString
+		}
+	}
+	return pkg, ident
+}
+
+// typeKind returns the kind recorded in the _type of a type symbol.
+func typeKind(objsym *ObjSymbol) reflect.Kind {
+	// size, ptrdata, hash, tflag, align, fieldAlign, kind
+	off := 2*PtrSize + 4 + 3
+	if len(objsym.Data) <= off {
+		return reflect.Invalid
+	}
+	return reflect.Kind(objsym.Data[off] & typeKindMask)
+}
+
+// typeMethods returns the exported methods of the method table of a type
+// symbol, each method is a name, a mtyp, an ifn and a tfn and the last
+// three are R_METHODOFF relocations to the type.func symbol and the code.
+func typeMethods(objsym *ObjSymbol) []MethodDoc {
+	relocs := make([]Reloc, len(objsym.Reloc))
+	copy(relocs, objsym.Reloc)
+	sort.Slice(relocs, func(i, j int) bool { return relocs[i].Offset < relocs[j].Offset })
+	methods := make([]MethodDoc, 0)
+	for i := 0; i+2 < len(relocs); i++ {
+		mtyp, ifn, tfn := relocs[i], relocs[i+1], relocs[i+2]
+		if mtyp.Type != R_METHODOFF || ifn.Type != R_METHODOFF || tfn.Type != R_METHODOFF ||
+			ifn.Offset != mtyp.Offset+4 || tfn.Offset != mtyp.Offset+8 ||
+			!strings.HasPrefix(mtyp.Sym.Name, TypePrefix+"func(") {
+			continue
+		}
+		code := tfn.Sym.Name
+		if code == EmptyString {
+			code = ifn.Sym.Name
+		}
+		name := code[strings.LastIndex(code, ".")+1:]
+		if r, _ := utf8.DecodeRuneInString(name); unicode.IsUpper(r) {
+			methods = append(methods, MethodDoc{Name: name, Signature: mtyp.Sym.Name[len(TypePrefix+"func"):]})
+		}
+		i += 2
+	}
+	return methods
+}
+
+func hasMethod(doc *TypeDoc, name string) bool {
+	for _, method := range doc.Methods {
+		if method.Name == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Print writes the API in the form of go declarations.
+func (doc *APIDoc) Print(w io.Writer) error {
+	for i, pkg := range doc.Packages {
+		if i > 0 {
+			if _, err := fmt.Fprintln(w); err != nil {
+				return err
+			}
+		}
+		lines := []string{fmt.Sprintf("package %s\n", pkg.Path)}
+		for _, f := range pkg.Funcs {
+			if f.Signature == EmptyString {
+				lines = append(lines, fmt.Sprintf("func %s // signature not recorded", f.Name))
+			} else {
+				lines = append(lines, fmt.Sprintf("func %s%s", f.Name, f.Signature))
+			}
+		}
+		for _, v := range pkg.Vars {
+			lines = append(lines, strings.TrimSpace(fmt.Sprintf("var %s %s", v.Name, v.Type)))
+		}
+		for _, t := range pkg.Types {
+			lines = append(lines, fmt.Sprintf("type %s %s", t.Name, t.Kind))
+			for _, m := range t.Methods {
+				recv := t.Name
+				if m.PtrReceiver {
+					recv = "*" + recv
+				}
+				lines = append(lines, fmt.Sprintf("\tfunc (%s) %s%s", recv, m.Name, m.Signature))
+			}
+		}
+		if _, err := fmt.Fprintln(w, strings.Join(lines, "\n")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,77 @@
+package goloader
+
+import (
+	"encoding/binary"
+	"fmt"
+	"runtime"
+	"unsafe"
+)
+
+// archInfo is what goloader needs to know of a GOARCH besides its
+// relocations: the byte order the code and the data of a module are written
+// in, and the pc quantum, the minimum length of an instruction.
+type archInfo struct {
+	byteOrder binary.ByteOrder
+	minLC     byte
+}
+
+// names of archs compared without cmd/internal/sys, which has riscv64
+// since go1.11
+const (
+	archRISCV64 = "riscv64"
+	archS390X   = "s390x"
+)
+
+var archs = map[string]archInfo{
+	"386":      {binary.LittleEndian, 1},
+	"amd64":    {binary.LittleEndian, 1},
+	"arm":      {binary.LittleEndian, 4},
+	"arm64":    {binary.LittleEndian, 4},
+	"mips64":   {binary.BigEndian, 4},
+	"mips64le": {binary.LittleEndian, 4},
+	"riscv64":  {binary.LittleEndian, 4},
+	"s390x":    {binary.BigEndian, 2},
+}
+
+// byteOrder is the byte order of the host, every word of a module, in its
+// code, data and tables, is written in it.
+var byteOrder = nativeByteOrder()
+
+func nativeByteOrder() binary.ByteOrder {
+	x := uint16(1)
+	if *(*byte)(unsafe.Pointer(&x)) == 1 {
+		return binary.LittleEndian
+	}
+	return binary.BigEndian
+}
+
+// offset of minLC in the header of the pclntable
+const pcHeaderMinLC = 6
+
+// pcHeaderHead returns the magic, the pc quantum and the pointer size the
+// header of a pclntable starts with, in the byte order of the host.
+func pcHeaderHead(magic uint32) []byte {
+	head := make([]byte, 8)
+	byteOrder.PutUint32(head, magic)
+	head[pcHeaderMinLC] = archs[runtime.GOARCH].minLC
+	head[pcHeaderMinLC+1] = PtrSize
+	return head
+}
+
+// checkArch checks that objects built for arch can be loaded by the host.
+func checkArch(arch string) error {
+	if arch == EmptyString {
+		return nil
+	}
+	info, ok := archs[arch]
+	if !ok {
+		return fmt.Errorf("goloader: arch %s is not supported", arch)
+	}
+	if arch != runtime.GOARCH {
+		return fmt.Errorf("goloader: objects built for %s can not be loaded on %s", arch, runtime.GOARCH)
+	}
+	if info.byteOrder != byteOrder {
+		return fmt.Errorf("goloader: arch %s is %s, the host is %s", arch, info.byteOrder, byteOrder)
+	}
+	return nil
+}
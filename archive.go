@@ -0,0 +1,127 @@
+package goloader
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// see $GOROOT/src/cmd/internal/archive/archive.go
+const (
+	archiveMagic      = "!<arch>\n"
+	archiveHeaderSize = 60
+	goobjHeaderPrefix = "go object "
+	pkgDefName        = "__.PKGDEF"
+)
+
+// archiveObj is a go object file in an archive, offset and size exclude
+// its text header, which starts at start.
+type archiveObj struct {
+	name    string
+	arch    string
+	version string // go version of the compiler
+	start   int64
+	offset  int64
+	size    int64
+}
+
+var errNotGoObject = errors.New("not a go object file")
+
+func readerSize(r io.ReaderAt) (int64, error) {
+	switch v := r.(type) {
+	case interface{ Size() int64 }:
+		return v.Size(), nil
+	case interface {
+		Stat() (os.FileInfo, error)
+	}:
+		fi, err := v.Stat()
+		if err != nil {
+			return 0, err
+		}
+		return fi.Size(), nil
+	case io.Seeker:
+		return v.Seek(0, io.SeekEnd)
+	}
+	return 0, errors.New("can not get size of reader, it has no Size, Stat or Seek method")
+}
+
+// parseGoObjHeader reads the text header of a go object file at offset,
+// it ends with "\n!\n".
+func parseGoObjHeader(r io.ReaderAt, name string, offset, size int64) (*archiveObj, error) {
+	limit := int64(4096)
+	if limit > size {
+		limit = size
+	}
+	head := make([]byte, limit)
+	if _, err := r.ReadAt(head, offset); err != nil && err != io.EOF {
+		return nil, err
+	}
+	if !bytes.HasPrefix(head, []byte(goobjHeaderPrefix)) {
+		return nil, errNotGoObject
+	}
+	end := bytes.Index(head, []byte("\n!\n"))
+	if end < 0 {
+		return nil, fmt.Errorf("archive member %s: go object header too long", name)
+	}
+	end += len("\n!\n")
+	obj := &archiveObj{name: name, start: offset, offset: offset + int64(end), size: size - int64(end)}
+	// go object GOOS GOARCH VERSION ...
+	fields := strings.Fields(string(head[:end]))
+	if len(fields) >= 4 {
+		obj.arch = fields[3]
+	}
+	if len(fields) >= 5 {
+		obj.version = fields[4]
+	}
+	return obj, nil
+}
+
+// readArchive returns the go object files in r, r is an archive, e.g.
+// built by go build, or a single go object file. Archive members which are
+// not go object files (objects of cgo) are skipped.
+func readArchive(r io.ReaderAt, size int64) ([]*archiveObj, error) {
+	magic := make([]byte, len(archiveMagic))
+	if _, err := r.ReadAt(magic, 0); err != nil {
+		return nil, err
+	}
+	if string(magic) != archiveMagic {
+		obj, err := parseGoObjHeader(r, EmptyString, 0, size)
+		if err != nil {
+			return nil, fmt.Errorf("unrecognized object file: %v", err)
+		}
+		return []*archiveObj{obj}, nil
+	}
+	objs := make([]*archiveObj, 0)
+	header := make([]byte, archiveHeaderSize)
+	for offset := int64(len(archiveMagic)); offset < size; {
+		if _, err := r.ReadAt(header, offset); err != nil {
+			return nil, fmt.Errorf("read archive header at %d: %v", offset, err)
+		}
+		if header[58] != '`' || header[59] != '\n' {
+			return nil, fmt.Errorf("malformed archive header at %d", offset)
+		}
+		name := strings.TrimRight(strings.TrimSpace(string(header[0:16])), "/")
+		memberSize, err := strconv.ParseInt(strings.TrimSpace(string(header[48:58])), 10, 64)
+		if err != nil || memberSize < 0 || offset+archiveHeaderSize+memberSize > size {
+			return nil, fmt.Errorf("malformed size of archive member %s", name)
+		}
+		offset += archiveHeaderSize
+		if name != pkgDefName {
+			obj, err := parseGoObjHeader(r, name, offset, memberSize)
+			if err == nil {
+				objs = append(objs, obj)
+			} else if err != errNotGoObject {
+				return nil, err
+			}
+		}
+		offset += memberSize + memberSize&1
+	}
+	if len(objs) == 0 {
+		return nil, errors.New("archive contains no go object file")
+	}
+	return objs, nil
+}
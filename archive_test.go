@@ -0,0 +1,88 @@
+package goloader
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+)
+
+const testObjHeader = "go object linux amd64 go1.16.5 X:none\n!\n"
+
+// archiveMember returns the header and the data of an archive member,
+// padded to an even size.
+func archiveMember(name string, data string) string {
+	member := fmt.Sprintf("%-16s%-12d%-6d%-6d%-8o%-10d`\n", name+"/", 0, 0, 0, 0644, len(data)) + data
+	if len(data)%2 == 1 {
+		member += "\n"
+	}
+	return member
+}
+
+func TestReadArchive(t *testing.T) {
+	pkgdef := archiveMember(pkgDefName, "go object linux amd64 go1.16.5 X:none\nbuild id\n")
+	goObj := archiveMember("_go_.o", testObjHeader+"odd")
+	cgoObj := archiveMember("_x001.o", "\x7fELF")
+	asmObj := archiveMember("asm.o", testObjHeader+"even")
+	goStart := int64(len(archiveMagic) + len(pkgdef) + archiveHeaderSize)
+	asmStart := int64(len(archiveMagic) + len(pkgdef) + len(goObj) + len(cgoObj) + archiveHeaderSize)
+	tests := []struct {
+		name    string
+		data    string
+		objs    []archiveObj
+		wantErr bool
+	}{{
+		name: "object",
+		data: testObjHeader + "data",
+		objs: []archiveObj{{arch: "amd64", version: "go1.16.5", offset: int64(len(testObjHeader)), size: 4}},
+	}, {
+		name: "archive",
+		data: archiveMagic + pkgdef + goObj + cgoObj + asmObj,
+		objs: []archiveObj{
+			{name: "_go_.o", arch: "amd64", version: "go1.16.5", start: goStart, size: 3},
+			{name: "asm.o", arch: "amd64", version: "go1.16.5", start: asmStart, size: 4},
+		},
+	}, {
+		name:    "not an object",
+		data:    "\x7fELF",
+		wantErr: true,
+	}, {
+		name:    "no go object",
+		data:    archiveMagic + archiveMember("_x001.o", "\x7fELF"),
+		wantErr: true,
+	}, {
+		name:    "malformed header",
+		data:    archiveMagic + archiveMember("_go_.o", testObjHeader)[:58] + "xx" + testObjHeader,
+		wantErr: true,
+	}, {
+		name:    "truncated member",
+		data:    (archiveMagic + archiveMember("_go_.o", testObjHeader+"data"))[:8+60+10],
+		wantErr: true,
+	}}
+	for _, test := range tests {
+		r := bytes.NewReader([]byte(test.data))
+		objs, err := readArchive(r, int64(len(test.data)))
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: readArchive succeeded", test.name)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: readArchive: %v", test.name, err)
+			continue
+		}
+		if len(objs) != len(test.objs) {
+			t.Errorf("%s: readArchive returned %d objects, want %d", test.name, len(objs), len(test.objs))
+			continue
+		}
+		for i, obj := range objs {
+			want := test.objs[i]
+			if want.offset == 0 {
+				want.offset = want.start + int64(len(testObjHeader))
+			}
+			if *obj != want {
+				t.Errorf("%s: object %d is %+v, want %+v", test.name, i, *obj, want)
+			}
+		}
+	}
+}
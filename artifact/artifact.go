@@ -0,0 +1,114 @@
+// Package artifact keeps bundles, linkers encoded with Linker.Encode, in
+// content-addressed stores: a local directory, an OCI registry or an S3
+// bucket. A bundle is stored under its digest and tags point at digests,
+// so bundles are managed like container images.
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/pkujhd/goloader"
+)
+
+// ErrNotFound is returned by a Store for a missing blob or tag.
+var ErrNotFound = errors.New("artifact: not found")
+
+// Store keeps blobs by digest and tags pointing at digests.
+type Store interface {
+	PutBlob(ctx context.Context, digest string, data []byte) error
+	GetBlob(ctx context.Context, digest string) ([]byte, error)
+	PutTag(ctx context.Context, tag, digest string) error
+	GetTag(ctx context.Context, tag string) (string, error)
+}
+
+const digestPrefix = "sha256:"
+
+// tags are the tags of OCI registries
+var tagPattern = regexp.MustCompile(`^[A-Za-z0-9_][A-Za-z0-9_.-]{0,127}$`)
+
+// Digest returns the content address of data, "sha256:" and its hex hash.
+func Digest(data []byte) string {
+	sum := sha256.Sum256(data)
+	return digestPrefix + hex.EncodeToString(sum[:])
+}
+
+// IsDigest reports whether ref is a digest rather than a tag.
+func IsDigest(ref string) bool {
+	if !strings.HasPrefix(ref, digestPrefix) {
+		return false
+	}
+	b, err := hex.DecodeString(ref[len(digestPrefix):])
+	return err == nil && len(b) == sha256.Size
+}
+
+func checkTag(tag string) error {
+	if !tagPattern.MatchString(tag) {
+		return fmt.Errorf("artifact: bad tag %q", tag)
+	}
+	return nil
+}
+
+// Push stores the bundle of linker and tags it with tag, if tag is not
+// empty. It returns the digest of the bundle.
+func Push(ctx context.Context, store Store, tag string, linker *goloader.Linker) (string, error) {
+	if tag != "" {
+		if err := checkTag(tag); err != nil {
+			return "", err
+		}
+	}
+	var buf bytes.Buffer
+	if err := linker.Encode(&buf); err != nil {
+		return "", err
+	}
+	digest := Digest(buf.Bytes())
+	if err := store.PutBlob(ctx, digest, buf.Bytes()); err != nil {
+		return "", err
+	}
+	if tag != "" {
+		if err := store.PutTag(ctx, tag, digest); err != nil {
+			return "", err
+		}
+	}
+	return digest, nil
+}
+
+// Pull returns the linker of the bundle ref, a tag or a digest. The bundle
+// is rejected if its content does not match its digest or one of verifiers
+// fails on it.
+func Pull(ctx context.Context, store Store, ref string, verifiers ...goloader.Verifier) (*goloader.Linker, error) {
+	digest := ref
+	if !IsDigest(ref) {
+		if err := checkTag(ref); err != nil {
+			return nil, err
+		}
+		var err error
+		if digest, err = store.GetTag(ctx, ref); err != nil {
+			return nil, err
+		}
+		if !IsDigest(digest) {
+			return nil, fmt.Errorf("artifact: tag %s points at bad digest %q", ref, digest)
+		}
+	}
+	data, err := store.GetBlob(ctx, digest)
+	if err != nil {
+		return nil, err
+	}
+	if got := Digest(data); got != digest {
+		return nil, fmt.Errorf("artifact: blob %s has digest %s", digest, got)
+	}
+	linker, err := goloader.DecodeLinker(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if err = goloader.Verify(linker, verifiers...); err != nil {
+		return nil, err
+	}
+	return linker, nil
+}
@@ -0,0 +1,80 @@
+package artifact
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// DirStore keeps blobs in root/blobs/sha256/<hex> and tags in root/tags,
+// e.g. on a shared file system.
+type DirStore struct {
+	root string
+}
+
+func NewDirStore(root string) *DirStore {
+	return &DirStore{root: root}
+}
+
+func (s *DirStore) blobPath(digest string) string {
+	return filepath.Join(s.root, "blobs", "sha256", strings.TrimPrefix(digest, digestPrefix))
+}
+
+// write writes data to name through a temporary file, readers never see
+// a partial file.
+func write(name string, data []byte) error {
+	if err := os.MkdirAll(filepath.Dir(name), 0755); err != nil {
+		return err
+	}
+	f, err := ioutil.TempFile(filepath.Dir(name), ".tmp")
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), name)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+	}
+	return err
+}
+
+func read(name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(name)
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return data, err
+}
+
+func (s *DirStore) PutBlob(ctx context.Context, digest string, data []byte) error {
+	if _, err := os.Stat(s.blobPath(digest)); err == nil {
+		return nil
+	}
+	return write(s.blobPath(digest), data)
+}
+
+func (s *DirStore) GetBlob(ctx context.Context, digest string) ([]byte, error) {
+	return read(s.blobPath(digest))
+}
+
+func (s *DirStore) PutTag(ctx context.Context, tag, digest string) error {
+	if err := checkTag(tag); err != nil {
+		return err
+	}
+	return write(filepath.Join(s.root, "tags", tag), []byte(digest))
+}
+
+func (s *DirStore) GetTag(ctx context.Context, tag string) (string, error) {
+	if err := checkTag(tag); err != nil {
+		return "", err
+	}
+	data, err := read(filepath.Join(s.root, "tags", tag))
+	return strings.TrimSpace(string(data)), err
+}
@@ -0,0 +1,198 @@
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// media types of the OCI manifest of a bundle
+const (
+	ManifestMediaType = "application/vnd.oci.image.manifest.v1+json"
+	ConfigMediaType   = "application/vnd.goloader.config.v1+json"
+	BundleMediaType   = "application/vnd.goloader.bundle.v1"
+)
+
+// OCIStore keeps bundles in a repository of an OCI registry with the
+// distribution API: a bundle is the only layer of an image manifest, which
+// is tagged in the registry.
+type OCIStore struct {
+	// Registry is the base url, e.g. "https://registry.example.com".
+	Registry string
+	// Repository is the name of the repository, e.g. "team/plugins".
+	Repository string
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+	// Authorize, if not nil, adds credentials to each request, e.g. a
+	// bearer token.
+	Authorize func(req *http.Request)
+}
+
+type descriptor struct {
+	MediaType string `json:"mediaType"`
+	Digest    string `json:"digest"`
+	Size      int64  `json:"size"`
+}
+
+type manifest struct {
+	SchemaVersion int          `json:"schemaVersion"`
+	MediaType     string       `json:"mediaType"`
+	Config        descriptor   `json:"config"`
+	Layers        []descriptor `json:"layers"`
+}
+
+// empty config of the manifests
+var ociConfig = []byte("{}")
+
+func (s *OCIStore) url(kind, ref string) string {
+	return strings.TrimRight(s.Registry, "/") + "/v2/" + s.Repository + "/" + kind + "/" + ref
+}
+
+func (s *OCIStore) do(ctx context.Context, method, u string, body []byte, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, u, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = int64(len(body))
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if s.Authorize != nil {
+		s.Authorize(req)
+	}
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+// check drains and closes the body of resp and returns an error unless its
+// status is want, a 404 is ErrNotFound.
+func check(resp *http.Response, want ...int) error {
+	body, _ := ioutil.ReadAll(io.LimitReader(resp.Body, 4096))
+	resp.Body.Close()
+	for _, code := range want {
+		if resp.StatusCode == code {
+			return nil
+		}
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrNotFound
+	}
+	return fmt.Errorf("artifact: %s %s: %s: %s", resp.Request.Method, resp.Request.URL, resp.Status, bytes.TrimSpace(body))
+}
+
+// blobSize returns the size of a blob in the registry, or ErrNotFound.
+func (s *OCIStore) blobSize(ctx context.Context, digest string) (int64, error) {
+	resp, err := s.do(ctx, http.MethodHead, s.url("blobs", digest), nil, nil)
+	if err != nil {
+		return 0, err
+	}
+	if err := check(resp, http.StatusOK); err != nil {
+		return 0, err
+	}
+	return resp.ContentLength, nil
+}
+
+func (s *OCIStore) PutBlob(ctx context.Context, digest string, data []byte) error {
+	if _, err := s.blobSize(ctx, digest); err == nil {
+		return nil
+	} else if err != ErrNotFound {
+		return err
+	}
+	resp, err := s.do(ctx, http.MethodPost, s.url("blobs", "uploads/"), nil, nil)
+	if err != nil {
+		return err
+	}
+	if err := check(resp, http.StatusAccepted); err != nil {
+		return err
+	}
+	location, err := resp.Request.URL.Parse(resp.Header.Get("Location"))
+	if err != nil {
+		return err
+	}
+	query := location.Query()
+	query.Set("digest", digest)
+	location.RawQuery = query.Encode()
+	header := http.Header{"Content-Type": {"application/octet-stream"}}
+	if resp, err = s.do(ctx, http.MethodPut, location.String(), data, header); err != nil {
+		return err
+	}
+	return check(resp, http.StatusCreated)
+}
+
+func (s *OCIStore) GetBlob(ctx context.Context, digest string) ([]byte, error) {
+	resp, err := s.do(ctx, http.MethodGet, s.url("blobs", digest), nil, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, check(resp, http.StatusOK)
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+// PutTag tags a manifest holding the blob digest, which must be pushed.
+func (s *OCIStore) PutTag(ctx context.Context, tag, digest string) error {
+	if err := checkTag(tag); err != nil {
+		return err
+	}
+	size, err := s.blobSize(ctx, digest)
+	if err != nil {
+		return err
+	}
+	configDigest := Digest(ociConfig)
+	if err := s.PutBlob(ctx, configDigest, ociConfig); err != nil {
+		return err
+	}
+	body, err := json.Marshal(manifest{
+		SchemaVersion: 2,
+		MediaType:     ManifestMediaType,
+		Config:        descriptor{MediaType: ConfigMediaType, Digest: configDigest, Size: int64(len(ociConfig))},
+		Layers:        []descriptor{{MediaType: BundleMediaType, Digest: digest, Size: size}},
+	})
+	if err != nil {
+		return err
+	}
+	header := http.Header{"Content-Type": {ManifestMediaType}}
+	resp, err := s.do(ctx, http.MethodPut, s.url("manifests", url.PathEscape(tag)), body, header)
+	if err != nil {
+		return err
+	}
+	return check(resp, http.StatusCreated)
+}
+
+// GetTag returns the digest of the bundle of the manifest tagged tag.
+func (s *OCIStore) GetTag(ctx context.Context, tag string) (string, error) {
+	if err := checkTag(tag); err != nil {
+		return "", err
+	}
+	header := http.Header{"Accept": {ManifestMediaType}}
+	resp, err := s.do(ctx, http.MethodGet, s.url("manifests", url.PathEscape(tag)), nil, header)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", check(resp, http.StatusOK)
+	}
+	defer resp.Body.Close()
+	var m manifest
+	if err := json.NewDecoder(resp.Body).Decode(&m); err != nil {
+		return "", err
+	}
+	for _, layer := range m.Layers {
+		if layer.MediaType == BundleMediaType {
+			return layer.Digest, nil
+		}
+	}
+	return "", fmt.Errorf("artifact: manifest %s has no %s layer", tag, BundleMediaType)
+}
@@ -0,0 +1,140 @@
+package artifact
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+)
+
+// S3Store keeps blobs and tags as objects of an S3 bucket, or of a service
+// with the S3 API, under Prefix+"blobs/sha256/<hex>" and Prefix+"tags/".
+// Requests are signed with AWS signature version 4.
+type S3Store struct {
+	// Endpoint is the url of the bucket, e.g.
+	// "https://bucket.s3.eu-west-1.amazonaws.com".
+	Endpoint string
+	Region   string
+	Prefix   string
+	// AccessKey and SecretKey sign the requests, SessionToken is set for
+	// temporary credentials.
+	AccessKey    string
+	SecretKey    string
+	SessionToken string
+	// Client sends the requests, http.DefaultClient if nil.
+	Client *http.Client
+}
+
+func (s *S3Store) object(ctx context.Context, method, key string, body []byte) (*http.Response, error) {
+	req, err := http.NewRequest(method, strings.TrimRight(s.Endpoint, "/")+"/"+s.Prefix+key, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req = req.WithContext(ctx)
+	req.ContentLength = int64(len(body))
+	s.sign(req, body, time.Now().UTC())
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	return client.Do(req)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+func sha256Hex(data []byte) string {
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// sign adds the AWS signature version 4 of req to its headers.
+func (s *S3Store) sign(req *http.Request, body []byte, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := amzDate[:8]
+	payloadHash := sha256Hex(body)
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", payloadHash)
+	if s.SessionToken != "" {
+		req.Header.Set("X-Amz-Security-Token", s.SessionToken)
+	}
+	headers := map[string]string{"host": req.URL.Host}
+	for key := range req.Header {
+		if name := strings.ToLower(key); strings.HasPrefix(name, "x-amz-") {
+			headers[name] = strings.TrimSpace(req.Header.Get(key))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for name := range headers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var canonicalHeaders bytes.Buffer
+	for _, name := range names {
+		canonicalHeaders.WriteString(name + ":" + headers[name] + "\n")
+	}
+	signedHeaders := strings.Join(names, ";")
+	canonicalRequest := strings.Join([]string{req.Method, req.URL.EscapedPath(), req.URL.RawQuery,
+		canonicalHeaders.String(), signedHeaders, payloadHash}, "\n")
+	scope := date + "/" + s.Region + "/s3/aws4_request"
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + sha256Hex([]byte(canonicalRequest))
+	key := hmacSHA256([]byte("AWS4"+s.SecretKey), date)
+	key = hmacSHA256(key, s.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	req.Header.Set("Authorization", fmt.Sprintf("AWS4-HMAC-SHA256 Credential=%s/%s, SignedHeaders=%s, Signature=%s",
+		s.AccessKey, scope, signedHeaders, hex.EncodeToString(hmacSHA256(key, stringToSign))))
+}
+
+func (s *S3Store) put(ctx context.Context, key string, data []byte) error {
+	resp, err := s.object(ctx, http.MethodPut, key, data)
+	if err != nil {
+		return err
+	}
+	return check(resp, http.StatusOK)
+}
+
+func (s *S3Store) get(ctx context.Context, key string) ([]byte, error) {
+	resp, err := s.object(ctx, http.MethodGet, key, nil)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, check(resp, http.StatusOK)
+	}
+	defer resp.Body.Close()
+	return ioutil.ReadAll(resp.Body)
+}
+
+func (s *S3Store) PutBlob(ctx context.Context, digest string, data []byte) error {
+	return s.put(ctx, "blobs/sha256/"+strings.TrimPrefix(digest, digestPrefix), data)
+}
+
+func (s *S3Store) GetBlob(ctx context.Context, digest string) ([]byte, error) {
+	return s.get(ctx, "blobs/sha256/"+strings.TrimPrefix(digest, digestPrefix))
+}
+
+func (s *S3Store) PutTag(ctx context.Context, tag, digest string) error {
+	if err := checkTag(tag); err != nil {
+		return err
+	}
+	return s.put(ctx, "tags/"+tag, []byte(digest))
+}
+
+func (s *S3Store) GetTag(ctx context.Context, tag string) (string, error) {
+	if err := checkTag(tag); err != nil {
+		return "", err
+	}
+	data, err := s.get(ctx, "tags/"+tag)
+	return strings.TrimSpace(string(data)), err
+}
@@ -1,9 +1,13 @@
 package goloader
 
 const (
-	x86amd64MOVcode  byte = 0x8B
-	x86amd64LEAcode  byte = 0x8D
-	x86amd64CMPLcode byte = 0x83
+	x86amd64MOVcode    byte = 0x8B
+	x86amd64LEAcode    byte = 0x8D
+	x86amd64CMPLcode   byte = 0x83
+	x86amd64MOVABScode byte = 0xB8 // MOVQ $imm64, reg, the register in the low 3 bits
+	x86amd64REXcode    byte = 0x40
+	x86amd64REXW       byte = 0x08 // 64-bit operand size
+	x86amd64REXR       byte = 0x04 // high bit of the register field of modrm
 )
 
 // arm/arm64
@@ -12,7 +16,48 @@ var (
 	arm64code = []byte{
 		0x49, 0x00, 0x00, 0x58, // LDR X9 [PC+8]
 		0x20, 0x01, 0x1F, 0xD6} // BR X9
-	arm64BLcode = []byte{0x00, 0x00, 0x00, 0x94} // BL [PC+0x0]
+)
+
+// arm64 instructions without operands
+const (
+	arm64Bcode          uint32 = 0x14000000 // B, the word offset in the low 26 bits
+	arm64LDRLiteralcode uint32 = 0x58000000 // LDR Xt, [PC+imm19*4]
+	arm64NOPcode        uint32 = 0xD503201F
+)
+
+// arm branches, the condition is in the top 4 bits
+const (
+	armCondMask uint32 = 0xF0000000
+	armCondAL   uint32 = 0xE0000000 // always
+	armOpMask   uint32 = 0x0F000000
+	armBLcode   uint32 = 0x0B000000
+	armBLXcode  uint32 = 0xFA000000 // BLX imm, bit 24 is bit 1 of the offset
+)
+
+// riscv64, instructions without operands and immediates
+const (
+	riscvAUIPCcode uint32 = 0x00000017
+	riscvJALRcode  uint32 = 0x00000067
+	riscvLDcode    uint32 = 0x00003003
+	riscvNOPcode   uint32 = 0x00000013 // ADDI X0, X0, 0
+	riscvOpMask    uint32 = 0x7F
+	riscvTMP       uint32 = 31 // X31, the temporary register of the go assembler
+)
+
+// mips64, R23 is REGTMP of the go assembler
+const (
+	mipsLUIcode  uint32 = 0x3C000000
+	mipsORIcode  uint32 = 0x34000000
+	mipsDSLLcode uint32 = 0x00000038
+	mipsJRcode   uint32 = 0x00000008
+	mipsNOPcode  uint32 = 0x00000000
+	mipsTMP      uint32 = 23
+)
+
+// s390x, R10 is REGTMP of the go assembler
+var (
+	s390xLGRLcode = []byte{0xC4, 0xA8, 0x00, 0x00, 0x00, 0x00} // LGRL R10, [PC+0x0]
+	s390xBRcode   = []byte{0x07, 0xFA}                         // BR R10
 )
 
 // x86/amd64
@@ -27,7 +72,4 @@ var (
 		0x5b,                               // POP EBX
 		0x58,                               // POP EAX
 		0xff, 0x25, 0x08, 0x00, 0x00, 0x00} // JMPL *ADDRESS
-	x86amd64replaceMOVQcode = []byte{
-		0x48, 0xb8, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, 0x00, //MOVE RxX x
-		0xff, 0x25, 0x00, 0x00, 0x00, 0x00} //JMPL *ADDRESS
 )
@@ -0,0 +1,117 @@
+package goloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sync"
+	"time"
+)
+
+// audited operations
+const (
+	AuditParse  = "parse"
+	AuditVerify = "verify"
+	AuditLoad   = "load"
+	AuditInit   = "init"
+	AuditSwap   = "swap"
+	AuditUnload = "unload"
+)
+
+// AuditEvent records one operation on dynamic code.
+type AuditEvent struct {
+	Seq    uint64    `json:"seq"`
+	Time   time.Time `json:"time"`
+	Op     string    `json:"op"`
+	Actor  string    `json:"actor"`
+	Hash   string    `json:"hash,omitempty"`   // sha256 of the code and data, see Linker.Hash
+	Detail string    `json:"detail,omitempty"` // object files, symbol names...
+	Error  string    `json:"error,omitempty"`  // outcome, empty on success
+}
+
+// AuditSink receives audit events in order, events must only be appended.
+type AuditSink interface {
+	Append(event AuditEvent)
+}
+
+var (
+	auditLock  sync.Mutex
+	auditSink  AuditSink
+	auditActor string
+	auditSeq   uint64
+)
+
+// SetAuditSink sets the sink receiving all later audit events, recorded
+// with actor. A nil sink disables the audit log.
+func SetAuditSink(sink AuditSink, actor string) {
+	auditLock.Lock()
+	defer auditLock.Unlock()
+	auditSink = sink
+	auditActor = actor
+}
+
+// Audit appends an event to the audit log, packages driving loaded modules
+// (e.g. a swap of modules) use it to record their operations.
+func Audit(op, hash, detail string, err error) {
+	auditLock.Lock()
+	defer auditLock.Unlock()
+	if auditSink == nil {
+		return
+	}
+	auditSeq++
+	event := AuditEvent{
+		Seq:    auditSeq,
+		Time:   time.Now(),
+		Op:     op,
+		Actor:  auditActor,
+		Hash:   hash,
+		Detail: detail,
+	}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	auditSink.Append(event)
+}
+
+// Hash returns the hex encoded sha256 of the code and data of linker.
+func (linker *Linker) Hash() string {
+	if linker.container != nil {
+		return linker.container.index.Hash
+	}
+	h := sha256.New()
+	h.Write(linker.code)
+	h.Write(linker.data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Hash returns the hash of the linker the module was loaded from.
+func (cm *CodeModule) Hash() string {
+	return cm.hash
+}
+
+// AuditWriter is an AuditSink writing one json object per line.
+type AuditWriter struct {
+	lock sync.Mutex
+	enc  *json.Encoder
+	err  error
+}
+
+func NewAuditWriter(w io.Writer) *AuditWriter {
+	return &AuditWriter{enc: json.NewEncoder(w)}
+}
+
+func (aw *AuditWriter) Append(event AuditEvent) {
+	aw.lock.Lock()
+	defer aw.lock.Unlock()
+	if err := aw.enc.Encode(event); err != nil && aw.err == nil {
+		aw.err = err
+	}
+}
+
+// Err returns the first write error.
+func (aw *AuditWriter) Err() error {
+	aw.lock.Lock()
+	defer aw.lock.Unlock()
+	return aw.err
+}
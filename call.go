@@ -0,0 +1,134 @@
+package goloader
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+func toType(addr uintptr) reflect.Type {
+	var i interface{}
+	(*emptyInterface)(unsafe.Pointer(&i)).typ = adduintptr(addr, 0)
+	return reflect.TypeOf(i)
+}
+
+func isErrorType(t reflect.Type) bool {
+	return t.Kind() == reflect.Interface && t.NumMethod() == 1 &&
+		t.Method(0).Name == "Error" && t.Method(0).Type.NumIn() == 0
+}
+
+// FuncType returns the type of name, recovered from the object file: the
+// go type information the compiler records for package-level variables,
+// e.g. a variable holding a func, or the DWARF of a function, which holds
+// the types of its parameters. A variadic function is only told from one
+// taking a slice if its func type is in the module or the host.
+func (cm *CodeModule) FuncType(name string) (reflect.Type, error) {
+	addr, ok := cm.types[name]
+	if !ok || addr == 0 {
+		return cm.funcSigType(name)
+	}
+	t := toType(addr)
+	if t.Kind() != reflect.Func {
+		return nil, fmt.Errorf("goloader: %s is %s, not a func", name, t)
+	}
+	return t, nil
+}
+
+// Call calls name with args converted to the parameter types of its
+// signature. A trailing error result is returned as err, the other
+// results are returned as values.
+func (cm *CodeModule) Call(name string, args ...interface{}) ([]interface{}, error) {
+	fnType, err := cm.FuncType(name)
+	if err != nil {
+		return nil, err
+	}
+	return cm.CallFunc(name, fnType, args...)
+}
+
+// CallFunc is like Call, with the signature of name given by fnType.
+func (cm *CodeModule) CallFunc(name string, fnType reflect.Type, args ...interface{}) ([]interface{}, error) {
+	if !cm.calls.acquire() {
+		return nil, fmt.Errorf("goloader: call %s: module is unloaded", name)
+	}
+	defer cm.calls.release()
+	fn, err := cm.funcValue(name, fnType)
+	if err != nil {
+		return nil, err
+	}
+	return callValue(name, fn, args)
+}
+
+func (cm *CodeModule) funcValue(name string, fnType reflect.Type) (reflect.Value, error) {
+	var fn reflect.Value
+	if fnType.Kind() != reflect.Func {
+		return fn, fmt.Errorf("goloader: %s is not a func type", fnType)
+	}
+	if ptr, ok := cm.Lookup(name); ok && ptr != 0 {
+		closure := funcValue(ptr)
+		fn = reflect.NewAt(fnType, unsafe.Pointer(&closure)).Elem()
+	} else if addr, ok := cm.vars[name]; ok {
+		//package-level variable, holds the func value
+		fn = reflect.NewAt(fnType, adduintptr(addr, 0)).Elem()
+		if fn.IsNil() {
+			return fn, fmt.Errorf("goloader: func variable %s is nil", name)
+		}
+	} else {
+		return fn, fmt.Errorf("goloader: function %s not found", name)
+	}
+	return fn, nil
+}
+
+func callValue(name string, fn reflect.Value, args []interface{}) ([]interface{}, error) {
+	fnType := fn.Type()
+	in, err := marshalArgs(fnType, args)
+	if err != nil {
+		return nil, fmt.Errorf("goloader: call %s: %v", name, err)
+	}
+	out := fn.Call(in)
+
+	results := make([]interface{}, 0, len(out))
+	for i, v := range out {
+		if i == len(out)-1 && isErrorType(fnType.Out(i)) {
+			if !v.IsNil() {
+				err = v.Interface().(error)
+			}
+			break
+		}
+		results = append(results, v.Interface())
+	}
+	return results, err
+}
+
+func marshalArgs(fnType reflect.Type, args []interface{}) ([]reflect.Value, error) {
+	numIn := fnType.NumIn()
+	if fnType.IsVariadic() {
+		if len(args) < numIn-1 {
+			return nil, fmt.Errorf("want at least %d arguments, got %d", numIn-1, len(args))
+		}
+	} else if len(args) != numIn {
+		return nil, fmt.Errorf("want %d arguments, got %d", numIn, len(args))
+	}
+	in := make([]reflect.Value, len(args))
+	for i, arg := range args {
+		var t reflect.Type
+		if fnType.IsVariadic() && i >= numIn-1 {
+			t = fnType.In(numIn - 1).Elem()
+		} else {
+			t = fnType.In(i)
+		}
+		if arg == nil {
+			in[i] = reflect.Zero(t)
+			continue
+		}
+		v := reflect.ValueOf(arg)
+		switch {
+		case v.Type().AssignableTo(t):
+			in[i] = v
+		case v.Type().ConvertibleTo(t):
+			in[i] = v.Convert(t)
+		default:
+			return nil, fmt.Errorf("argument %d: can not use %s as %s", i, v.Type(), t)
+		}
+	}
+	return in, nil
+}
@@ -0,0 +1,261 @@
+package goloader
+
+import (
+	"reflect"
+	"sync"
+)
+
+// calls counts the calls into a module made through wrappers and the
+// references acquired by the host, Unload waits for them to return before
+// unmapping the module.
+type calls struct {
+	lock    sync.Mutex
+	cond    *sync.Cond
+	count   int
+	closed  bool
+	idle    func() error // run once the count drops to zero, see whenIdle
+	wait    []chan error // receive the result of idle
+	idled   bool         // idle returned idleErr
+	idleErr error
+}
+
+func (c *calls) acquire() bool {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.closed {
+		return false
+	}
+	c.count++
+	return true
+}
+
+func (c *calls) release() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.count--
+	if c.count == 0 && c.cond != nil {
+		c.cond.Broadcast()
+	}
+	if c.count == 0 && c.idle != nil {
+		c.runIdle()
+	}
+}
+
+// whenIdle refuses new calls and runs idle once the running ones return,
+// the channel receives the error idle returned and is closed. idle runs
+// once, a later whenIdle receives the result of the first.
+func (c *calls) whenIdle(idle func() error) <-chan error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	result := make(chan error, 1)
+	if c.idled {
+		result <- c.idleErr
+		close(result)
+		return result
+	}
+	c.wait = append(c.wait, result)
+	if len(c.wait) > 1 {
+		//idle is pending or running
+		return result
+	}
+	c.closed, c.idle = true, idle
+	if c.count == 0 {
+		c.runIdle()
+	}
+	return result
+}
+
+// runIdle runs idle in a new goroutine, the caller may still run on a frame
+// of the module, it must not be unmapped under its feet. c.lock is held.
+func (c *calls) runIdle() {
+	idle := c.idle
+	c.idle = nil
+	go func() {
+		err := idle()
+		c.lock.Lock()
+		c.idled, c.idleErr = true, err
+		wait := c.wait
+		c.wait = nil
+		c.lock.Unlock()
+		for _, result := range wait {
+			result <- err
+			close(result)
+		}
+	}()
+}
+
+// drain refuses new calls and waits for the running ones.
+func (c *calls) drain() {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.closed = true
+	if c.cond == nil {
+		c.cond = sync.NewCond(&c.lock)
+	}
+	for c.count > 0 {
+		c.cond.Wait()
+	}
+}
+
+// CallbackRegistry is implemented by the host to receive callbacks of modules.
+type CallbackRegistry interface {
+	Register(cb *Callback) error
+	Unregister(cb *Callback)
+}
+
+// Callback is a module function registered with the host.
+type Callback struct {
+	entry    *entry
+	name     string
+	fn       reflect.Value
+	registry CallbackRegistry
+}
+
+// Name returns the symbol name of the module function.
+func (cb *Callback) Name() string {
+	return cb.name
+}
+
+// Module returns the module the function belongs to, see Swap.
+func (cb *Callback) Module() *CodeModule {
+	cm, _ := cb.entry.target()
+	return cm
+}
+
+// Func returns the wrapper of the module function, it has the type of the
+// module function. Calling it after the module is unloaded panics.
+func (cb *Callback) Func() interface{} {
+	return cb.fn.Interface()
+}
+
+// Call calls the callback, see CodeModule.Call.
+func (cb *Callback) Call(args ...interface{}) ([]interface{}, error) {
+	return callValue(cb.name, cb.fn, args)
+}
+
+// Release unregisters the callback.
+func (cb *Callback) Release() {
+	cm := cb.Module()
+	cm.callbackLock.Lock()
+	_, ok := cm.callbacks[cb]
+	delete(cm.callbacks, cb)
+	cm.callbackLock.Unlock()
+	if ok {
+		cb.registry.Unregister(cb)
+	}
+}
+
+// Bind registers the function name of the module with registry. Calls made
+// through the callback are tracked, Unload unregisters the callbacks of the
+// module and waits for the running calls.
+// If fnType is nil, the signature is taken from FuncType.
+func (cm *CodeModule) Bind(name string, fnType reflect.Type, registry CallbackRegistry) (*Callback, error) {
+	var err error
+	if fnType == nil {
+		if fnType, err = cm.FuncType(name); err != nil {
+			return nil, err
+		}
+	}
+	fn, err := cm.funcValue(name, fnType)
+	if err != nil {
+		return nil, err
+	}
+	e := cm.newEntry(name, fn)
+	cb := &Callback{entry: e, name: name, fn: e.wrapper, registry: registry}
+	if err = registry.Register(cb); err != nil {
+		return nil, err
+	}
+	cm.callbackLock.Lock()
+	if cm.callbacks == nil {
+		cm.callbacks = make(map[*Callback]bool)
+	}
+	cm.callbacks[cb] = true
+	cm.callbackLock.Unlock()
+	return cb, nil
+}
+
+// Func returns a wrapper of the function name of the module, with the type
+// fnType or else the type from FuncType. A call of the wrapper holds a
+// reference to the module, Unload and UnloadWhenIdle wait for it to
+// return, and panics once the module is unloaded. Hand it to the host
+// instead of a raw func value of the module.
+func (cm *CodeModule) Func(name string, fnType reflect.Type) (interface{}, error) {
+	var err error
+	if fnType == nil {
+		if fnType, err = cm.FuncType(name); err != nil {
+			return nil, err
+		}
+	}
+	fn, err := cm.funcValue(name, fnType)
+	if err != nil {
+		return nil, err
+	}
+	return cm.newEntry(name, fn).wrapper.Interface(), nil
+}
+
+// Acquire takes a reference to the module, e.g. while the host holds data
+// structures pointing into it, and reports false if the module is being
+// unloaded. Each successful Acquire is followed by a Release.
+func (cm *CodeModule) Acquire() bool {
+	return cm.calls.acquire()
+}
+
+// Release drops a reference taken by Acquire.
+func (cm *CodeModule) Release() {
+	cm.calls.release()
+}
+
+// UnloadWhenIdle refuses new calls and references and unloads the module
+// once the running calls of wrappers, see Func and Bind, and the references
+// taken by Acquire are released, without blocking the caller. The channel
+// receives nil once the module is unloaded, or the error which kept it
+// mapped, e.g. a patched host function which can not be restored, and is
+// then closed. Goroutines running module code not entered through a
+// wrapper are not counted.
+func (cm *CodeModule) UnloadWhenIdle() <-chan error {
+	return cm.calls.whenIdle(cm.unload)
+}
+
+// releaseCallbacks unregisters all callbacks and waits for the running calls.
+func (cm *CodeModule) releaseCallbacks() {
+	cm.callbackLock.Lock()
+	callbacks := cm.callbacks
+	cm.callbacks = nil
+	cm.callbackLock.Unlock()
+	for cb := range callbacks {
+		cb.registry.Unregister(cb)
+	}
+	cm.calls.drain()
+}
+
+// CallbackMap is a CallbackRegistry keeping the last callback registered
+// for each name.
+type CallbackMap struct {
+	lock      sync.RWMutex
+	callbacks map[string]*Callback
+}
+
+func (m *CallbackMap) Register(cb *Callback) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.callbacks == nil {
+		m.callbacks = make(map[string]*Callback)
+	}
+	m.callbacks[cb.Name()] = cb
+	return nil
+}
+
+func (m *CallbackMap) Unregister(cb *Callback) {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if m.callbacks[cb.Name()] == cb {
+		delete(m.callbacks, cb.Name())
+	}
+}
+
+// Get returns the callback registered for name, or nil.
+func (m *CallbackMap) Get(name string) *Callback {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.callbacks[name]
+}
@@ -0,0 +1,182 @@
+package goloader
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+	"unsafe"
+)
+
+// newTestModule returns a module without code, its functions are func
+// variables of the host added by addTestFunc. It can be unloaded.
+func newTestModule(t *testing.T) *CodeModule {
+	code, err := Mmap(PageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := MmapData(PageSize)
+	if err != nil {
+		Munmap(code)
+		t.Fatal(err)
+	}
+	cm := &CodeModule{
+		Syms:   make(map[string]uintptr),
+		module: &moduledata{typemap: make(map[typeOff]uintptr)},
+		types:  make(map[string]uintptr),
+		vars:   make(map[string]uintptr),
+	}
+	cm.codeByte, cm.dataByte = code, data
+	return cm
+}
+
+// addTestFunc copies the func variable fnPtr points to into the data of cm
+// as its package-level variable name, with the type information the
+// compiler records.
+func addTestFunc(cm *CodeModule, name string, fnPtr interface{}) {
+	typ := reflect.TypeOf(fnPtr).Elem()
+	addr := unsafe.Pointer(&cm.dataByte[len(cm.vars)*PtrSize])
+	reflect.NewAt(typ, addr).Elem().Set(reflect.ValueOf(fnPtr).Elem())
+	cm.vars[name] = uintptr(addr)
+	cm.types[name] = uintptr((*emptyInterface)(unsafe.Pointer(&typ)).word)
+}
+
+// wait returns the result received from result, or fails after a timeout.
+func wait(t *testing.T, result <-chan error) error {
+	select {
+	case err, ok := <-result:
+		if !ok {
+			t.Fatal("result channel closed without a result")
+		}
+		if _, ok := <-result; ok {
+			t.Fatal("result channel received a second result")
+		}
+		return err
+	case <-time.After(10 * time.Second):
+		t.Fatal("idle did not run")
+	}
+	return nil
+}
+
+func TestWhenIdleRunsOnceAtZero(t *testing.T) {
+	const n = 64
+	var c calls
+	for i := 0; i < n; i++ {
+		if !c.acquire() {
+			t.Fatal("acquire failed before whenIdle")
+		}
+	}
+	var runs int32
+	idle := func() error {
+		c.lock.Lock()
+		count := c.count
+		c.lock.Unlock()
+		if count != 0 {
+			t.Errorf("idle ran with %d references", count)
+		}
+		atomic.AddInt32(&runs, 1)
+		return nil
+	}
+
+	var wg sync.WaitGroup
+	var resultLock sync.Mutex
+	results := make([]<-chan error, 0, n)
+	for i := 0; i < n; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			result := c.whenIdle(idle)
+			resultLock.Lock()
+			results = append(results, result)
+			resultLock.Unlock()
+		}()
+		go func() {
+			defer wg.Done()
+			if c.acquire() {
+				c.release()
+			}
+			c.release()
+		}()
+	}
+	wg.Wait()
+	for _, result := range results {
+		if err := wait(t, result); err != nil {
+			t.Errorf("whenIdle: %v", err)
+		}
+	}
+	if runs != 1 {
+		t.Errorf("idle ran %d times, want 1", runs)
+	}
+	if c.acquire() {
+		t.Error("acquire succeeded after whenIdle")
+	}
+	if err := wait(t, c.whenIdle(idle)); err != nil || runs != 1 {
+		t.Errorf("whenIdle after idle returned: %v, %d runs", err, runs)
+	}
+}
+
+func TestWhenIdleWaitsForReferences(t *testing.T) {
+	var c calls
+	c.acquire()
+	result := c.whenIdle(func() error { return nil })
+	select {
+	case <-result:
+		t.Fatal("idle ran while a reference is held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	c.release()
+	if err := wait(t, result); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestWhenIdleError(t *testing.T) {
+	var c calls
+	failed := errors.New("failed")
+	c.acquire()
+	first := c.whenIdle(func() error { return failed })
+	second := c.whenIdle(func() error { return nil })
+	c.release()
+	for _, result := range []<-chan error{first, second, c.whenIdle(nil)} {
+		if err := wait(t, result); err != failed {
+			t.Errorf("whenIdle: %v, want %v", err, failed)
+		}
+	}
+}
+
+func TestUnloadWhenIdle(t *testing.T) {
+	cm := newTestModule(t)
+	const n = 32
+	release := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < n; i++ {
+		if !cm.Acquire() {
+			t.Fatal("Acquire failed before UnloadWhenIdle")
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			<-release
+			cm.Release()
+		}()
+	}
+	result := cm.UnloadWhenIdle()
+	if cm.Acquire() {
+		t.Fatal("Acquire succeeded after UnloadWhenIdle")
+	}
+	select {
+	case <-result:
+		t.Fatal("module unloaded while references are held")
+	case <-time.After(50 * time.Millisecond):
+	}
+	close(release)
+	wg.Wait()
+	if err := wait(t, result); err != nil {
+		t.Fatal(err)
+	}
+	if err := wait(t, cm.UnloadWhenIdle()); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -0,0 +1,59 @@
+package goloader
+
+import (
+	"runtime"
+	"sort"
+)
+
+// Capability is a behavior of go code a module may rely on, supported or
+// not by the go version, GOOS, GOARCH and build tags goloader is built with.
+// Capability names are stable, lowercase and dash separated.
+type Capability struct {
+	Name      string
+	Supported bool
+	Doc       string // what is supported, or why not
+}
+
+const (
+	CapabilityDeferRecover    = "defer-recover"
+	CapabilityGoroutines      = "goroutines"
+	CapabilityReflectNewTypes = "reflect-new-types"
+	CapabilityGenerics        = "generics"
+	CapabilityCgo             = "cgo"
+	CapabilityArch            = "arch"
+)
+
+// Capabilities returns the capabilities of modules loaded by this build of
+// goloader sorted by name, e.g. to turn off workflows relying on a behavior
+// which is not supported instead of loading modules which would crash.
+func Capabilities() []Capability {
+	capabilities := []Capability{
+		{Name: CapabilityDeferRecover, Supported: true, Doc: "defer, panic and recover in module code"},
+		{Name: CapabilityGoroutines, Supported: true, Doc: "goroutines running module code, preempted at calls"},
+		{Name: CapabilityReflectNewTypes, Supported: true, Doc: "reflect on the types defined in a module, unnamed composite types with the typelinks feature"},
+		{Name: CapabilityGenerics, Supported: false, Doc: "type parameters need go1.18 objects, go1.8-1.16 objects are read"},
+		{Name: CapabilityCgo, Supported: cgoSupported, Doc: "C symbols of cgo packages resolved by Dlsym"},
+		{Name: CapabilityArch, Supported: checkArch(runtime.GOARCH) == nil, Doc: "modules built for " + runtime.GOARCH},
+	}
+	if openCodedDefers {
+		capabilities[0].Doc += ", including open-coded defers"
+	}
+	if asyncPreemption {
+		capabilities[1].Doc += " and asynchronously at safe points"
+	}
+	if !cgoSupported {
+		capabilities[4].Doc = "Dlsym needs cgo on linux, darwin or freebsd"
+	}
+	sort.Slice(capabilities, func(i, j int) bool { return capabilities[i].Name < capabilities[j].Name })
+	return capabilities
+}
+
+// HasCapability reports whether the capability name is supported.
+func HasCapability(name string) bool {
+	for _, capability := range Capabilities() {
+		if capability.Name == name {
+			return capability.Supported
+		}
+	}
+	return false
+}
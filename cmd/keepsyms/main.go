@@ -0,0 +1,84 @@
+// Command keepsyms writes a go file for the host keeping the symbols the
+// object files of plugins need, e.g. with go generate:
+//
+//	//go:generate go run github.com/pkujhd/goloader/cmd/keepsyms -out keepsyms.go -o plugin.o:example.com/plugin
+//
+// It also writes an empty assembly file next to it, keepsyms.s, which
+// lets the go file declare the functions it anchors without body.
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/pkujhd/goloader/keepsyms"
+)
+
+type arrayFlags struct {
+	File    []string
+	PkgPath []string
+}
+
+func (i *arrayFlags) String() string {
+	return strings.Join(i.File, ",")
+}
+
+func (i *arrayFlags) Set(value string) error {
+	s := strings.Split(value, ":")
+	i.File = append(i.File, s[0])
+	var path string
+	if len(s) > 1 {
+		path = s[1]
+	}
+	i.PkgPath = append(i.PkgPath, path)
+	return nil
+}
+
+func main() {
+	var files arrayFlags
+	flag.Var(&files, "o", "go object file of a plugin, file:pkgpath")
+	var out = flag.String("out", "keepsyms.go", "go file to write")
+	var pkg = flag.String("pkg", os.Getenv("GOPACKAGE"), "package of the go file, $GOPACKAGE under go generate")
+	var hostPkgs = flag.String("hostpkgs", "", "comma separated packages the host links already, their internal functions are anchored too")
+	flag.Parse()
+	if len(files.File) == 0 {
+		flag.PrintDefaults()
+		os.Exit(2)
+	}
+	if *pkg == "" {
+		*pkg = "main"
+	}
+	var linked []string
+	if *hostPkgs != "" {
+		linked = strings.Split(*hostPkgs, ",")
+	}
+	if err := run(files, linked, *out, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(files arrayFlags, hostPkgs []string, out, pkg string) error {
+	syms, err := keepsyms.ScanFiles(files.File, files.PkgPath, hostPkgs)
+	if err != nil {
+		return err
+	}
+	if err := writeFile(out, func(f *os.File) error { return syms.Generate(f, pkg) }); err != nil {
+		return err
+	}
+	return writeFile(strings.TrimSuffix(out, ".go")+".s", func(f *os.File) error { return keepsyms.GenerateAsm(f) })
+}
+
+func writeFile(name string, write func(f *os.File) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
+	}
+	if err := write(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,140 @@
+// Command selftestgen writes a go file registering the self test modules
+// goloader.Probe runs when it is given no SelfTest, built by the go version
+// running it with the compiler flags deployments use: none, -N -l, -race
+// and -cover. An encoded module is only accepted by the same go version
+// and GOARCH, generate the file with the toolchain building the host, e.g.
+//
+//	//go:generate go run github.com/pkujhd/goloader/cmd/selftestgen -out goloader_selftest.go
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"flag"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+
+	"github.com/pkujhd/goloader"
+	"github.com/pkujhd/goloader/compile"
+)
+
+// fixture allocates across a garbage collection and recovers a panic, the
+// runtime walks its frames and scans its stack
+const fixture = `package main
+
+import "runtime"
+
+var sink []*[64]byte
+
+func Probe() {
+	for i := 0; i < 64; i++ {
+		sink = append(sink, new([64]byte))
+	}
+	runtime.GC()
+	sink = nil
+	defer func() {
+		if recover() == nil {
+			panic("probe: no panic recovered")
+		}
+	}()
+	panic("probe")
+}
+
+func main() {}
+`
+
+type variant struct {
+	name  string
+	flags []string
+	cover bool // instrumented by go tool cover first
+}
+
+var variants = []variant{
+	{name: "default"},
+	{name: "-N -l", flags: []string{"-N", "-l"}},
+	{name: "-race", flags: []string{"-race"}},
+	{name: "-cover", cover: true},
+}
+
+func main() {
+	var out = flag.String("out", "goloader_selftest.go", "go file to write")
+	var pkg = flag.String("pkg", os.Getenv("GOPACKAGE"), "package of the go file, $GOPACKAGE under go generate")
+	flag.Parse()
+	if *pkg == "" {
+		*pkg = "main"
+	}
+	if err := run(*out, *pkg); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(out, pkg string) error {
+	dir, err := ioutil.TempDir("", "selftestgen")
+	if err != nil {
+		return err
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "probe.go")
+	if err := ioutil.WriteFile(src, []byte(fixture), 0644); err != nil {
+		return err
+	}
+	bundles := make([][]byte, len(variants))
+	for i, v := range variants {
+		if bundles[i], err = build(dir, src, v); err != nil {
+			return fmt.Errorf("selftestgen: %s: %v", v.name, err)
+		}
+	}
+
+	f, err := os.Create(out)
+	if err != nil {
+		return err
+	}
+	w := bufio.NewWriter(f)
+	fmt.Fprintf(w, "// Code generated by selftestgen for %s %s. DO NOT EDIT.\n\npackage %s\n\n", runtime.Version(), runtime.GOARCH, pkg)
+	qualifier := "goloader."
+	if pkg == "goloader" {
+		qualifier = ""
+	} else {
+		fmt.Fprintf(w, "import \"github.com/pkujhd/goloader\"\n\n")
+	}
+	fmt.Fprintf(w, "func init() {\n")
+	for i, v := range variants {
+		fmt.Fprintf(w, "\t%sRegisterSelfTest(%q, []byte(%q))\n", qualifier, v.name, bundles[i])
+	}
+	fmt.Fprintf(w, "}\n")
+	if err := w.Flush(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// build compiles the fixture as v and returns the encoded linker.
+func build(dir, src string, v variant) ([]byte, error) {
+	if v.cover {
+		covered := filepath.Join(dir, "cover.go")
+		output, err := exec.Command("go", "tool", "cover", "-mode=set", "-var=goloaderCover", "-o", covered, src).CombinedOutput()
+		if err != nil {
+			return nil, fmt.Errorf("%v: %s", err, bytes.TrimSpace(output))
+		}
+		src = covered
+	}
+	obj := filepath.Join(dir, "probe.o")
+	if err := compile.Compile(obj, []string{src}, compile.Options{Flags: v.flags}); err != nil {
+		return nil, err
+	}
+	linker, err := goloader.ReadObjs([]string{obj}, []string{"main"})
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	if err := linker.Encode(&buf); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,51 @@
+// +build go1.10
+
+package compile
+
+import (
+	"reflect"
+	"testing"
+)
+
+// the functions are not held by variables, Call takes their signatures
+// from the DWARF
+const callSource = `package main
+
+import "errors"
+
+type point struct{ x, y int }
+
+func Add(a, b int) int { return a + b }
+
+func Div(a, b int) (int, error) {
+	if b == 0 {
+		return 0, errors.New("division by zero")
+	}
+	return a / b, nil
+}
+
+func Norm(p point, scale int8) int { return (p.x*p.x + p.y*p.y) * int(scale) }
+`
+
+func TestCallFunctionSignature(t *testing.T) {
+	codeModule := load(t, callSource)
+	defer codeModule.Unload()
+	results, err := codeModule.Call("main.Add", 1, 2)
+	if err != nil || len(results) != 1 || results[0] != 3 {
+		t.Errorf("Add(1, 2) = %v, %v, want [3]", results, err)
+	}
+	results, err = codeModule.Call("main.Div", 7, 2)
+	if err != nil || len(results) != 1 || results[0] != 3 {
+		t.Errorf("Div(7, 2) = %v, %v, want [3]", results, err)
+	}
+	if _, err = codeModule.Call("main.Div", 1, 0); err == nil {
+		t.Error("Div(1, 0) returned no error")
+	}
+	fnType, err := codeModule.FuncType("main.Norm")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if fnType.NumIn() != 2 || fnType.In(0).Kind() != reflect.Struct || fnType.In(1).Kind() != reflect.Int8 {
+		t.Errorf("FuncType(main.Norm) = %s", fnType)
+	}
+}
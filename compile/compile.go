@@ -0,0 +1,245 @@
+// Package compile builds go source files into an object file with the go
+// toolchain and loads it, e.g. for a REPL or a watch mode. Diagnostics of
+// the compiler are returned as Errors with their position.
+package compile
+
+import (
+	"bytes"
+	"fmt"
+	"go/ast"
+	"go/importer"
+	"go/parser"
+	"go/token"
+	"go/types"
+	"io/ioutil"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkujhd/goloader"
+)
+
+// ExternsFile is the name of the externs file of a module, Load records it
+// in the linker if it is next to the first file, see GenerateExterns.
+const ExternsFile = "goloader.externs"
+
+// Error is a diagnostic of the compiler.
+type Error struct {
+	File    string
+	Line    int
+	Column  int // 0 if the compiler does not report it
+	Message string
+}
+
+func (e Error) Error() string {
+	if e.Column > 0 {
+		return fmt.Sprintf("%s:%d:%d: %s", e.File, e.Line, e.Column, e.Message)
+	}
+	return fmt.Sprintf("%s:%d: %s", e.File, e.Line, e.Message)
+}
+
+// Errors are the diagnostics of a failed compilation in the order the
+// compiler reports them.
+type Errors []Error
+
+func (errs Errors) Error() string {
+	lines := make([]string, len(errs))
+	for i, err := range errs {
+		lines[i] = err.Error()
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Options changes how the files are compiled.
+type Options struct {
+	// Go is the go command, "go" if empty.
+	Go string
+	// PkgPath is the import path of the package, "main" if empty.
+	PkgPath string
+	// Flags are passed to go tool compile, e.g. -I for imported packages.
+	Flags []string
+}
+
+// file:line:column: message, the column is reported since go1.10
+var diagnostic = regexp.MustCompile(`^(.+?\.go):(\d+)(?::(\d+))?: (.*)$`)
+
+// ParseErrors parses the diagnostics in the output of the compiler, lines
+// indented by a tab continue the message of the previous diagnostic and
+// the other lines are skipped, e.g. "too many errors".
+func ParseErrors(output []byte) Errors {
+	errs := make(Errors, 0)
+	for _, line := range strings.Split(string(output), "\n") {
+		line = strings.TrimRight(line, "\r")
+		if strings.HasPrefix(line, "\t") && len(errs) > 0 {
+			errs[len(errs)-1].Message += "\n" + line
+			continue
+		}
+		match := diagnostic.FindStringSubmatch(line)
+		if match == nil {
+			continue
+		}
+		e := Error{File: match[1], Message: match[4]}
+		e.Line, _ = strconv.Atoi(match[2])
+		if match[3] != "" {
+			e.Column, _ = strconv.Atoi(match[3])
+		}
+		errs = append(errs, e)
+	}
+	return errs
+}
+
+// Compile compiles files into the object file out. If the compiler fails
+// the error is Errors, or the output of the compiler if it reports no
+// diagnostic.
+func Compile(out string, files []string, options Options) error {
+	goCmd, pkgPath := options.Go, options.PkgPath
+	if goCmd == "" {
+		goCmd = "go"
+	}
+	if pkgPath == "" {
+		pkgPath = "main"
+	}
+	args := append([]string{"tool", "compile", "-p", pkgPath, "-o", out}, options.Flags...)
+	cmd := exec.Command(goCmd, append(args, files...)...)
+	var output bytes.Buffer
+	cmd.Stdout, cmd.Stderr = &output, &output
+	if err := cmd.Run(); err != nil {
+		if errs := ParseErrors(output.Bytes()); len(errs) > 0 {
+			return errs
+		}
+		return fmt.Errorf("compile: %v: %s", err, bytes.TrimSpace(output.Bytes()))
+	}
+	return nil
+}
+
+// Load compiles files and loads the object with the symbols of the host
+// in symPtr, see goloader.RegSymbol.
+func Load(files []string, symPtr map[string]uintptr, options Options) (*goloader.CodeModule, error) {
+	dir, err := ioutil.TempDir("", "goloader")
+	if err != nil {
+		return nil, err
+	}
+	defer os.RemoveAll(dir)
+	out := filepath.Join(dir, "module.o")
+	if err := Compile(out, files, options); err != nil {
+		return nil, err
+	}
+	pkgPath := options.PkgPath
+	if pkgPath == "" {
+		pkgPath = "main"
+	}
+	linker, err := goloader.ReadObjs([]string{out}, []string{pkgPath})
+	if err != nil {
+		return nil, err
+	}
+	if f, err := os.Open(filepath.Join(filepath.Dir(files[0]), ExternsFile)); err == nil {
+		externs, err := goloader.ParseExterns(f)
+		f.Close()
+		if err != nil {
+			return nil, err
+		}
+		linker.SetExterns(externs)
+	}
+	return goloader.Load(linker, symPtr)
+}
+
+// universe types spelled as the DWARF of the host spells them
+var dwarfNames = map[string]string{"byte": "uint8", "rune": "int32", "any": "interface {}"}
+
+var identifier = regexp.MustCompile(`[\pL_][\pL\pN_]*`)
+
+// typeString formats typ with the import paths of its named types.
+func typeString(typ types.Type) string {
+	s := types.TypeString(typ, func(pkg *types.Package) string { return pkg.Path() })
+	return identifier.ReplaceAllStringFunc(s, func(ident string) string {
+		if name, ok := dwarfNames[ident]; ok {
+			return name
+		}
+		return ident
+	})
+}
+
+// GenerateExterns type checks files and returns the functions and the
+// variables of other packages they use, with their signatures and types,
+// e.g. to write the ExternsFile of a module with goloader.WriteExterns.
+// Imported packages are type checked from their source.
+func GenerateExterns(files []string, options Options) ([]goloader.Extern, error) {
+	fset := token.NewFileSet()
+	parsed := make([]*ast.File, 0, len(files))
+	for _, file := range files {
+		f, err := parser.ParseFile(fset, file, nil, 0)
+		if err != nil {
+			return nil, err
+		}
+		parsed = append(parsed, f)
+	}
+	pkgPath := options.PkgPath
+	if pkgPath == "" {
+		pkgPath = "main"
+	}
+	info := &types.Info{Uses: make(map[*ast.Ident]types.Object)}
+	config := types.Config{Importer: importer.ForCompiler(fset, "source", nil)}
+	pkg, err := config.Check(pkgPath, fset, parsed, info)
+	if err != nil {
+		return nil, err
+	}
+	seen := make(map[string]bool)
+	externs := make([]goloader.Extern, 0)
+	for _, obj := range info.Uses {
+		if obj.Pkg() == nil || obj.Pkg() == pkg {
+			continue
+		}
+		var e goloader.Extern
+		switch obj := obj.(type) {
+		case *types.Func:
+			sig := obj.Type().(*types.Signature)
+			name := obj.Pkg().Path() + "." + obj.Name()
+			if recv := sig.Recv(); recv != nil {
+				recvType := recv.Type()
+				if _, ok := recvType.Underlying().(*types.Interface); ok {
+					continue
+				}
+				if ptr, ok := recvType.(*types.Pointer); ok {
+					name = fmt.Sprintf("%s.(*%s).%s", obj.Pkg().Path(), ptr.Elem().(*types.Named).Obj().Name(), obj.Name())
+				} else {
+					name = fmt.Sprintf("%s.%s.%s", obj.Pkg().Path(), recvType.(*types.Named).Obj().Name(), obj.Name())
+				}
+			}
+			e = goloader.Extern{Kind: goloader.ExternFunc, Name: name, Type: signatureString(sig)}
+		case *types.Var:
+			if obj.IsField() || obj.Parent() != obj.Pkg().Scope() {
+				continue
+			}
+			e = goloader.Extern{Kind: goloader.ExternVar, Name: obj.Pkg().Path() + "." + obj.Name(), Type: typeString(obj.Type())}
+		default:
+			continue
+		}
+		if !seen[e.Name] {
+			seen[e.Name] = true
+			externs = append(externs, e)
+		}
+	}
+	sort.Slice(externs, func(i, j int) bool { return externs[i].Name < externs[j].Name })
+	return externs, nil
+}
+
+// signatureString formats sig as an externs file does, the results are
+// always parenthesized.
+func signatureString(sig *types.Signature) string {
+	tuple := func(t *types.Tuple) string {
+		list := make([]string, t.Len())
+		for i := range list {
+			list[i] = typeString(t.At(i).Type())
+		}
+		return "(" + strings.Join(list, ", ") + ")"
+	}
+	s := tuple(sig.Params())
+	if sig.Results().Len() > 0 {
+		s += " " + tuple(sig.Results())
+	}
+	return s
+}
@@ -0,0 +1,67 @@
+package compile
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkujhd/goloader"
+)
+
+// the types of the module have pointers, their descriptors refer to the
+// runtime.gcbits masks of the object
+const pointerSource = `package main
+
+type node struct {
+	next  *node
+	value *int
+}
+
+var list *node
+
+func Sum(n int) int {
+	for i := 0; i < n; i++ {
+		v := i
+		list = &node{next: list, value: &v}
+	}
+	sum := 0
+	for p := list; p != nil; p = p.next {
+		sum += *p.value
+	}
+	return sum
+}
+`
+
+func load(t *testing.T, source string) *goloader.CodeModule {
+	dir, err := ioutil.TempDir("", "goloader")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	src := filepath.Join(dir, "main.go")
+	if err := ioutil.WriteFile(src, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+	symPtr := make(map[string]uintptr)
+	if err := goloader.RegSymbol(symPtr); err != nil {
+		t.Fatal(err)
+	}
+	codeModule, err := Load([]string{src}, symPtr, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return codeModule
+}
+
+func TestLoadPointerTypes(t *testing.T) {
+	codeModule := load(t, pointerSource)
+	defer codeModule.Unload()
+	var sum func(int) int
+	if err := codeModule.LookupFunc("main.Sum", &sum); err != nil {
+		t.Fatal(err)
+	}
+	if s := sum(10); s != 45 {
+		t.Errorf("Sum(10) = %d, want 45", s)
+	}
+}
@@ -18,6 +18,7 @@ const (
 	InvalidOffset      = int(-1)
 	InvalidIndex       = uint32(0xFFFFFFFF)
 	PageSize           = 1 << 12 //4096
+	MaxDataAlign       = 32      //cmd/link Maxalign
 )
 
 const (
@@ -44,6 +45,8 @@ const (
 	TypeDoubleDotPrefix  = "type.."
 	TypePrefix           = "type."
 	ItabPrefix           = "go.itab."
+	GCBitsPrefix         = "runtime.gcbits."
+	CgoSymPrefix         = "_cgo_"
 	StkobjSuffix         = ".stkobj"
 	InlineTreeSuffix     = ".inlinetree"
 	OsStdout             = "os.Stdout"
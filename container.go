@@ -0,0 +1,294 @@
+package goloader
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"io"
+)
+
+// layout of a container:
+//
+//	magic | uint32 length of index | gob(containerIndex) | sections
+//
+// Each section is compressed on its own, the index records where it is,
+// so the metadata of a linker is read without inflating its code, data and
+// pclntable, which Load inflates directly into the mappings of the module.
+const containerMagic = "goloadc1"
+
+const (
+	sectionMeta      = "meta"      // gob(encodedLinker) without the sections below
+	sectionText      = "text"      // gob of the data of the STEXT objsymbols
+	sectionCode      = "code"      // linker.code
+	sectionData      = "data"      // linker.data
+	sectionPclntable = "pclntable" // linker.pclntable
+)
+
+// ContainerSection describes a section of a container.
+type ContainerSection struct {
+	Name    string
+	Offset  int64 // from the end of the index
+	Size    int64 // compressed size
+	RawSize int64
+	Sum     [sha256.Size]byte // of the raw bytes
+}
+
+type containerIndex struct {
+	Header   encodeHeader
+	Hash     string            // Linker.Hash
+	Text     map[string]string // symbolHash of the STEXT objsymbols, see Diff
+	Sections []ContainerSection
+}
+
+// container holds the sections of a linker not inflated yet.
+type container struct {
+	r     io.ReaderAt
+	base  int64 // offset of the first section
+	index containerIndex
+}
+
+// EncodeContainer writes linker to w as a container, OpenContainer reads it
+// back in a process of the same go version and GOARCH.
+func (linker *Linker) EncodeContainer(w io.Writer) error {
+	e, err := linker.encoded()
+	if err != nil {
+		return err
+	}
+	index := containerIndex{Header: newEncodeHeader(), Hash: linker.Hash(), Text: make(map[string]string)}
+	text := make(map[string][]byte)
+	objsyms := make(map[string]*ObjSymbol, len(e.ObjSymbols))
+	for name, objsym := range e.ObjSymbols {
+		if objsym.Kind == STEXT && len(objsym.Data) > 0 {
+			text[name] = objsym.Data
+			index.Text[name] = symbolHash(objsym)
+			stripped := *objsym
+			stripped.Data = nil
+			objsym = &stripped
+		}
+		objsyms[name] = objsym
+	}
+	meta := *e
+	meta.Code, meta.Data, meta.Pclntable, meta.ObjSymbols = nil, nil, nil, objsyms
+
+	var sections bytes.Buffer
+	add := func(name string, raw []byte) error {
+		start := sections.Len()
+		fw, err := flate.NewWriter(&sections, flate.DefaultCompression)
+		if err != nil {
+			return err
+		}
+		if _, err := fw.Write(raw); err != nil {
+			return err
+		}
+		if err := fw.Close(); err != nil {
+			return err
+		}
+		index.Sections = append(index.Sections, ContainerSection{
+			Name:    name,
+			Offset:  int64(start),
+			Size:    int64(sections.Len() - start),
+			RawSize: int64(len(raw)),
+			Sum:     sha256.Sum256(raw),
+		})
+		return nil
+	}
+	for _, section := range []struct {
+		name  string
+		value interface{}
+	}{{sectionMeta, &meta}, {sectionText, text}} {
+		var b bytes.Buffer
+		if err := gob.NewEncoder(&b).Encode(section.value); err != nil {
+			return err
+		}
+		if err := add(section.name, b.Bytes()); err != nil {
+			return err
+		}
+	}
+	if err := add(sectionCode, linker.code); err != nil {
+		return err
+	}
+	if err := add(sectionData, linker.data); err != nil {
+		return err
+	}
+	if err := add(sectionPclntable, linker.pclntable); err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if err := gob.NewEncoder(&b).Encode(&index); err != nil {
+		return err
+	}
+	head := make([]byte, len(containerMagic)+Uint32Size)
+	copy(head, containerMagic)
+	binary.LittleEndian.PutUint32(head[len(containerMagic):], uint32(b.Len()))
+	for _, p := range [][]byte{head, b.Bytes(), sections.Bytes()} {
+		if _, err := w.Write(p); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// OpenContainer reads the index and the metadata of a container written by
+// EncodeContainer. The code, data and pclntable of the linker stay in r
+// until Load inflates them, r must stay readable until then; Verify, the
+// symbols and the references of the linker are available without them.
+func OpenContainer(r io.ReaderAt) (*Linker, error) {
+	head := make([]byte, len(containerMagic)+Uint32Size)
+	if _, err := r.ReadAt(head, 0); err != nil {
+		return nil, err
+	}
+	if string(head[:len(containerMagic)]) != containerMagic {
+		return nil, fmt.Errorf("goloader: not a container, magic %q", head[:len(containerMagic)])
+	}
+	size := int64(binary.LittleEndian.Uint32(head[len(containerMagic):]))
+	c := &container{r: r, base: int64(len(head)) + size}
+	if err := gob.NewDecoder(io.NewSectionReader(r, int64(len(head)), size)).Decode(&c.index); err != nil {
+		return nil, fmt.Errorf("goloader: container index: %v", err)
+	}
+	if err := c.index.Header.check(); err != nil {
+		return nil, err
+	}
+	meta, err := c.inflate(sectionMeta, nil)
+	if err != nil {
+		return nil, err
+	}
+	var e encodedLinker
+	if err := gob.NewDecoder(bytes.NewReader(meta)).Decode(&e); err != nil {
+		return nil, fmt.Errorf("goloader: container section %s: %v", sectionMeta, err)
+	}
+	linker, err := e.linker()
+	if err != nil {
+		return nil, err
+	}
+	linker.container = c
+	return linker, nil
+}
+
+func (c *container) section(name string) (*ContainerSection, error) {
+	for i := range c.index.Sections {
+		if c.index.Sections[i].Name == name {
+			return &c.index.Sections[i], nil
+		}
+	}
+	return nil, fmt.Errorf("goloader: container has no section %s", name)
+}
+
+// inflate inflates the section name into dst, or into a new slice if dst
+// is nil, and checks its sum.
+func (c *container) inflate(name string, dst []byte) ([]byte, error) {
+	section, err := c.section(name)
+	if err != nil {
+		return nil, err
+	}
+	if dst == nil {
+		dst = make([]byte, section.RawSize)
+	}
+	if int64(len(dst)) < section.RawSize {
+		return nil, fmt.Errorf("goloader: container section %s is %d bytes, room for %d", name, section.RawSize, len(dst))
+	}
+	dst = dst[:section.RawSize]
+	fr := flate.NewReader(io.NewSectionReader(c.r, c.base+section.Offset, section.Size))
+	defer fr.Close()
+	if _, err := io.ReadFull(fr, dst); err != nil {
+		return nil, fmt.Errorf("goloader: container section %s: %v", name, err)
+	}
+	if sha256.Sum256(dst) != section.Sum {
+		return nil, fmt.Errorf("goloader: container section %s is corrupt", name)
+	}
+	return dst, nil
+}
+
+// Sections returns the sections of the container linker was opened from,
+// nil if it was not opened from a container or is inflated.
+func (linker *Linker) Sections() []ContainerSection {
+	if linker.container == nil {
+		return nil
+	}
+	return linker.container.index.Sections
+}
+
+// rawSize returns the size of a section of the container, or of the same
+// section of linker if it is inflated.
+func (linker *Linker) rawSize(name string, inflated []byte) int {
+	if linker.container == nil {
+		return len(inflated)
+	}
+	if section, err := linker.container.section(name); err == nil {
+		return int(section.RawSize)
+	}
+	return 0
+}
+
+func (linker *Linker) codeSize() int {
+	return linker.rawSize(sectionCode, linker.code)
+}
+
+func (linker *Linker) dataSize() int {
+	return linker.rawSize(sectionData, linker.data)
+}
+
+// copyCode copies the code of linker to dst, inflating it from the
+// container without keeping a copy.
+func (linker *Linker) copyCode(dst []byte) error {
+	if linker.container == nil {
+		copy(dst, linker.code)
+		return nil
+	}
+	_, err := linker.container.inflate(sectionCode, dst)
+	return err
+}
+
+func (linker *Linker) copyData(dst []byte) error {
+	if linker.container == nil {
+		copy(dst, linker.data)
+		return nil
+	}
+	_, err := linker.container.inflate(sectionData, dst)
+	return err
+}
+
+// inflatePclntable inflates the pclntable of linker, the functions of the
+// module are named from it while relocating.
+func (linker *Linker) inflatePclntable() (err error) {
+	if linker.container != nil && linker.pclntable == nil {
+		linker.pclntable, err = linker.container.inflate(sectionPclntable, nil)
+	}
+	return err
+}
+
+// inflate inflates all the sections of the container linker was opened
+// from into linker, e.g. to encode it again or to diff it.
+func (linker *Linker) inflate() (err error) {
+	c := linker.container
+	if c == nil {
+		return nil
+	}
+	if err = linker.inflatePclntable(); err != nil {
+		return err
+	}
+	if linker.code, err = c.inflate(sectionCode, nil); err != nil {
+		return err
+	}
+	if linker.data, err = c.inflate(sectionData, nil); err != nil {
+		return err
+	}
+	raw, err := c.inflate(sectionText, nil)
+	if err != nil {
+		return err
+	}
+	text := make(map[string][]byte)
+	if err = gob.NewDecoder(bytes.NewReader(raw)).Decode(&text); err != nil {
+		return fmt.Errorf("goloader: container section %s: %v", sectionText, err)
+	}
+	for name, data := range text {
+		if objsym, ok := linker.objsymbolMap[name]; ok {
+			objsym.Data = data
+		}
+	}
+	linker.container = nil
+	return nil
+}
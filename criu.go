@@ -0,0 +1,42 @@
+package goloader
+
+import (
+	"fmt"
+)
+
+// Reregister links the moduledata of every loaded module into the runtime
+// again and checks that the memory of the modules is mapped. Call it after
+// the process is restored by CRIU, before module code runs, e.g. from a
+// signal sent by the post-restore action script. CRIU recreates the
+// mappings of modules at their addresses, Reregister reports the module
+// whose mapping is missing.
+func Reregister() error {
+	modulesLock.Lock()
+	defer modulesLock.Unlock()
+	for m := range modules {
+		md := m.(*moduledata)
+		if err := checkMapped(md.minpc, md.maxpc); err != nil {
+			return fmt.Errorf("goloader: module code 0x%x-0x%x: %v", md.minpc, md.maxpc, err)
+		}
+		if err := checkMapped(md.data, md.edata); err != nil {
+			return fmt.Errorf("goloader: module data 0x%x-0x%x: %v", md.data, md.edata, err)
+		}
+		linked := false
+		for datap := &firstmoduledata; datap != nil; datap = datap.next {
+			if datap == md {
+				linked = true
+				break
+			}
+		}
+		if !linked {
+			md.next = nil
+			datap := &firstmoduledata
+			for datap.next != nil {
+				datap = datap.next
+			}
+			datap.next = md
+		}
+	}
+	modulesinit()
+	return nil
+}
@@ -0,0 +1,40 @@
+// +build linux
+
+package goloader
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// checkMapped reports an error unless [start, end) is mapped in the process.
+func checkMapped(start, end uintptr) error {
+	if start >= end {
+		return nil
+	}
+	f, err := os.Open("/proc/self/maps")
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var low, high uintptr
+		if _, err := fmt.Sscanf(scanner.Text(), "%x-%x", &low, &high); err != nil {
+			continue
+		}
+		if low <= start && start < high {
+			if end <= high {
+				return nil
+			}
+			//the range goes on in the next mapping
+			start = high
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return err
+	}
+	return errors.New("not mapped")
+}
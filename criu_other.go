@@ -0,0 +1,7 @@
+// +build !linux
+
+package goloader
+
+func checkMapped(start, end uintptr) error {
+	return nil
+}
@@ -8,25 +8,42 @@ import (
 	"fmt"
 )
 
+// _addDeferReturn sets the offset of the deferreturn call of a function,
+// after a recovered panic the runtime resumes the frame of a function with
+// open-coded defers there. runtime.deferreturn is a symbol of the host,
+// each relocation to it has its own Sym, so it is matched by name.
 func (linker *Linker) _addDeferReturn(_func *_func) (err error) {
 	funcname := gostringnocopy(&linker.pclntable[_func.nameoff])
-	Func := linker.symMap[funcname].Func
-	if Func != nil && len(Func.FuncData) > _FUNCDATA_OpenCodedDeferInfo {
-		sym := linker.symMap[funcname]
-		for _, r := range sym.Reloc {
-			if r.Sym == linker.symMap[RuntimeDeferReturn] {
-				//../cmd/link/internal/ld/pcln.go:pclntab
-				switch linker.Arch {
-				case sys.Arch386.Name, sys.ArchAMD64.Name:
-					_func.deferreturn = uint32(r.Offset) - uint32(sym.Offset) - 1
-				case sys.ArchARM.Name, sys.ArchARM64.Name:
-					_func.deferreturn = uint32(r.Offset) - uint32(sym.Offset)
-				default:
-					err = fmt.Errorf("not support arch:%s", linker.Arch)
-				}
-				break
+	sym := linker.symMap[funcname]
+	if sym == nil || sym.Func == nil {
+		return nil
+	}
+	for _, r := range sym.Reloc {
+		if r.Sym == nil || r.Sym.Name != RuntimeDeferReturn {
+			continue
+		}
+		//../cmd/link/internal/ld/pcln.go:pclntab
+		switch linker.Arch {
+		case sys.Arch386.Name, sys.ArchAMD64.Name:
+			_func.deferreturn = uint32(r.Offset) - uint32(sym.Offset) - 1
+		case sys.ArchARM.Name, sys.ArchARM64.Name, sys.ArchMIPS64.Name, sys.ArchMIPS64LE.Name:
+			_func.deferreturn = uint32(r.Offset) - uint32(sym.Offset)
+		case archS390X:
+			//the offset of BRASL is in the instruction, 2 bytes after its start
+			_func.deferreturn = uint32(r.Offset) - uint32(sym.Offset) - 2
+		case archRISCV64:
+			//R_CALLRISCV marks the JALR, deferreturn is the AUIPC before it
+			if r.Type != R_CALLRISCV {
+				continue
 			}
+			_func.deferreturn = uint32(r.Offset) - uint32(sym.Offset) - uint32(Uint32Size)
+		default:
+			return fmt.Errorf("not support arch:%s", linker.Arch)
 		}
+		return nil
+	}
+	if len(sym.Func.FuncData) > _FUNCDATA_OpenCodedDeferInfo && sym.Func.FuncData[_FUNCDATA_OpenCodedDeferInfo] != 0 {
+		return fmt.Errorf("function %s has open-coded defers but no call of %s", funcname, RuntimeDeferReturn)
 	}
-	return err
+	return nil
 }
@@ -0,0 +1,61 @@
+package goloader
+
+import (
+	"sort"
+)
+
+// runtime features a module is loaded without when its objects miss the
+// pc tables they need, e.g. objects written by an older builder
+const (
+	// DegradedInlineTraceback: a function has an inline tree but no
+	// PCDATA_InlTreeIndex table, tracebacks and runtime.Callers do not
+	// show the functions inlined into it.
+	DegradedInlineTraceback = "inline-traceback"
+	// DegradedSourcePositions: a function has no pcfile or pcln table,
+	// tracebacks and runtime.FuncForPC report its file and line as "?" and 0.
+	DegradedSourcePositions = "source-positions"
+)
+
+// ModuleInfo describes a loaded module.
+type ModuleInfo struct {
+	Hash     string // see Linker.Hash
+	Funcs    int
+	CodeSize int
+	DataSize int
+	// Degraded maps each degraded runtime feature to the sorted names of
+	// the functions it is degraded for, it is empty if the objects have
+	// every pc table.
+	Degraded map[string][]string
+}
+
+// Info returns a description of the module and of the runtime features
+// degraded because of missing pc tables.
+func (cm *CodeModule) Info() ModuleInfo {
+	info := ModuleInfo{
+		Hash:     cm.hash,
+		Funcs:    len(cm.module.ftab) - 1,
+		CodeSize: cm.codeLen,
+		DataSize: cm.dataLen,
+		Degraded: make(map[string][]string),
+	}
+	for feature, funcs := range cm.degraded {
+		info.Degraded[feature] = append([]string(nil), funcs...)
+	}
+	return info
+}
+
+// degrade records that feature is degraded for the function funcname.
+func (linker *Linker) degrade(feature, funcname string) {
+	if linker.degraded == nil {
+		linker.degraded = make(map[string][]string)
+	}
+	funcs := linker.degraded[feature]
+	i := sort.SearchStrings(funcs, funcname)
+	if i < len(funcs) && funcs[i] == funcname {
+		return
+	}
+	funcs = append(funcs, EmptyString)
+	copy(funcs[i+1:], funcs[i:])
+	funcs[i] = funcname
+	linker.degraded[feature] = funcs
+}
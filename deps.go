@@ -0,0 +1,212 @@
+package goloader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// Resolver locates the object files of the packages imported by a module,
+// see ResolveObjs.
+type Resolver struct {
+	// Dirs are searched for <dir>/<import path>.a and .o, e.g. a directory
+	// of objects built by go build -o or $GOPATH/pkg/$GOOS_$GOARCH.
+	Dirs []string
+	// Go is the go command which locates the packages not in Dirs in its
+	// build cache with go list -export, "go" if empty. "-" disables it.
+	Go string
+	// HostSymbols are the symbols of the host, see RegSymbol. A package the
+	// host links a symbol of is resolved by the host and not loaded.
+	HostSymbols map[string]uintptr
+	// HostPackages are import paths resolved by the host, as
+	// ReadOptions.HostPackages, in addition to the runtime packages.
+	HostPackages []string
+}
+
+// objImports returns the sorted packages of the symbols pkg refers to,
+// other than its own.
+func objImports(pkg *Pkg) []string {
+	seen := make(map[string]bool)
+	for _, sym := range pkg.Syms {
+		for _, loc := range sym.Reloc {
+			name := strings.Replace(loc.Sym.Name, EmptyPkgPath, pkg.PkgPath, -1)
+			if path := symbolPkg(name); path != EmptyString && path != pkg.PkgPath {
+				seen[path] = true
+			}
+		}
+	}
+	imports := make([]string, 0, len(seen))
+	for path := range seen {
+		imports = append(imports, path)
+	}
+	sort.Strings(imports)
+	return imports
+}
+
+// hostPackages returns the packages of the host symbols.
+func (r *Resolver) hostPackages() map[string]bool {
+	pkgs := make(map[string]bool)
+	for name := range r.HostSymbols {
+		if path := symbolPkg(name); path != EmptyString {
+			pkgs[path] = true
+		}
+	}
+	return pkgs
+}
+
+func (r *Resolver) isHost(path string, host map[string]bool) bool {
+	if host[path] {
+		return true
+	}
+	for _, patterns := range [][]string{hostPkgs, r.HostPackages} {
+		for _, pattern := range patterns {
+			if matchPkg(path, pattern) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// find returns the object file of the package path in Dirs, or "".
+func (r *Resolver) find(path string) string {
+	for _, dir := range r.Dirs {
+		for _, ext := range []string{".a", ".o"} {
+			file := filepath.Join(dir, filepath.FromSlash(path)+ext)
+			if fi, err := os.Stat(file); err == nil && !fi.IsDir() {
+				return file
+			}
+		}
+	}
+	return EmptyString
+}
+
+// goList returns the export files of paths in the build cache of the go
+// command, go list builds them if they are missing.
+func (r *Resolver) goList(paths []string) (map[string]string, error) {
+	goCmd := r.Go
+	if goCmd == EmptyString {
+		goCmd = "go"
+	}
+	args := append([]string{"list", "-export", "-f", "{{.ImportPath}} {{.Export}}"}, paths...)
+	var stdout, stderr bytes.Buffer
+	cmd := exec.Command(goCmd, args...)
+	cmd.Stdout, cmd.Stderr = &stdout, &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("goloader: go list: %v: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	exports := make(map[string]string)
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		fields := strings.SplitN(scanner.Text(), " ", 2)
+		if len(fields) == 2 && fields[1] != EmptyString {
+			exports[fields[0]] = fields[1]
+		}
+	}
+	return exports, scanner.Err()
+}
+
+// ResolveObjs returns files and the object files of the packages they
+// import transitively, each dependency before the packages importing it,
+// with their package paths, ready for ReadObjs. The packages resolved by
+// the host are left out. The imports of an object are the packages of the
+// symbols it refers to, its objects are read once more by ReadObjs.
+func ResolveObjs(files []string, pkgPaths []string, resolver Resolver) ([]string, []string, error) {
+	if len(files) != len(pkgPaths) {
+		return nil, nil, fmt.Errorf("goloader: %d files and %d package paths", len(files), len(pkgPaths))
+	}
+	host := resolver.hostPackages()
+	located := make(map[string]string)
+	for i, path := range pkgPaths {
+		located[path] = files[i]
+	}
+	imports := make(map[string][]string)
+	// read the objects of the packages located, then locate their imports
+	pending := append([]string(nil), pkgPaths...)
+	for len(pending) > 0 {
+		missing := make([]string, 0)
+		for _, path := range pending {
+			f, err := os.Open(located[path])
+			if err != nil {
+				return nil, nil, err
+			}
+			pkg, err := ParseObj(f, located[path], path)
+			f.Close()
+			if err != nil {
+				return nil, nil, err
+			}
+			imports[path] = objImports(pkg)
+			for _, imported := range imports[path] {
+				if _, ok := located[imported]; ok || resolver.isHost(imported, host) {
+					continue
+				}
+				located[imported] = resolver.find(imported)
+				if located[imported] == EmptyString {
+					missing = append(missing, imported)
+				}
+			}
+		}
+		if len(missing) > 0 {
+			if resolver.Go == "-" {
+				return nil, nil, fmt.Errorf("goloader: no object file for %s in %s", strings.Join(missing, ", "), strings.Join(resolver.Dirs, ", "))
+			}
+			exports, err := resolver.goList(missing)
+			if err != nil {
+				return nil, nil, err
+			}
+			for _, path := range missing {
+				if exports[path] == EmptyString {
+					return nil, nil, fmt.Errorf("goloader: no object file for %s", path)
+				}
+				located[path] = exports[path]
+			}
+		}
+		pending = pending[:0]
+		for path := range located {
+			if _, ok := imports[path]; !ok {
+				pending = append(pending, path)
+			}
+		}
+		sort.Strings(pending)
+	}
+
+	// dependencies first, as the go linker orders the init tasks
+	var allFiles, allPaths []string
+	visited := make(map[string]bool)
+	var visit func(path string)
+	visit = func(path string) {
+		if visited[path] {
+			return
+		}
+		visited[path] = true
+		for _, imported := range imports[path] {
+			if _, ok := located[imported]; ok {
+				visit(imported)
+			}
+		}
+		allFiles = append(allFiles, located[path])
+		allPaths = append(allPaths, path)
+	}
+	for _, path := range pkgPaths {
+		visit(path)
+	}
+	return allFiles, allPaths, nil
+}
+
+// ReadObjsWithDeps is like ReadObjsWithOptions, the object files of the
+// packages imported by files are located by resolver and read too.
+func ReadObjsWithDeps(files []string, pkgPaths []string, resolver Resolver, options ReadOptions) (*Linker, error) {
+	if resolver.HostPackages == nil {
+		resolver.HostPackages = options.HostPackages
+	}
+	allFiles, allPaths, err := ResolveObjs(files, pkgPaths, resolver)
+	if err != nil {
+		return nil, err
+	}
+	return ReadObjsWithOptions(allFiles, allPaths, options)
+}
@@ -0,0 +1,146 @@
+package goloader
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+)
+
+// SymbolDiff is a function or a type added, removed or changed between
+// two linkers, OldHash and NewHash are the content hashes of the symbol
+// in each, "" if it is not there.
+type SymbolDiff struct {
+	Name    string
+	Type    bool // a type descriptor, not a function
+	OldHash string
+	NewHash string
+}
+
+// BundleDiff is the difference between the functions and the types of two
+// linkers, e.g. two bundles written by Linker.Encode, each list sorted by name.
+type BundleDiff struct {
+	Added   []SymbolDiff
+	Removed []SymbolDiff
+	Changed []SymbolDiff
+}
+
+// Empty reports whether the linkers have the same functions and types.
+func (diff *BundleDiff) Empty() bool {
+	return len(diff.Added) == 0 && len(diff.Removed) == 0 && len(diff.Changed) == 0
+}
+
+// Diff compares the functions and the types of two linkers by the hash of
+// their content, the bytes of a symbol and its relocations, so a function
+// calling another function or a type with another method changes even if
+// its bytes are the same, e.g. to review what a hot patch changes.
+func Diff(old, patched *Linker) *BundleDiff {
+	oldHashes, newHashes := symbolHashes(old), symbolHashes(patched)
+	diff := &BundleDiff{}
+	for name, hash := range newHashes {
+		oldHash, ok := oldHashes[name]
+		d := SymbolDiff{Name: name, Type: strings.HasPrefix(name, TypePrefix), OldHash: oldHash, NewHash: hash}
+		if !ok {
+			diff.Added = append(diff.Added, d)
+		} else if oldHash != hash {
+			diff.Changed = append(diff.Changed, d)
+		}
+	}
+	for name, hash := range oldHashes {
+		if _, ok := newHashes[name]; !ok {
+			diff.Removed = append(diff.Removed, SymbolDiff{Name: name, Type: strings.HasPrefix(name, TypePrefix), OldHash: hash})
+		}
+	}
+	for _, list := range [][]SymbolDiff{diff.Added, diff.Removed, diff.Changed} {
+		sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	}
+	return diff
+}
+
+// symbolHashes returns the content hash of each function and type symbol
+// of the objects read by linker.
+func symbolHashes(linker *Linker) map[string]string {
+	hashes := make(map[string]string)
+	for name, objsym := range linker.objsymbolMap {
+		isType := strings.HasPrefix(name, TypePrefix) && !strings.HasPrefix(name, TypeDoubleDotPrefix)
+		if objsym.Kind != STEXT && !isType {
+			continue
+		}
+		if c := linker.container; c != nil && objsym.Kind == STEXT {
+			//the bytes of the functions are still in the container
+			if hash, ok := c.index.Text[name]; ok {
+				hashes[name] = hash
+				continue
+			}
+		}
+		hashes[name] = symbolHash(objsym)
+	}
+	return hashes
+}
+
+// symbolHash hashes the bytes and the relocations of a symbol, relocations
+// are sorted by offset and hashed with the name of their target.
+func symbolHash(objsym *ObjSymbol) string {
+	h := sha256.New()
+	buf := make([]byte, 8)
+	putInt := func(v int) {
+		binary.LittleEndian.PutUint64(buf, uint64(v))
+		h.Write(buf)
+	}
+	putInt(objsym.Kind)
+	putInt(len(objsym.Data))
+	h.Write(objsym.Data)
+	relocs := make([]Reloc, len(objsym.Reloc))
+	copy(relocs, objsym.Reloc)
+	sort.Slice(relocs, func(i, j int) bool { return relocs[i].Offset < relocs[j].Offset })
+	for _, loc := range relocs {
+		putInt(loc.Offset)
+		putInt(loc.Size)
+		putInt(loc.Type)
+		putInt(loc.Add)
+		if loc.Sym != nil {
+			putInt(len(loc.Sym.Name))
+			h.Write([]byte(loc.Sym.Name))
+		} else {
+			putInt(0)
+		}
+	}
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// Print writes the added, removed and changed symbols one per line,
+// prefixed with +, - and ~, and a summary line.
+func (diff *BundleDiff) Print(w io.Writer) error {
+	kind := func(d SymbolDiff) string {
+		if d.Type {
+			return "type"
+		}
+		return "func"
+	}
+	short := func(hash string) string {
+		if len(hash) > 12 {
+			return hash[:12]
+		}
+		return hash
+	}
+	for _, d := range diff.Added {
+		if _, err := fmt.Fprintf(w, "+ %s %s %s\n", kind(d), d.Name, short(d.NewHash)); err != nil {
+			return err
+		}
+	}
+	for _, d := range diff.Removed {
+		if _, err := fmt.Fprintf(w, "- %s %s %s\n", kind(d), d.Name, short(d.OldHash)); err != nil {
+			return err
+		}
+	}
+	for _, d := range diff.Changed {
+		if _, err := fmt.Fprintf(w, "~ %s %s %s -> %s\n", kind(d), d.Name, short(d.OldHash), short(d.NewHash)); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(w, "%d added, %d removed, %d changed\n", len(diff.Added), len(diff.Removed), len(diff.Changed))
+	return err
+}
@@ -0,0 +1,11 @@
+package goloader
+
+// resolveSymbol resolves an external symbol missing in the symbols of the
+// host with LoadOptions.ResolveSymbol.
+func (cm *CodeModule) resolveSymbol(name string) (uintptr, bool) {
+	if cm.options.ResolveSymbol == nil {
+		return 0, false
+	}
+	ptr, ok := cm.options.ResolveSymbol(name)
+	return ptr, ok && ptr != 0
+}
@@ -0,0 +1,72 @@
+// +build cgo
+// +build linux darwin freebsd
+
+package goloader
+
+/*
+#cgo linux LDFLAGS: -ldl
+#include <dlfcn.h>
+#include <stdlib.h>
+
+static void *goloader_dlsym(const char *name) {
+	return dlsym(RTLD_DEFAULT, name);
+}
+*/
+import "C"
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// cgoSupported reports whether the C symbols of cgo packages can be resolved.
+const cgoSupported = true
+
+// Dlsym looks name up in the dynamic symbol table of the process and the
+// libraries it loaded, it resolves the _cgo_ and C symbols of modules
+// built from cgo packages, use it as LoadOptions.ResolveSymbol. The C
+// symbols of the host are only exported if it is linked with -rdynamic.
+func Dlsym(name string) (uintptr, bool) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	ptr := uintptr(C.goloader_dlsym(cname))
+	return ptr, ptr != 0
+}
+
+// Library is a shared library opened with Dlopen.
+type Library struct {
+	path   string
+	handle unsafe.Pointer
+}
+
+// Dlopen opens the shared library path with RTLD_NOW|RTLD_GLOBAL.
+func Dlopen(path string) (*Library, error) {
+	cpath := C.CString(path)
+	defer C.free(unsafe.Pointer(cpath))
+	handle := C.dlopen(cpath, C.RTLD_NOW|C.RTLD_GLOBAL)
+	if handle == nil {
+		return nil, fmt.Errorf("goloader: dlopen %s: %s", path, C.GoString(C.dlerror()))
+	}
+	return &Library{path: path, handle: handle}, nil
+}
+
+// Path returns the path lib was opened with.
+func (lib *Library) Path() string {
+	return lib.path
+}
+
+// Sym looks name up in the exports of lib and its dependencies.
+func (lib *Library) Sym(name string) (uintptr, bool) {
+	cname := C.CString(name)
+	defer C.free(unsafe.Pointer(cname))
+	ptr := uintptr(C.dlsym(lib.handle, cname))
+	return ptr, ptr != 0
+}
+
+// Close closes lib, its functions must not be called after.
+func (lib *Library) Close() error {
+	if C.dlclose(lib.handle) != 0 {
+		return fmt.Errorf("goloader: dlclose %s: %s", lib.path, C.GoString(C.dlerror()))
+	}
+	return nil
+}
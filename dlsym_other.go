@@ -0,0 +1,41 @@
+// +build !cgo !linux,!darwin,!freebsd
+
+package goloader
+
+import (
+	"fmt"
+	"runtime"
+)
+
+// cgoSupported reports whether the C symbols of cgo packages can be resolved.
+const cgoSupported = false
+
+// Dlsym needs cgo on linux, darwin or freebsd, otherwise it resolves nothing.
+func Dlsym(name string) (uintptr, bool) {
+	return 0, false
+}
+
+// Library is a shared library opened with Dlopen.
+type Library struct {
+	path string
+}
+
+// Dlopen needs cgo on linux, darwin or freebsd, otherwise it fails.
+func Dlopen(path string) (*Library, error) {
+	return nil, fmt.Errorf("goloader: dlopen %s: not supported without cgo on %s", path, runtime.GOOS)
+}
+
+// Path returns the path lib was opened with.
+func (lib *Library) Path() string {
+	return lib.path
+}
+
+// Sym looks name up in the exports of lib and its dependencies.
+func (lib *Library) Sym(name string) (uintptr, bool) {
+	return 0, false
+}
+
+// Close closes lib, its functions must not be called after.
+func (lib *Library) Close() error {
+	return nil
+}
@@ -0,0 +1,58 @@
+package goloader
+
+// DryRunReport is what DryRun found about loading a linker.
+type DryRunReport struct {
+	Check error      // first failing check Load runs before mapping anything
+	Link  *LinkError // unresolved symbols and failing relocations, nil if all linked
+	// CodeBytes and DataBytes are the sizes of the mappings Load makes, the
+	// code with the worst case trampoline space; TrampolineBytes the
+	// trampolines written when relocating in heap memory.
+	CodeBytes       int
+	DataBytes       int
+	TrampolineBytes int
+	PclntableBytes  int
+	SharedDataBytes int // shared tables the module maps if no loaded module did
+	RelocStats      RelocStats
+}
+
+// Err returns why the linker would not load, nil if it would.
+func (report *DryRunReport) Err() error {
+	if report.Check != nil {
+		return report.Check
+	}
+	if report.Link != nil {
+		return report.Link
+	}
+	return nil
+}
+
+// DryRun checks that linker loads against symPtr with options without
+// mapping memory, registering anything to the runtime or running code: it
+// runs the checks of Load and relocates the module in heap memory, so all
+// missing symbols and unsupported relocations are reported together, e.g.
+// in CI against a symbol manifest of the host. The error is non-nil only
+// if the dry run itself fails, report.Err tells whether Load would fail.
+func DryRun(linker *Linker, symPtr map[string]uintptr, options LoadOptions) (*DryRunReport, error) {
+	if err := checkFeatures(options.Features); err != nil {
+		return nil, err
+	}
+	report := &DryRunReport{Check: linker.checkLoad(symPtr, options)}
+	codeModule, err := linker.dryRun(symPtr, options)
+	if codeModule == nil {
+		return nil, err
+	}
+	if link, ok := err.(*LinkError); ok {
+		report.Link = link
+	} else if err != nil {
+		return nil, err
+	}
+	report.CodeBytes = codeModule.maxLength
+	report.DataBytes = len(codeModule.dataByte)
+	report.TrampolineBytes = codeModule.relocStats.TrampolineBytes
+	report.PclntableBytes = len(linker.pclntable)
+	for _, name := range linker.SharedData() {
+		report.SharedDataBytes += alignof(len(linker.objsymbolMap[name].Data), PageSize)
+	}
+	report.RelocStats = codeModule.relocStats
+	return report, nil
+}
@@ -0,0 +1,134 @@
+package goloader
+
+import (
+	"sort"
+	"strings"
+)
+
+// R_DWARFSECREF resolves to the offset of the target symbol in its DWARF
+// section, it follows R_METHODOFF, R_POWER_TOC, R_GOTPCREL and R_JMPMIPS
+// in every supported go version.
+const R_DWARFSECREF = R_METHODOFF + 4
+
+// R_DWARFFILEREF resolves to the index of a file symbol in the file table of
+// the line program, it follows R_DWARFSECREF.
+const R_DWARFFILEREF = R_DWARFSECREF + 1
+
+// DWARFSymbol is a DWARF symbol of a module at Offset in Section.
+type DWARFSymbol struct {
+	Name    string
+	Section string
+	Offset  int
+}
+
+type moduleDWARF struct {
+	sections map[string][]byte
+	symbols  []DWARFSymbol
+}
+
+// cuStarts returns the address of the first function of each compile unit,
+// a package of the module, by package path.
+func (linker *Linker) cuStarts(symbolMap map[string]uintptr) map[string]uintptr {
+	starts := make(map[string]uintptr)
+	for name, sym := range linker.symMap {
+		addr, ok := symbolMap[name]
+		if sym.Kind != STEXT || sym.Offset == InvalidOffset || !ok {
+			continue
+		}
+		pkg := symbolPkg(name)
+		if start, ok := starts[pkg]; !ok || addr < start {
+			starts[pkg] = addr
+		}
+	}
+	return starts
+}
+
+// relocateDWARF lays out the DWARF symbols of the objects in their sections
+// and relocates the addresses of functions and variables, the references
+// between DWARF symbols, the offsets of functions in their compile unit and
+// the indexes of files, which are the files of the module numbered from 1.
+func (linker *Linker) relocateDWARF(codeModule *CodeModule, symbolMap map[string]uintptr) {
+	names := make([]string, 0)
+	for name, objsym := range linker.objsymbolMap {
+		if _, ok := dwarfSections[objsym.Kind]; ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	dwarf := &moduleDWARF{sections: make(map[string][]byte)}
+	offsets := make(map[string]int)
+	for _, name := range names {
+		objsym := linker.objsymbolMap[name]
+		section := dwarfSections[objsym.Kind]
+		offsets[name] = len(dwarf.sections[section])
+		dwarf.symbols = append(dwarf.symbols, DWARFSymbol{Name: name, Section: section, Offset: offsets[name]})
+		dwarf.sections[section] = append(dwarf.sections[section], objsym.Data...)
+	}
+	cuStarts := linker.cuStarts(symbolMap)
+	for _, name := range names {
+		objsym := linker.objsymbolMap[name]
+		data := dwarf.sections[dwarfSections[objsym.Kind]][offsets[name]:]
+		for _, loc := range objsym.Reloc {
+			if loc.Offset+loc.Size > len(objsym.Data) {
+				continue
+			}
+			var value uint64
+			switch loc.Type {
+			case R_ADDR:
+				addr, ok := symbolMap[loc.Sym.Name]
+				if !ok || addr == InvalidHandleValue {
+					continue
+				}
+				value = uint64(int(addr) + loc.Add)
+			case R_DWARFSECREF:
+				offset, ok := offsets[loc.Sym.Name]
+				if !ok {
+					continue
+				}
+				value = uint64(offset + loc.Add)
+			case R_ADDRCUOFF:
+				addr, ok := symbolMap[loc.Sym.Name]
+				start, found := cuStarts[symbolPkg(loc.Sym.Name)]
+				if !ok || !found || addr == InvalidHandleValue {
+					continue
+				}
+				value = uint64(int(addr) + loc.Add - int(start))
+			case R_DWARFFILEREF:
+				index, ok := linker.fileIndex[strings.TrimPrefix(loc.Sym.Name, FileSymPrefix)]
+				if !ok {
+					continue
+				}
+				value = uint64(int(index) + 1 + loc.Add)
+			default:
+				continue
+			}
+			switch loc.Size {
+			case Uint32Size:
+				byteOrder.PutUint32(data[loc.Offset:], uint32(value))
+			case UInt64Size:
+				byteOrder.PutUint64(data[loc.Offset:], value)
+			}
+		}
+	}
+	codeModule.dwarf = dwarf
+}
+
+// DWARF returns the relocated DWARF sections of a module loaded with
+// LoadOptions.KeepDWARF by section name, e.g. ".debug_info". They hold the
+// DWARF emitted by the compiler, the compile unit headers and the line
+// programs written by the go linker are not generated.
+func (cm *CodeModule) DWARF() map[string][]byte {
+	if cm.dwarf == nil {
+		return nil
+	}
+	return cm.dwarf.sections
+}
+
+// DWARFSymbols returns where the DWARF symbols of the module are in the
+// sections returned by DWARF.
+func (cm *CodeModule) DWARFSymbols() []DWARFSymbol {
+	if cm.dwarf == nil {
+		return nil
+	}
+	return cm.dwarf.symbols
+}
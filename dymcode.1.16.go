@@ -8,12 +8,19 @@ import (
 )
 
 const (
-	R_PCREL = 16
+	// R_CALLRISCV marks RISC-V CALLs for stack checking.
+	R_CALLRISCV = 14
+	R_PCREL     = 16
 	// R_TLS_LE, used on 386, amd64, and ARM, resolves to the offset of the
 	// thread-local symbol from the thread local base and is used to implement the
 	// "local exec" model for tls access (r.Sym is not set on intel platforms but is
 	// set to a TLS symbol -- runtime.tlsg -- in the linker when externally linking).
 	R_TLS_LE = 17
+	// R_USEFIELD and R_USETYPE are marker relocations (0-sized): a field is
+	// used by the function, for field tracking, and a type is used, when
+	// linking dynamically.
+	R_USEFIELD = 23
+	R_USETYPE  = 24
 	// R_USEIFACE marks a type is converted to an interface in the function this
 	// relocation is applied to. The target is a type descriptor.
 	// This is a marker relocation (0-sized), for the linker's reachabililty
@@ -31,9 +38,40 @@ const (
 	// *rtype, and may be set to zero by the linker if it determines the method
 	// text is unreachable by the linked program.
 	R_METHODOFF = 27
+	// R_GOTPCREL is a PC-relative offset to the GOT entry holding the address
+	// of the symbol.
+	R_GOTPCREL = 29
+	// R_ARM64_GOTPCREL relocates an adrp, ld64 pair to compute the address of
+	// the GOT entry of the symbol.
+	R_ARM64_GOTPCREL = 35
+	// R_JMPMIPS (only used on mips64) resolves to non-PC-relative target address
+	// of a JMP instruction, by encoding the address into the instruction.
+	// The stack nosplit check ignores this since it is not a function call.
+	R_JMPMIPS = 30
+	// R_RISCV_PCREL_ITYPE resolves a 32-bit PC-relative address using an
+	// AUIPC + I-type instruction pair.
+	R_RISCV_PCREL_ITYPE = 51
+	// R_RISCV_PCREL_STYPE resolves a 32-bit PC-relative address using an
+	// AUIPC + S-type instruction pair.
+	R_RISCV_PCREL_STYPE = 52
+	// R_PCRELDBL relocates s390x 2-byte aligned addresses.
+	// It is used for 32-bit PC-relative data address loads (LARL).
+	R_PCRELDBL = 55
+	// R_ADDRMIPSU (only used on mips/mips64) resolves to the sign-adjusted "upper" 16
+	// bits (bit 16-31) of an external address, by encoding it into the instruction.
+	R_ADDRMIPSU = 56
 	// R_ADDRCUOFF resolves to a pointer-sized offset from the start of the
 	// symbol's DWARF compile unit.
 	R_ADDRCUOFF = 58
+
+	//not used, only adapter golang 1.17
+	R_KEEP = 0x10000000 - 10
+)
+
+// open-coded defers and asynchronous preemption of goroutines, since go1.14
+const (
+	openCodedDefers = true
+	asyncPreemption = true
 )
 
 // copy from $GOROOT/src/cmd/internal/objabi/symkind.go
@@ -77,6 +115,19 @@ const (
 
 )
 
+// section of the DWARF symbol kinds
+var dwarfSections = map[int]string{
+	SDWARFCUINFO: ".debug_info",
+	SDWARFCONST:  ".debug_info",
+	SDWARFFCN:    ".debug_info",
+	SDWARFABSFCN: ".debug_info",
+	SDWARFTYPE:   ".debug_info",
+	SDWARFVAR:    ".debug_info",
+	SDWARFRANGE:  ".debug_ranges",
+	SDWARFLOC:    ".debug_loc",
+	SDWARFLINES:  ".debug_line",
+}
+
 func (linker *Linker) addStackObject(funcname string, symbolMap map[string]uintptr) (err error) {
 	return linker._addStackObject(funcname, symbolMap)
 }
@@ -100,7 +151,7 @@ func (linker *Linker) initInlinedCall(inl InlTreeNode, _func *_func) inlinedCall
 	inlname := inl.Func
 	return inlinedCall{
 		parent:   int16(inl.Parent),
-		funcID:   _func.funcID,
+		funcID:   linker.inlinedFuncID(inl),
 		file:     findFileTab(linker, inl.File),
 		line:     int32(inl.Line),
 		func_:    int32(linker.namemap[inlname]),
@@ -111,11 +162,22 @@ func (linker *Linker) addInlineTree(_func *_func, objsym *ObjSymbol) (err error)
 	return linker._addInlineTree(_func, objsym)
 }
 
+// the runtime of go1.16 reads funcnametab, cutab, filetab and pctab with
+// the offsets kept in _func, the linker emits them as separate tables and
+// records their offsets in pcHeader. goloader keeps names, files and pc
+// values in one pclntable, so every table is pclntable itself and all the
+// offsets from pcHeader are 0, except cutab which is the filetab of linker.
 func (linker *Linker) _buildModule(codeModule *CodeModule) {
 	module := codeModule.module
 	module.pcHeader = (*pcHeader)(unsafe.Pointer(&(module.pclntable[0])))
-	module.pcHeader.nfunc = len(module.ftab)
-	module.pcHeader.nfiles = (uint)(len(module.filetab))
+	//ftab has a sentinel entry after the last function
+	module.pcHeader.nfunc = len(module.ftab) - 1
+	module.pcHeader.nfiles = (uint)(len(linker.filetab))
+	module.pcHeader.funcnameOffset = 0
+	module.pcHeader.cuOffset = 0
+	module.pcHeader.filetabOffset = 0
+	module.pcHeader.pctabOffset = 0
+	module.pcHeader.pclnOffset = 0
 	module.funcnametab = module.pclntable
 	module.pctab = module.pclntable
 	module.cutab = linker.filetab
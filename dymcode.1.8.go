@@ -14,6 +14,11 @@ const (
 	// "local exec" model for tls access (r.Sym is not set on intel platforms but is
 	// set to a TLS symbol -- runtime.tlsg -- in the linker when externally linking).
 	R_TLS_LE = 16
+	// R_USEFIELD and R_USETYPE are marker relocations (0-sized): a field is
+	// used by the function, for field tracking, and a type is used, when
+	// linking dynamically.
+	R_USEFIELD = 22
+	R_USETYPE  = 23
 	// R_METHODOFF resolves to a 32-bit offset from the beginning of the section
 	// holding the data being relocated to the referenced symbol.
 	// It is a variant of R_ADDROFF used when linking from the uncommonType of a
@@ -21,10 +26,37 @@ const (
 	// text is unreachable by the linked program.
 	R_METHODOFF = 24
 
-	//not used, only adapter golang 1.16
-	R_USEIFACE       = 0x10000000 - 3
-	R_USEIFACEMETHOD = 0x10000000 - 2
-	R_ADDRCUOFF      = 0x10000000 - 1
+	// R_GOTPCREL is a PC-relative offset to the GOT entry holding the address
+	// of the symbol.
+	R_GOTPCREL = 26
+
+	// R_JMPMIPS (only used on mips64) resolves to non-PC-relative target address
+	// of a JMP instruction, by encoding the address into the instruction.
+	// The stack nosplit check ignores this since it is not a function call.
+	R_JMPMIPS = 27
+
+	//not used, only adapter golang 1.16 and 1.17
+	R_KEEP              = 0x10000000 - 10
+	R_ARM64_GOTPCREL    = 0x10000000 - 9
+	R_PCRELDBL          = 0x10000000 - 8
+	R_ADDRMIPSU         = 0x10000000 - 7
+	R_CALLRISCV         = 0x10000000 - 6
+	R_RISCV_PCREL_ITYPE = 0x10000000 - 5
+	R_RISCV_PCREL_STYPE = 0x10000000 - 4
+	R_USEIFACE          = 0x10000000 - 3
+	R_USEIFACEMETHOD    = 0x10000000 - 2
+	R_ADDRCUOFF         = 0x10000000 - 1
+)
+
+// open-coded defers and asynchronous preemption of goroutines, since go1.14
+const (
+	openCodedDefers = false
+	asyncPreemption = false
+)
+
+const (
+	//not used, only adapter golang 1.12
+	SABIALIAS = 0x10000000 - 1
 )
 
 const (
@@ -76,6 +108,11 @@ const (
 	SDWARFINFO
 )
 
+// section of the DWARF symbol kinds
+var dwarfSections = map[int]string{
+	SDWARFINFO: ".debug_info",
+}
+
 func (linker *Linker) addStackObject(funcname string, symbolMap map[string]uintptr) (err error) {
 	return nil
 }
@@ -1,7 +1,6 @@
 package goloader
 
 import (
-	"encoding/binary"
 	"errors"
 	"fmt"
 	"runtime"
@@ -16,6 +15,9 @@ const (
 	// R_ADDRARM64 relocates an adrp, add pair to compute the address of the
 	// referenced symbol.
 	R_ADDRARM64 = 3
+	// R_ADDRMIPS (only used on mips/mips64) resolves to the low 16 bits of an external
+	// address, by encoding it into the instruction.
+	R_ADDRMIPS = 4
 	// R_ADDROFF resolves to a 32-bit offset from the beginning of the section
 	// holding the data being relocated to the referenced symbol.
 	R_ADDROFF = 5
@@ -28,6 +30,9 @@ const (
 	R_CALLARM     = 9
 	R_CALLARM64   = 10
 	R_CALLIND     = 11
+	// R_CALLMIPS (only used on mips64) resolves to non-PC-relative target address
+	// of a CALL (JAL) instruction, by encoding the address into the instruction.
+	R_CALLMIPS = 13
 )
 
 type Func struct {
@@ -54,15 +59,41 @@ type Reloc struct {
 }
 
 // ourself defined struct
-// code segment
+// code segment, the code and the trampolines are in one mapping and the
+// data in another, so their pages are protected separately
 type segment struct {
 	codeByte  []byte
+	dataByte  []byte
 	codeBase  int
 	dataBase  int
 	dataLen   int
 	codeLen   int
 	maxLength int
 	offset    int
+	far       int // relocations rewritten because their target is out of range
+	// grow, if not nil, maps at least size more bytes of trampolines after
+	// codeByte, see CodeModule.growCode
+	grow       func(size int) error
+	growFailed bool           // the pages after codeByte are taken
+	got        map[string]int // offsets of the GOT entries of the symbols, see gotEntry
+}
+
+// reserve checks that size bytes of trampoline can be written at the end of
+// the segment, growing it if it can, and reached by a 32-bit relative offset
+// from offset from.
+func (seg *segment) reserve(size, from int) error {
+	if seg.offset+size > len(seg.codeByte) && seg.grow != nil {
+		if err := seg.grow(seg.offset + size - len(seg.codeByte)); err != nil {
+			return err
+		}
+	}
+	if seg.offset+size > len(seg.codeByte) {
+		return fmt.Errorf("trampoline overflow: need %d bytes at offset:%d, segment length:%d", size, seg.offset, len(seg.codeByte))
+	}
+	if isOverflowInt32(seg.offset + size - from) {
+		return fmt.Errorf("trampoline at offset:%d is out of 32-bit range of offset:%d", seg.offset, from)
+	}
+	return nil
 }
 
 type Linker struct {
@@ -78,6 +109,16 @@ type Linker struct {
 	_func        []_func
 	initFuncs    []string
 	Arch         string
+	strtab       map[string]string   // interned strings of the objects, dropped after parse
+	hostPkgs     []string            // packages bound to the host, see ReadOptions
+	fileIndex    map[string]int32    // index of the files in filetab
+	modules      []ModuleSum         // modules pinned by PinModules
+	degraded     map[string][]string // see ModuleInfo.Degraded
+	externs      []Extern            // see SetExterns
+	sharedData   map[string]bool     // see ReadOptions.SharedData
+	container    *container          // sections not inflated yet, see OpenContainer
+	toolchain    Toolchain           // see Linker.Toolchain
+	provided     map[string]bool     // see ReadOptions.Provided
 }
 
 type CodeModule struct {
@@ -85,6 +126,40 @@ type CodeModule struct {
 	Syms    map[string]uintptr
 	module  *moduledata
 	stkmaps map[string][]byte
+	types   map[string]uintptr
+	vars    map[string]uintptr
+	pins    map[uintptr]*pinned
+	pinLock sync.Mutex
+	hash    string
+	options LoadOptions
+	libs    []*Library // shared libraries of LoadWithLibs
+	sealed  bool       // see Seal
+
+	relocStats RelocStats
+	symIndex   *symbolIndex
+	indexOnce  sync.Once
+	funcSigs   map[string]*funcSig // signatures from the DWARF, see FuncType
+
+	calls        calls
+	callbacks    map[*Callback]bool
+	callbackLock sync.Mutex
+	entries      []*entry // entry points handed to the host, see Swap
+	entryLock    sync.Mutex
+
+	initLock    sync.Mutex
+	pendingInit func() error
+	initErr     error
+
+	lockedBytes  int
+	gcdata       []byte
+	hostTypes    map[uintptr]int // typeOff of the host types, see hostTypeOff
+	dwarf        *moduleDWARF
+	failedReloc  *FailedReloc
+	degraded     map[string][]string
+	relocDumps   []RelocDump
+	sharedTables []string        // keys of the shared tables, see mapSharedData
+	dryRun       bool            // relocated in heap memory, see DryRun
+	stubbed      map[string]bool // symbols sent to deniedStub, see LoadOptions.StubDenied
 }
 
 type InlTreeNode struct {
@@ -114,9 +189,11 @@ type ObjSymbol struct {
 	Kind  int    // kind of symbol
 	DupOK bool   // are duplicate definitions okay?
 	Size  int64  // size of corresponding data
+	Align int    // alignment of the symbol, 0 if the object does not record it
 	Data  []byte // memory image of symbol
 	Reloc []Reloc
 	Func  *FuncInfo // additional data for functions
+	Type  string    // symbol for go type information
 }
 
 var (
@@ -128,22 +205,60 @@ func (linker *Linker) addSymbols() error {
 	//static_tmp is 0, golang compile not allocate memory.
 	linker.data = append(linker.data, make([]byte, IntSize)...)
 	for _, objSym := range linker.objsymbolMap {
+		if linker.isHostSymbol(objSym.Name) {
+			continue
+		}
 		if objSym.Kind == STEXT && objSym.DupOK == false {
 			_, err := linker.addSymbol(objSym.Name)
 			if err != nil {
 				return err
 			}
 		}
-		if objSym.Kind == SNOPTRDATA {
+		if objSym.Kind == SNOPTRDATA || isPackageVar(objSym) {
 			_, err := linker.addSymbol(objSym.Name)
 			if err != nil {
 				return err
 			}
 		}
 	}
+	//the data segment follows the code, align it for the data symbols
+	align := MaxDataAlign
+	for _, sym := range linker.symMap {
+		if objsym, ok := linker.objsymbolMap[sym.Name]; ok && objsym.Align > align {
+			align = objsym.Align
+		}
+	}
+	bytearrayAlign(&linker.code, align)
 	return nil
 }
 
+// isPackageVar reports whether objSym is a package-level variable, they
+// are loaded with their initial values even if nothing refers to them.
+func isPackageVar(objSym *ObjSymbol) bool {
+	switch objSym.Kind {
+	case SDATA, SBSS, SNOPTRBSS:
+		return !objSym.DupOK && symbolPkg(objSym.Name) != EmptyString
+	}
+	return false
+}
+
+// symAlign returns the alignment of a data symbol as the go linker aligns
+// it: the alignment recorded in the object, at least PtrSize, or else the
+// largest power of two up to MaxDataAlign not above its size.
+func symAlign(objsym *ObjSymbol) int {
+	if objsym.Align != 0 {
+		if objsym.Align < PtrSize {
+			return PtrSize
+		}
+		return objsym.Align
+	}
+	align := MaxDataAlign
+	for align > len(objsym.Data) && align > PtrSize {
+		align >>= 1
+	}
+	return align
+}
+
 func (linker *Linker) addSymbol(name string) (symbol *Sym, err error) {
 	if symbol, ok := linker.symMap[name]; ok {
 		return symbol, nil
@@ -151,6 +266,11 @@ func (linker *Linker) addSymbol(name string) (symbol *Sym, err error) {
 	objsym := linker.objsymbolMap[name]
 	symbol = &Sym{Name: objsym.Name, Kind: int(objsym.Kind)}
 	linker.symMap[symbol.Name] = symbol
+	if linker.provided[name] {
+		//resolved by the host, neither laid out nor relocated
+		symbol.Offset = InvalidOffset
+		return symbol, nil
+	}
 
 	switch symbol.Kind {
 	case STEXT:
@@ -162,6 +282,12 @@ func (linker *Linker) addSymbol(name string) (symbol *Sym, err error) {
 			return nil, err
 		}
 	default:
+		if linker.sharedData[name] {
+			//mapped once for all modules at load, see mapSharedData
+			symbol.Offset = InvalidOffset
+			break
+		}
+		bytearrayAlign(&linker.data, symAlign(objsym))
 		symbol.Offset = len(linker.data)
 		linker.data = append(linker.data, objsym.Data...)
 		bytearrayAlign(&linker.data, PtrSize)
@@ -170,12 +296,15 @@ func (linker *Linker) addSymbol(name string) (symbol *Sym, err error) {
 	for _, loc := range objsym.Reloc {
 		reloc := loc
 		reloc.Offset = reloc.Offset + symbol.Offset
-		if _, ok := linker.objsymbolMap[reloc.Sym.Name]; ok {
+		if target := linker.resolveABIAlias(reloc.Sym.Name); target != reloc.Sym.Name {
+			reloc.Sym = &Sym{Name: target, Offset: InvalidOffset}
+		}
+		if _, ok := linker.objsymbolMap[reloc.Sym.Name]; ok && !linker.isHostSymbol(reloc.Sym.Name) {
 			reloc.Sym, err = linker.addSymbol(reloc.Sym.Name)
 			if err != nil {
 				return nil, err
 			}
-			if len(linker.objsymbolMap[reloc.Sym.Name].Data) == 0 && reloc.Size > 0 {
+			if len(linker.objsymbolMap[reloc.Sym.Name].Data) == 0 && reloc.Size > 0 && !linker.provided[reloc.Sym.Name] {
 				//static_tmp is 0, golang compile not allocate memory.
 				//goloader add IntSize bytes on linker.data[0]
 				if int(reloc.Size) <= IntSize {
@@ -215,6 +344,11 @@ func (linker *Linker) addSymbol(name string) (symbol *Sym, err error) {
 		}
 		symbol.Reloc = append(symbol.Reloc, reloc)
 	}
+	if _, ok := linker.objsymbolMap[objsym.Type]; ok {
+		if _, err = linker.addSymbol(objsym.Type); err != nil {
+			return nil, err
+		}
+	}
 	return symbol, nil
 }
 
@@ -229,18 +363,15 @@ func (linker *Linker) readFuncData(symbol *ObjSymbol, codeLen int) (err error) {
 	bucket := &linker.pcfunc[b]
 	bucket.subbuckets[i] = byte(len(linker._func) - int(bucket.idx))
 
-	pcFileHead := make([]byte, 32)
-	pcFileHeadSize := binary.PutUvarint(pcFileHead, uint64(len(linker.filetab))<<1)
-	for _, fileName := range symbol.Func.File {
-		if offset, ok := linker.namemap[fileName]; !ok {
-			linker.filetab = append(linker.filetab, (uint32)(len(linker.pclntable)))
-			linker.namemap[fileName] = len(linker.pclntable)
-			fileName = strings.TrimLeft(fileName, FileSymPrefix)
-			linker.pclntable = append(linker.pclntable, []byte(fileName)...)
-			linker.pclntable = append(linker.pclntable, ZeroByte)
-		} else {
-			linker.filetab = append(linker.filetab, uint32(offset))
-		}
+	//the pcfile table holds indexes into the files of the function, make
+	//them indexes into the filetab of the module
+	files := make([]int32, len(symbol.Func.File))
+	for index, fileName := range symbol.Func.File {
+		files[index] = linker.addFile(fileName)
+	}
+	pcfile, err := remapPCValue(symbol.Func.PCFile, files)
+	if err != nil {
+		return fmt.Errorf("function %s: %v", symbol.Name, err)
 	}
 
 	nameOff := len(linker.pclntable)
@@ -255,16 +386,28 @@ func (linker *Linker) readFuncData(symbol *ObjSymbol, codeLen int) (err error) {
 	pcspOff := len(linker.pclntable)
 	linker.pclntable = append(linker.pclntable, symbol.Func.PCSP...)
 
-	pcfileOff := len(linker.pclntable)
-	linker.pclntable = append(linker.pclntable, pcFileHead[:pcFileHeadSize-1]...)
-	linker.pclntable = append(linker.pclntable, symbol.Func.PCFile...)
-
-	pclnOff := len(linker.pclntable)
-	linker.pclntable = append(linker.pclntable, symbol.Func.PCLine...)
+	//an object without the pcfile or pcln table of a function is loaded
+	//with offsets of 0, the runtime reports its positions as unknown
+	pcfileOff, pclnOff := 0, 0
+	if len(pcfile) != 0 && len(symbol.Func.PCLine) != 0 {
+		pcfileOff = len(linker.pclntable)
+		linker.pclntable = append(linker.pclntable, pcfile...)
+		pclnOff = len(linker.pclntable)
+		linker.pclntable = append(linker.pclntable, symbol.Func.PCLine...)
+	} else if len(symbol.Data) != 0 {
+		linker.degrade(DegradedSourcePositions, symbol.Name)
+	}
 
 	_func := init_func(symbol, nameOff, pcspOff, pcfileOff, pclnOff)
 	Func := linker.symMap[symbol.Name].Func
 	for _, pcdata := range symbol.Func.PCData {
+		//an offset of 0 is a missing table, the runtime reads the value -1,
+		//e.g. the PCDATA_UnsafePoint of a function without unsafe points.
+		//an offset to an empty table would make it decode the next table
+		if len(pcdata) == 0 {
+			Func.PCData = append(Func.PCData, 0)
+			continue
+		}
 		Func.PCData = append(Func.PCData, uint32(len(linker.pclntable)))
 		linker.pclntable = append(linker.pclntable, pcdata...)
 	}
@@ -291,6 +434,9 @@ func (linker *Linker) readFuncData(symbol *ObjSymbol, codeLen int) (err error) {
 	}
 
 	grow(&linker.pclntable, alignof(len(linker.pclntable), PtrSize))
+	if uint64(len(linker.pclntable)) > 0x7FFFFFFF {
+		return fmt.Errorf("pclntable size:%d overflows 32-bit offsets of function:%s", len(linker.pclntable), symbol.Name)
+	}
 	linker._func = append(linker._func, _func)
 
 	for _, name := range symbol.Func.FuncData {
@@ -301,16 +447,52 @@ func (linker *Linker) readFuncData(symbol *ObjSymbol, codeLen int) (err error) {
 	return
 }
 
+// isMarkerReloc reports whether a relocation of relocType only marks its
+// target as used, it patches nothing. The target is loaded with the module
+// if the objects define it, e.g. the type converted to an interface of
+// R_USEIFACE, so its methods are there for the itabs built at run time.
+func isMarkerReloc(relocType int) bool {
+	switch relocType {
+	case R_USEIFACE, R_USEIFACEMETHOD, R_USEFIELD, R_USETYPE, R_KEEP:
+		return true
+	}
+	return false
+}
+
+// strongRefs returns the names of symbols referenced by a relocation which
+// is neither weak nor a marker.
+func (linker *Linker) strongRefs() map[string]bool {
+	refs := make(map[string]bool)
+	for _, symbol := range linker.symMap {
+		for _, loc := range symbol.Reloc {
+			if loc.Type != R_WEAKADDROFF && !isMarkerReloc(loc.Type) {
+				refs[loc.Sym.Name] = true
+			}
+		}
+	}
+	return refs
+}
+
 func (linker *Linker) addSymbolMap(symPtr map[string]uintptr, codeModule *CodeModule) (symbolMap map[string]uintptr, err error) {
 	symbolMap = make(map[string]uintptr)
 	segment := &codeModule.segment
+	strongRefs := linker.strongRefs()
+	link := &LinkError{}
 	for name, sym := range linker.symMap {
-		if sym.Offset == InvalidOffset {
+		if linker.sharedData[name] {
+			if symbolMap[name], err = codeModule.mapSharedData(name, linker.objsymbolMap[name].Data); err != nil {
+				return nil, err
+			}
+		} else if sym.Offset == InvalidOffset {
 			if ptr, ok := symPtr[sym.Name]; ok {
 				symbolMap[name] = ptr
+			} else if ptr, ok := codeModule.resolveSymbol(sym.Name); ok {
+				symbolMap[name] = ptr
 			} else {
 				symbolMap[name] = InvalidHandleValue
-				return nil, fmt.Errorf("unresolve external:%s", sym.Name)
+				if strongRefs[name] || codeModule.options.StrictWeak {
+					link.unresolved(name, linker.unresolvedError(sym.Name))
+				}
 			}
 		} else if sym.Name == TLSNAME {
 			//nothing todo
@@ -332,154 +514,432 @@ func (linker *Linker) addSymbolMap(symPtr map[string]uintptr, codeModule *CodeMo
 			}
 		}
 	}
-	return symbolMap, err
+	if err = link.err(); err != nil {
+		return nil, err
+	}
+	return symbolMap, nil
+}
+
+func (linker *Linker) addTypeMap(symPtr, symbolMap map[string]uintptr, codeModule *CodeModule) {
+	for name := range linker.symMap {
+		if objsym, ok := linker.objsymbolMap[name]; ok && objsym.Type != EmptyString {
+			if objsym.Kind != STEXT {
+				codeModule.vars[name] = symbolMap[name]
+			}
+			if ptr, ok := symbolMap[objsym.Type]; ok {
+				codeModule.types[name] = ptr
+			} else if ptr, ok := symPtr[objsym.Type]; ok {
+				codeModule.types[name] = ptr
+			}
+		}
+	}
+	linker.addFuncSigs(symPtr, symbolMap, codeModule)
 }
 
-func relocateADRP(mCode []byte, loc Reloc, segment *segment, symAddr uintptr) {
-	offset := uint64(int64(symAddr) + int64(loc.Add) - ((int64(segment.codeBase) + int64(loc.Offset)) &^ 0xFFF))
+// relocateADRP relocates the ADRP, ADD pair of R_ADDRARM64, which reaches
+// 4GB around the PC. A target out of range whose address fits in 32 bits
+// is moved into the register by MOVZ, MOVK instead, other targets are
+// loaded from a literal pool: the ADRP branches to a trampoline loading the
+// address from the literal after it and branching back after the ADD.
+func relocateADRP(mCode []byte, loc Reloc, segment *segment, symAddr uintptr) (err error) {
+	target := uint64(int64(symAddr) + int64(loc.Add))
+	offset := int64(target) - ((int64(segment.codeBase) + int64(loc.Offset)) &^ 0xFFF)
 	//overflow
-	if offset > 0xFFFFFFFF {
-		if symAddr < 0xFFFFFFFF {
-			addr := binary.LittleEndian.Uint32(mCode)
+	if offset >= 1<<32 || offset < -1<<32 {
+		segment.far++
+		reg := byteOrder.Uint32(mCode) & 0x1F
+		if target < 0xFFFFFFFF {
 			//low:	MOV reg imm
 			low := uint32(0xD2800000)
 			//high: MOVK reg imm LSL#16
 			high := uint32(0xF2A00000)
-			low = ((addr & 0x1F) | low) | ((uint32(symAddr) & 0xFFFF) << 5)
-			high = ((addr & 0x1F) | high) | (uint32(symAddr) >> 16 << 5)
-			binary.LittleEndian.PutUint64(mCode, uint64(low)|(uint64(high)<<32))
+			low = (reg | low) | ((uint32(target) & 0xFFFF) << 5)
+			high = (reg | high) | (uint32(target) >> 16 << 5)
+			byteOrder.PutUint64(mCode, uint64(low)|(uint64(high)<<32))
 		} else {
-			addr := binary.LittleEndian.Uint32(mCode)
-			blcode := binary.LittleEndian.Uint32(arm64BLcode)
-			blcode |= ((uint32(segment.offset) - uint32(loc.Offset)) >> 2) & 0x01FFFFFF
-			if segment.offset-loc.Offset < 0 {
-				blcode |= 0x02000000
-			}
-			binary.LittleEndian.PutUint32(mCode, blcode)
-			//low: MOV reg imm
-			llow := uint32(0xD2800000)
-			//lhigh: MOVK reg imm LSL#16
-			lhigh := uint32(0xF2A00000)
-			//llow: MOVK reg imm LSL#32
-			hlow := uint32(0xF2C00000)
-			//lhigh: MOVK reg imm LSL#48
-			hhigh := uint32(0xF2E00000)
-			llow = ((addr & 0x1F) | llow) | ((uint32(symAddr) & 0xFFFF) << 5)
-			lhigh = ((addr & 0x1F) | lhigh) | (uint32(symAddr) >> 16 << 5)
-			putAddressAddOffset(segment.codeByte, &segment.offset, uint64(llow)|(uint64(lhigh)<<32))
-			hlow = ((addr & 0x1F) | hlow) | uint32(((uint64(symAddr)>>32)&0xFFFF)<<5)
-			hhigh = ((addr & 0x1F) | hhigh) | uint32((uint64(symAddr)>>48)<<5)
-			putAddressAddOffset(segment.codeByte, &segment.offset, uint64(hlow)|(uint64(hhigh)<<32))
-			blcode = binary.LittleEndian.Uint32(arm64BLcode)
-			blcode |= ((uint32(loc.Offset) - uint32(segment.offset) + 8) >> 2) & 0x01FFFFFF
-			if loc.Offset-segment.offset+8 < 0 {
-				blcode |= 0x02000000
-			}
-			binary.LittleEndian.PutUint32(segment.codeByte[segment.offset:], blcode)
+			segment.offset = alignof(segment.offset, PtrSize)
+			if err = segment.reserve(2*Uint32Size+PtrSize, loc.Offset); err != nil {
+				return err
+			}
+			if isOverflowArm64Branch(segment.offset - loc.Offset) {
+				return fmt.Errorf("trampoline at offset:%d is out of branch range of offset:%d", segment.offset, loc.Offset)
+			}
+			//B to the trampoline, the ADD is skipped
+			byteOrder.PutUint32(mCode, arm64Bcode|uint32((segment.offset-loc.Offset)>>2)&0x03FFFFFF)
+			byteOrder.PutUint32(mCode[Uint32Size:], arm64NOPcode)
+			//LDR reg, [PC+8]
+			byteOrder.PutUint32(segment.codeByte[segment.offset:], arm64LDRLiteralcode|2<<5|reg)
 			segment.offset += Uint32Size
+			//B back after the ADD
+			byteOrder.PutUint32(segment.codeByte[segment.offset:], arm64Bcode|uint32((loc.Offset+2*Uint32Size-segment.offset)>>2)&0x03FFFFFF)
+			segment.offset += Uint32Size
+			putAddressAddOffset(segment.codeByte, &segment.offset, target)
 		}
 	} else {
 		// 2bit + 19bit + low(12bit) = 33bit
 		low := (uint32((offset>>12)&3) << 29) | (uint32((offset>>12>>2)&0x7FFFF) << 5)
 		high := (uint32(offset&0xFFF) << 10)
-		value := binary.LittleEndian.Uint64(mCode)
+		value := byteOrder.Uint64(mCode)
 		value = (uint64(uint32(value>>32)|high) << 32) | uint64(uint32(value&0xFFFFFFFF)|low)
-		binary.LittleEndian.PutUint64(mCode, value)
+		byteOrder.PutUint64(mCode, value)
 	}
+	return err
+}
+
+// isOverflowArm64Branch reports whether the byte offset is out of the
+// 128MB range of an arm64 B or BL.
+func isOverflowArm64Branch(offset int) bool {
+	return offset >= 1<<27 || offset < -1<<27
 }
 
-func relocateCALL(addr uintptr, loc Reloc, segment *segment, relocByte []byte, addrBase int) {
+// relocateCALL and relocatePCREL fill a 32-bit PC-relative offset. On 386
+// int is 32-bit, the offset wraps as the address space does and always
+// fits, the 64-bit trampolines are only built on amd64.
+func relocateCALL(addr uintptr, loc Reloc, segment *segment, relocByte []byte, addrBase int) (err error) {
 	offset := int(addr) - (addrBase + loc.Offset + loc.Size) + loc.Add
-	if offset > 0x7FFFFFFF || offset < -0x80000000 {
+	if isOverflowInt32(offset) {
+		segment.far++
+		if err = segment.reserve(len(x86amd64JMPLcode)+PtrSize, addrBase-segment.codeBase+loc.Offset+loc.Size); err != nil {
+			return err
+		}
 		offset = (segment.codeBase + segment.offset) - (addrBase + loc.Offset + loc.Size)
 		copy(segment.codeByte[segment.offset:], x86amd64JMPLcode)
 		segment.offset += len(x86amd64JMPLcode)
 		putAddressAddOffset(segment.codeByte, &segment.offset, uint64(addr)+uint64(loc.Add))
 	}
-	binary.LittleEndian.PutUint32(relocByte[loc.Offset:], uint32(offset))
+	byteOrder.PutUint32(relocByte[loc.Offset:], uint32(offset))
+	return err
 }
 
+// relocatePCREL relocates the 32-bit PC-relative offset of an amd64 LEAQ,
+// MOVQ or MOVL load, or CMPL with an 8-bit immediate. A target out of
+// range is reached through the trampolines: LEAQ becomes a MOVQ of the
+// address from a literal, the loads and CMPL jump to code moving the
+// address into a register and accessing memory through it, with the
+// operand size of the instruction. Other instructions fail.
 func relocatePCREL(addr uintptr, loc Reloc, segment *segment, relocByte []byte, addrBase int) (err error) {
 	offset := int(addr) - (addrBase + loc.Offset + loc.Size) + loc.Add
-	if offset > 0x7FFFFFFF || offset < -0x80000000 {
+	if isOverflowInt32(offset) {
+		segment.far++
+		if err = segment.reserve(3*PtrSize+len(x86amd64replaceCMPLcode), addrBase-segment.codeBase+loc.Offset+loc.Size); err != nil {
+			return err
+		}
+		if loc.Offset < 2 {
+			return fmt.Errorf("not support code at offset:%d!", loc.Offset)
+		}
 		offset = (segment.codeBase + segment.offset) - (addrBase + loc.Offset + loc.Size)
 		bytes := relocByte[loc.Offset-2:]
-		opcode := relocByte[loc.Offset-2]
-		regsiter := ZeroByte
+		opcode, modrm := bytes[0], bytes[1]
+		//the REX prefix holds the operand size and the high bit of the register
+		rex := ZeroByte
+		if loc.Offset >= 3 && relocByte[loc.Offset-3]&0xF0 == x86amd64REXcode {
+			rex = relocByte[loc.Offset-3]
+		}
+		//the bytes of the instruction after the offset, its immediate
+		trailing := 0
 		if opcode == x86amd64LEAcode {
 			bytes[0] = x86amd64MOVcode
 		} else if opcode == x86amd64MOVcode && loc.Size >= Uint32Size {
-			regsiter = ((relocByte[loc.Offset-1] >> 3) & 0x7) | 0xb8
 			copy(bytes, x86amd64JMPLcode)
 		} else if opcode == x86amd64CMPLcode && loc.Size >= Uint32Size {
+			trailing = 1
 			copy(bytes, x86amd64JMPLcode)
 		} else {
 			return fmt.Errorf("not support code:%v!", relocByte[loc.Offset-2:loc.Offset])
 		}
-		binary.LittleEndian.PutUint32(relocByte[loc.Offset:], uint32(offset))
+		byteOrder.PutUint32(relocByte[loc.Offset:], uint32(offset))
+		//the target of the instruction, Add is relative to its end
+		target := uint64(int(addr) + loc.Add + trailing)
 		if opcode == x86amd64CMPLcode || opcode == x86amd64MOVcode {
 			putAddressAddOffset(segment.codeByte, &segment.offset, uint64(segment.codeBase+segment.offset+PtrSize))
 			if opcode == x86amd64CMPLcode {
 				copy(segment.codeByte[segment.offset:], x86amd64replaceCMPLcode)
+				if rex&x86amd64REXW == 0 {
+					//CMPL compares 32 bits
+					segment.codeByte[segment.offset+0x0C] = x86amd64REXcode
+				}
 				segment.codeByte[segment.offset+0x0F] = relocByte[loc.Offset+loc.Size]
 				segment.offset += len(x86amd64replaceCMPLcode)
-				putAddressAddOffset(segment.codeByte, &segment.offset, uint64(addr))
+				putAddressAddOffset(segment.codeByte, &segment.offset, target)
 			} else {
-				copy(segment.codeByte[segment.offset:], x86amd64replaceMOVQcode)
-				segment.codeByte[segment.offset+1] = regsiter
-				copy2Slice(segment.codeByte[segment.offset+2:], addr, PtrSize)
-				segment.offset += len(x86amd64replaceMOVQcode)
+				code := x86amd64LoadCode(rex, modrm, target)
+				copy(segment.codeByte[segment.offset:], code)
+				segment.offset += len(code)
 			}
-			putAddressAddOffset(segment.codeByte, &segment.offset, uint64(addrBase+loc.Offset+loc.Size-loc.Add))
+			putAddressAddOffset(segment.codeByte, &segment.offset, uint64(addrBase+loc.Offset+loc.Size+trailing))
 		} else {
-			putAddressAddOffset(segment.codeByte, &segment.offset, uint64(addr))
+			putAddressAddOffset(segment.codeByte, &segment.offset, target)
 		}
 	} else {
-		binary.LittleEndian.PutUint32(relocByte[loc.Offset:], uint32(offset))
+		byteOrder.PutUint32(relocByte[loc.Offset:], uint32(offset))
 	}
 	return err
 }
 
-func relocteCALLARM(addr uintptr, loc Reloc, segment *segment) {
-	add := loc.Add
-	if loc.Type == R_CALLARM {
-		add = int(signext24(int64(loc.Add&0xFFFFFF)) * 4)
+// x86amd64LoadCode returns the code of a trampoline replacing the MOV load
+// of rex and modrm from target: it moves target into the destination
+// register, loads through it and jumps back to the address in the literal
+// following the code.
+func x86amd64LoadCode(rex, modrm byte, target uint64) []byte {
+	//REX.R extends the register field of modrm
+	reg := (modrm>>3)&7 | (rex&x86amd64REXR)<<1
+	var imm [8]byte
+	byteOrder.PutUint64(imm[:], target)
+	code := make([]byte, 0, 2+len(imm)+4+len(x86amd64JMPLcode))
+	//MOVQ $target, reg
+	code = append(code, x86amd64REXcode|x86amd64REXW|reg>>3, x86amd64MOVABScode|reg&7)
+	code = append(code, imm[:]...)
+	//MOV (reg), reg, REX.B extends the base register
+	if rex != 0 || reg > 7 {
+		code = append(code, rex|x86amd64REXcode|reg>>3)
+	}
+	switch reg & 7 {
+	case 4:
+		//SP and R12 as base need a SIB byte
+		code = append(code, x86amd64MOVcode, (reg&7)<<3|4, 0x24)
+	case 5:
+		//BP and R13 as base need a displacement, without one they mean RIP
+		code = append(code, x86amd64MOVcode, 0x40|(reg&7)<<3|5, 0x00)
+	default:
+		code = append(code, x86amd64MOVcode, (reg&7)<<3|reg&7)
+	}
+	return append(code, x86amd64JMPLcode...)
+}
+
+// relocateBranchARM relocates the B, BL or BLX of R_CALLARM, the low 24 bits
+// of Add are the word offset of the target from PC, which reads 8 bytes
+// ahead. A target out of the 32MB range of the branch, or a Thumb target,
+// bit 0 set, the branch can not switch to, goes through a veneer loading
+// the address of the target into PC, which switches to Thumb as BX does.
+// An unconditional BL to a Thumb target in range becomes a BLX.
+func relocateBranchARM(addr uintptr, loc Reloc, segment *segment) (err error) {
+	insn := byteOrder.Uint32(segment.codeByte[loc.Offset:]) & 0xFF000000
+	add := int(signext24(int64(loc.Add&0xFFFFFF))) * 4
+	target := int(addr)
+	thumb := target&1 == 1
+	offset := target&^1 + add - (segment.codeBase + loc.Offset)
+	inRange := offset >= -(1<<25) && offset < 1<<25
+	if thumb && inRange && insn&armCondMask == armCondAL && insn&armOpMask == armBLcode {
+		insn = armBLXcode | uint32(offset>>1&1)<<24 | uint32(offset>>2)&0xFFFFFF
+	} else if !thumb && inRange {
+		insn |= uint32(offset>>2) & 0xFFFFFF
+	} else {
+		segment.far++
+		segment.offset = alignof(segment.offset, PtrSize)
+		if err = segment.reserve(len(armcode)+PtrSize, loc.Offset); err != nil {
+			return err
+		}
+		offset = segment.offset + add - loc.Offset
+		if offset >= 1<<25 {
+			return fmt.Errorf("veneer at offset:%d is out of branch range of offset:%d", segment.offset, loc.Offset)
+		}
+		insn |= uint32(offset>>2) & 0xFFFFFF
+		copy(segment.codeByte[segment.offset:], armcode)
+		segment.offset += len(armcode)
+		putAddressAddOffset(segment.codeByte, &segment.offset, uint64(uint32(target)))
 	}
-	offset := (int(addr) + add - (segment.codeBase + loc.Offset)) / 4
+	byteOrder.PutUint32(segment.codeByte[loc.Offset:], insn)
+	return err
+}
+
+func relocteCALLARM(addr uintptr, loc Reloc, segment *segment) (err error) {
+	offset := (int(addr) + loc.Add - (segment.codeBase + loc.Offset)) / 4
 	if offset > 0x7FFFFF || offset < -0x800000 {
+		segment.far++
 		segment.offset = alignof(segment.offset, PtrSize)
-		off := uint32(segment.offset-loc.Offset) / 4
-		if loc.Type == R_CALLARM {
-			add = int(signext24(int64(loc.Add&0xFFFFFF)+2) * 4)
-			off = uint32(segment.offset-loc.Offset-8) / 4
-		}
-		putUint24(segment.codeByte[loc.Offset:], off)
-		if loc.Type == R_CALLARM64 {
-			copy(segment.codeByte[segment.offset:], arm64code)
-			segment.offset += len(arm64code)
-		} else {
-			copy(segment.codeByte[segment.offset:], armcode)
-			segment.offset += len(armcode)
+		if err = segment.reserve(len(arm64code)+PtrSize, loc.Offset); err != nil {
+			return err
+		}
+		if (segment.offset-loc.Offset)/4 > 0x7FFFFF {
+			return fmt.Errorf("trampoline at offset:%d is out of branch range of offset:%d", segment.offset, loc.Offset)
 		}
-		putAddressAddOffset(segment.codeByte, &segment.offset, uint64(int(addr)+add))
+		putUint24(segment.codeByte[loc.Offset:], uint32(segment.offset-loc.Offset)/4)
+		copy(segment.codeByte[segment.offset:], arm64code)
+		segment.offset += len(arm64code)
+		putAddressAddOffset(segment.codeByte, &segment.offset, uint64(int(addr)+loc.Add))
+	} else {
+		val := byteOrder.Uint32(segment.codeByte[loc.Offset:])
+		val |= uint32(offset) & 0x03FFFFFF
+		byteOrder.PutUint32(segment.codeByte[loc.Offset:], val)
+	}
+	return err
+}
+
+// splitRISCV splits a PC-relative offset into the 20-bit immediate of an
+// AUIPC and the signed 12-bit immediate of the instruction after it.
+func splitRISCV(offset int) (high, low int64, ok bool) {
+	low = int64(offset) << 52 >> 52
+	high = (int64(offset) - low) >> 12
+	return high, low, high >= -(1<<19) && high < 1<<19
+}
+
+// putRISCVPCREL writes offset into the immediates of the AUIPC at b and of
+// the I-type or S-type instruction after it.
+func putRISCVPCREL(b []byte, offset int, stype bool) {
+	high, low, _ := splitRISCV(offset)
+	auipc := byteOrder.Uint32(b)&0xFFF | uint32(high)<<12
+	second := byteOrder.Uint32(b[Uint32Size:])
+	if stype {
+		second = second&^0xFE000F80 | (uint32(low)>>5&0x7F)<<25 | (uint32(low)&0x1F)<<7
 	} else {
-		val := binary.LittleEndian.Uint32(segment.codeByte[loc.Offset:])
-		if loc.Type == R_CALLARM {
-			val |= uint32(offset) & 0x00FFFFFF
+		second = second&0xFFFFF | uint32(low)<<20
+	}
+	byteOrder.PutUint32(b, auipc)
+	byteOrder.PutUint32(b[Uint32Size:], second)
+}
+
+// relocateRISCV relocates an AUIPC and the I-type or S-type instruction
+// after it. A target out of the 32-bit range is reached through a trampoline
+// loading its address: a call (AUIPC, JALR) links as before and the
+// trampoline jumps to the target, a load, a store or an address computation
+// runs in the trampoline on the address and jumps back after the pair
+// with X31.
+func relocateRISCV(addr uintptr, loc Reloc, segment *segment) (err error) {
+	offset := int(addr) + loc.Add - (segment.codeBase + loc.Offset)
+	stype := loc.Type == R_RISCV_PCREL_STYPE
+	if _, _, ok := splitRISCV(offset); !ok {
+		segment.far++
+		code := segment.codeByte[loc.Offset:]
+		reg := byteOrder.Uint32(code) >> 7 & 0x1F
+		second := byteOrder.Uint32(code[Uint32Size:])
+		call := second&riscvOpMask == riscvJALRcode
+		words := []uint32{riscvAUIPCcode | reg<<7, riscvLDcode | reg<<15 | reg<<7}
+		if call {
+			words = append(words, riscvJALRcode|reg<<15)
 		} else {
-			val |= uint32(offset) & 0x03FFFFFF
+			if !stype && second>>7&0x1F == riscvTMP {
+				return fmt.Errorf("can not relocate offset:%d out of range, instruction writes X31", loc.Offset)
+			}
+			if stype {
+				second &^= 0xFE000F80
+			} else {
+				second &= 0xFFFFF
+			}
+			words = append(words, second, riscvAUIPCcode|riscvTMP<<7, riscvJALRcode|riscvTMP<<15)
+		}
+		//LD reads the address after the instructions, NOPs align it to PtrSize
+		for len(words)*Uint32Size%PtrSize != 0 {
+			words = append(words, riscvNOPcode)
 		}
-		binary.LittleEndian.PutUint32(segment.codeByte[loc.Offset:], val)
+		segment.offset = alignof(segment.offset, PtrSize)
+		if err = segment.reserve(len(words)*Uint32Size+PtrSize, loc.Offset); err != nil {
+			return err
+		}
+		tramp := segment.offset
+		words[1] |= uint32(len(words)*Uint32Size) << 20
+		for _, word := range words {
+			byteOrder.PutUint32(segment.codeByte[segment.offset:], word)
+			segment.offset += Uint32Size
+		}
+		putAddressAddOffset(segment.codeByte, &segment.offset, uint64(int(addr)+loc.Add))
+		if !call {
+			putRISCVPCREL(segment.codeByte[tramp+3*Uint32Size:], loc.Offset+2*Uint32Size-(tramp+3*Uint32Size), false)
+			//the pair jumps to the trampoline without linking
+			byteOrder.PutUint32(code[Uint32Size:], riscvJALRcode|reg<<15)
+		}
+		offset, stype = tramp-loc.Offset, false
 	}
+	putRISCVPCREL(segment.codeByte[loc.Offset:], offset, stype)
+	return err
+}
+
+// relocateS390X relocates the 32-bit halfword offset of a BRASL (R_CALL)
+// or a LARL (R_PCRELDBL), the field is 2 bytes into the instruction and the
+// offset is from the instruction, loc.Add includes those 2 bytes and the
+// 4 bytes of the field. A call out of range goes through a trampoline
+// loading the target from the literal after it into R10.
+func relocateS390X(addr uintptr, loc Reloc, segment *segment) (err error) {
+	offset := int(addr) + loc.Add - (segment.codeBase + loc.Offset + loc.Size)
+	if isOverflowInt32(offset / 2) {
+		if loc.Type != R_CALL {
+			return fmt.Errorf("can not relocate LARL at offset:%d out of range, offset:%d", loc.Offset, offset)
+		}
+		segment.far++
+		//LGRL reads a doubleword aligned literal, it follows 8 bytes of code
+		segment.offset = alignof(segment.offset, PtrSize)
+		if err = segment.reserve(len(s390xLGRLcode)+len(s390xBRcode)+PtrSize, loc.Offset); err != nil {
+			return err
+		}
+		tramp := segment.offset
+		copy(segment.codeByte[segment.offset:], s390xLGRLcode)
+		byteOrder.PutUint32(segment.codeByte[segment.offset+2:], uint32(len(s390xLGRLcode)+len(s390xBRcode))/2)
+		segment.offset += len(s390xLGRLcode)
+		copy(segment.codeByte[segment.offset:], s390xBRcode)
+		segment.offset += len(s390xBRcode)
+		putAddressAddOffset(segment.codeByte, &segment.offset, uint64(int(addr)+loc.Add-loc.Size-2))
+		//the offset of BRASL is from the instruction, 2 bytes before the field
+		offset = tramp - (loc.Offset - 2)
+	}
+	byteOrder.PutUint32(segment.codeByte[loc.Offset:], uint32(offset/2))
+	return err
+}
+
+// relocateJMPMIPS relocates a JAL or a J, whose target is the low 28 bits
+// of an address in the 256MB region of its delay slot. A target in another
+// region is reached through a jump island loading the address into R23,
+// a JAL has already linked the return address when the island runs.
+func relocateJMPMIPS(addr uintptr, loc Reloc, segment *segment) (err error) {
+	target := uint64(int(addr) + loc.Add)
+	region := uint64(segment.codeBase+loc.Offset+Uint32Size) &^ 0xFFFFFFF
+	if target&^0xFFFFFFF != region {
+		segment.far++
+		words := []uint32{
+			mipsLUIcode | mipsTMP<<16 | uint32(target>>48)&0xFFFF,
+			mipsORIcode | mipsTMP<<21 | mipsTMP<<16 | uint32(target>>32)&0xFFFF,
+			mipsDSLLcode | mipsTMP<<16 | mipsTMP<<11 | 16<<6,
+			mipsORIcode | mipsTMP<<21 | mipsTMP<<16 | uint32(target>>16)&0xFFFF,
+			mipsDSLLcode | mipsTMP<<16 | mipsTMP<<11 | 16<<6,
+			mipsORIcode | mipsTMP<<21 | mipsTMP<<16 | uint32(target)&0xFFFF,
+			mipsJRcode | mipsTMP<<21,
+			mipsNOPcode,
+		}
+		if err = segment.reserve(len(words)*Uint32Size, loc.Offset); err != nil {
+			return err
+		}
+		if uint64(segment.codeBase+segment.offset)&^0xFFFFFFF != region {
+			return fmt.Errorf("jump island at offset:%d is out of the 256MB region of offset:%d", segment.offset, loc.Offset)
+		}
+		target = uint64(segment.codeBase + segment.offset)
+		for _, word := range words {
+			byteOrder.PutUint32(segment.codeByte[segment.offset:], word)
+			segment.offset += Uint32Size
+		}
+	}
+	insn := byteOrder.Uint32(segment.codeByte[loc.Offset:])
+	byteOrder.PutUint32(segment.codeByte[loc.Offset:], insn&0xFC000000|uint32(target>>2)&0x3FFFFFF)
+	return err
+}
+
+// relocateADDRMIPS relocates the LUI (R_ADDRMIPSU) or the instruction with
+// the low 16 bits (R_ADDRMIPS) of an absolute address. The pair computes
+// a 32-bit address sign extended to 64 bits, so the symbols must be mapped
+// in the low 2GB, as mmapNear does next to the host.
+func relocateADDRMIPS(addr uintptr, loc Reloc, b []byte) error {
+	address := int(addr) + loc.Add
+	if isOverflowInt32(address) {
+		return fmt.Errorf("address:0x%x of offset:%d overflows the 32-bit address of a mips instruction pair", address, loc.Offset)
+	}
+	insn := byteOrder.Uint32(b)
+	if loc.Type == R_ADDRMIPSU {
+		insn = insn&0xFFFF0000 | uint32((address+1<<15)>>16)&0xFFFF
+	} else {
+		insn = insn&0xFFFF0000 | uint32(address)&0xFFFF
+	}
+	byteOrder.PutUint32(b, insn)
+	return nil
 }
 
 func (linker *Linker) relocate(codeModule *CodeModule, symbolMap map[string]uintptr) (err error) {
 	segment := &codeModule.segment
+	link := &LinkError{}
 	for _, symbol := range linker.symMap {
 		for _, loc := range symbol.Reloc {
 			addr := symbolMap[loc.Sym.Name]
+			if codeModule.stubbed[loc.Sym.Name] && isCallReloc(loc.Type) {
+				addr = getFunctionPtr(deniedStub)
+			}
 			sym := loc.Sym
-			relocByte := segment.codeByte[segment.codeLen:]
+			relocByte := segment.dataByte
 			addrBase := segment.dataBase
 			if symbol.Kind == STEXT {
 				addrBase = segment.codeBase
@@ -490,54 +950,117 @@ func (linker *Linker) relocate(codeModule *CodeModule, symbolMap map[string]uint
 				symbolMap[loc.Sym.Name] = addr
 				codeModule.module.itablinks = append(codeModule.module.itablinks, (*itab)(adduintptr(uintptr(segment.dataBase), loc.Sym.Offset)))
 			}
-			if addr != InvalidHandleValue {
+			far := segment.far
+			overflow, unsupported := false, false
+			var before []byte
+			trampoline := segment.offset
+			if codeModule.options.DumpFarRelocs {
+				start, end := relocWindow(loc, relocByte)
+				before = append([]byte(nil), relocByte[start:end]...)
+			}
+			if addr == InvalidHandleValue && loc.Type == R_WEAKADDROFF {
+				//weak relocation of an unreachable symbol resolves to zero
+				byteOrder.PutUint32(segment.dataByte[loc.Offset:], 0)
+				if codeModule.options.WeakZeroed != nil {
+					codeModule.options.WeakZeroed(symbol.Name, sym.Name)
+				}
+			} else if addr != InvalidHandleValue {
 				switch loc.Type {
 				case R_TLS_LE:
 					if _, ok := symbolMap[TLSNAME]; !ok {
 						regTLS(symbolMap, segment.codeByte[symbol.Offset:loc.Offset])
 					}
-					binary.LittleEndian.PutUint32(segment.codeByte[loc.Offset:], uint32(symbolMap[TLSNAME]))
+					byteOrder.PutUint32(segment.codeByte[loc.Offset:], uint32(symbolMap[TLSNAME]))
 				case R_CALL:
-					relocateCALL(addr, loc, segment, relocByte, addrBase)
+					if linker.Arch == archS390X {
+						err = relocateS390X(addr, loc, segment)
+					} else {
+						err = relocateCALL(addr, loc, segment, relocByte, addrBase)
+					}
+				case R_PCRELDBL:
+					err = relocateS390X(addr, loc, segment)
 				case R_PCREL:
 					err = relocatePCREL(addr, loc, segment, relocByte, addrBase)
-				case R_CALLARM, R_CALLARM64:
-					relocteCALLARM(addr, loc, segment)
+				case R_GOTPCREL:
+					err = relocateGOTPCREL(addr, loc, segment, relocByte, addrBase)
+				case R_ARM64_GOTPCREL:
+					err = relocateARM64GOTPCREL(addr, loc, segment)
+				case R_CALLARM:
+					err = relocateBranchARM(addr, loc, segment)
+				case R_CALLARM64:
+					err = relocteCALLARM(addr, loc, segment)
+				case R_RISCV_PCREL_ITYPE, R_RISCV_PCREL_STYPE:
+					err = relocateRISCV(addr, loc, segment)
+				case R_CALLRISCV:
+					//nothing todo, the call is relocated with its AUIPC
+				case R_CALLMIPS, R_JMPMIPS:
+					err = relocateJMPMIPS(addr, loc, segment)
+				case R_ADDRMIPS, R_ADDRMIPSU:
+					err = relocateADDRMIPS(addr, loc, relocByte[loc.Offset:])
 				case R_ADDRARM64:
 					if symbol.Kind != STEXT {
 						err = fmt.Errorf("impossible!Sym:%s locate not in code segment!", sym.Name)
 					}
-					relocateADRP(segment.codeByte[loc.Offset:], loc, segment, addr)
+					if err == nil {
+						err = relocateADRP(segment.codeByte[loc.Offset:], loc, segment, addr)
+					}
 				case R_ADDR:
 					address := uintptr(int(addr) + loc.Add)
-					putAddress(relocByte[loc.Offset:], uint64(address))
+					if loc.Size == Uint32Size && PtrSize != Uint32Size {
+						if uint64(address) > 0xFFFFFFFF {
+							err = fmt.Errorf("symName:%s address:0x%x overflows 32-bit R_ADDR", sym.Name, address)
+							overflow = true
+						} else {
+							byteOrder.PutUint32(relocByte[loc.Offset:], uint32(address))
+						}
+					} else {
+						putAddress(relocByte[loc.Offset:], uint64(address))
+					}
 				case R_CALLIND:
 					//nothing todo
 				case R_ADDROFF, R_WEAKADDROFF, R_METHODOFF:
 					if symbol.Kind == STEXT {
 						err = fmt.Errorf("impossible!Sym:%s locate on code segment!", sym.Name)
 					}
-					offset := int(addr) - segment.codeBase + loc.Add
-					if offset > 0x7FFFFFFF || offset < -0x80000000 {
+					//types and names are offsets from module.types, the data
+					//mapping, the code of methods from module.text
+					offset := int(addr) - segment.dataBase + loc.Add
+					if loc.Type == R_METHODOFF && !strings.HasPrefix(sym.Name, TypePrefix) {
+						offset = int(addr) - segment.codeBase + loc.Add
+					} else if strings.HasPrefix(sym.Name, TypePrefix) && (offset < 0 || offset >= segment.dataLen) {
+						offset = codeModule.hostTypeOff(uintptr(int(addr) + loc.Add))
+					}
+					if isOverflowInt32(offset) {
 						err = fmt.Errorf("symName:%s offset:%d is overflow!", sym.Name, offset)
+						overflow = true
 					}
-					binary.LittleEndian.PutUint32(segment.codeByte[segment.codeLen+loc.Offset:], uint32(offset))
-				case R_USEIFACE:
-					//nothing todo
-				case R_USEIFACEMETHOD:
-					//nothing todo
+					byteOrder.PutUint32(segment.dataByte[loc.Offset:], uint32(offset))
+				case R_USEIFACE, R_USEIFACEMETHOD, R_USEFIELD, R_USETYPE, R_KEEP:
+					//marker relocations, nothing todo
 				case R_ADDRCUOFF:
 					//nothing todo
 				default:
 					err = fmt.Errorf("unknown reloc type:%d sym:%s", loc.Type, sym.Name)
+					unsupported = true
 				}
 			}
 			if err != nil {
-				return err
+				//link the other relocations to report all the failures
+				link.failed(codeModule.failReloc(symbol, loc, addr, relocByte), overflow || segment.far != far, unsupported, err)
+				err = nil
+				continue
+			}
+			if addr != InvalidHandleValue || loc.Type == R_WEAKADDROFF {
+				codeModule.relocStats.add(loc.Type, segment.far != far, segment.offset-trampoline)
+			}
+			if codeModule.options.DumpFarRelocs && segment.far != far {
+				codeModule.dumpFarReloc(symbol, loc, addr, relocByte, before, trampoline)
 			}
 		}
 	}
-	return err
+	codeModule.relocStats.TrampolineBytes = segment.offset - segment.codeLen
+	codeModule.relocStats.TrampolineSpace = segment.maxLength - segment.codeLen
+	return link.err()
 }
 
 func (linker *Linker) addFuncTab(module *moduledata, _func *_func, symbolMap map[string]uintptr) (err error) {
@@ -558,6 +1081,8 @@ func (linker *Linker) addFuncTab(module *moduledata, _func *_func, symbolMap map
 		append2Slice(&module.pclntable, uintptr(unsafe.Pointer(&(Func.PCData[0]))), Uint32Size*int(_func.npcdata))
 	}
 
+	//funcdata follows pcdata aligned to PtrSize, see runtime.funcdata. on
+	//32-bit platforms pcdata always ends aligned and nothing is added
 	grow(&module.pclntable, alignof(len(module.pclntable), PtrSize))
 	if _func.nfuncdata > 0 {
 		append2Slice(&module.pclntable, uintptr(unsafe.Pointer(&Func.FuncData[0])), int(PtrSize*_func.nfuncdata))
@@ -571,16 +1096,19 @@ func (linker *Linker) buildModule(codeModule *CodeModule, symbolMap map[string]u
 	module := codeModule.module
 	module.pclntable = append(module.pclntable, linker.pclntable...)
 	module.minpc = uintptr(segment.codeBase)
-	module.maxpc = uintptr(segment.dataBase)
-	module.types = uintptr(segment.codeBase)
-	module.etypes = uintptr(segment.codeBase + segment.offset)
+	module.maxpc = uintptr(segment.codeBase + segment.codeLen)
+	module.types = uintptr(segment.dataBase)
+	module.etypes = uintptr(segment.dataBase + segment.dataLen)
 	module.text = uintptr(segment.codeBase)
-	module.etext = uintptr(segment.codeBase + len(linker.code))
+	module.etext = uintptr(segment.codeBase + segment.codeLen)
 	codeModule.stkmaps = linker.stkmaps // hold reference
+	linker.buildGCData(codeModule)
 
 	module.ftab = append(module.ftab, functab{funcoff: uintptr(len(module.pclntable)), entry: module.minpc})
 	for index, _func := range linker._func {
 		funcname := gostringnocopy(&linker.pclntable[_func.nameoff])
+		//the runtime reads _func through a pointer, its entry must be aligned
+		grow(&module.pclntable, alignof(len(module.pclntable), PtrSize))
 		module.ftab = append(module.ftab, functab{funcoff: uintptr(len(module.pclntable)), entry: uintptr(symbolMap[funcname])})
 		if err = linker.addFuncTab(module, &(linker._func[index]), symbolMap); err != nil {
 			return err
@@ -596,50 +1124,274 @@ func (linker *Linker) buildModule(codeModule *CodeModule, symbolMap map[string]u
 	modulesLock.Lock()
 	addModule(codeModule)
 	modulesLock.Unlock()
+	if codeModule.enabled(FeatureTypelinks) {
+		linker.buildTypelinks(codeModule, symbolMap)
+	}
 	additabs(codeModule.module)
 	moduledataverify1(codeModule.module)
 
 	return err
 }
 
-func Load(linker *Linker, symPtr map[string]uintptr) (codeModule *CodeModule, err error) {
+// checkLoad checks, before anything is mapped, that linker may be loaded
+// against symPtr with options.
+func (linker *Linker) checkLoad(symPtr map[string]uintptr, options LoadOptions) (err error) {
+	if err = checkFeatures(options.Features); err != nil {
+		return err
+	}
+	if err = checkArch(linker.Arch); err != nil {
+		return err
+	}
+	if err = linker.checkToolchain(); err != nil {
+		return err
+	}
+	if err = linker.checkModules(options.Modules); err != nil {
+		return err
+	}
+	if err = linker.checkPolicy(options.Symbols, options.StubDenied); err != nil {
+		return err
+	}
+	if err = linker.VerifyExterns(symPtr); err != nil {
+		return err
+	}
+	if err = Verify(linker, options.Verifiers...); err != nil {
+		return err
+	}
+	limits := SizeVerifier{MaxCodeBytes: options.MaxCodeBytes, MaxDataBytes: options.MaxDataBytes, MaxSymbols: options.MaxSymbols}
+	if err = limits.Verify(linker); err != nil {
+		return fmt.Errorf("goloader: %v", err)
+	}
+	return nil
+}
+
+func Load(linker *Linker, symPtr map[string]uintptr) (*CodeModule, error) {
+	return LoadWithOptions(linker, symPtr, LoadOptions{})
+}
+
+func LoadWithOptions(linker *Linker, symPtr map[string]uintptr, options LoadOptions) (codeModule *CodeModule, err error) {
 	codeModule = &CodeModule{
-		Syms:   make(map[string]uintptr),
-		module: &moduledata{typemap: make(map[typeOff]uintptr)},
+		Syms:     make(map[string]uintptr),
+		module:   &moduledata{typemap: make(map[typeOff]uintptr)},
+		types:    make(map[string]uintptr),
+		vars:     make(map[string]uintptr),
+		hash:     linker.Hash(),
+		degraded: linker.degraded,
+	}
+	partial := codeModule
+	defer func() {
+		if err != nil {
+			partial.releaseSharedData()
+			err = newLoadError(err, linker, symPtr, partial)
+		}
+	}()
+	codeModule.options = options
+	if err = linker.checkLoad(symPtr, options); err != nil {
+		Audit(AuditLoad, codeModule.hash, EmptyString, err)
+		return nil, err
+	}
+	codeModule.stubDenied(linker)
+	if err = linker.inflatePclntable(); err != nil {
+		Audit(AuditLoad, codeModule.hash, EmptyString, err)
+		return nil, err
+	}
+	codeModule.codeLen = linker.codeSize()
+	codeModule.dataLen = linker.dataSize()
+	//the trampolines follow the code in the code mapping, sized for the
+	//worst case of every relocation, or up to the size of the code if the
+	//mapping can grow
+	trampolines := linker.reserveTrampolines(codeModule)
+	growable := canGrowCode && !options.fullTrampolines && options.Shared == nil && options.HugePages == HugePagesOff
+	if growable && trampolines > codeModule.codeLen {
+		trampolines = codeModule.codeLen
+	}
+	codeModule.maxLength = alignof(codeModule.codeLen+trampolines, PageSize)
+	if limit := options.MaxCodeBytes; limit > 0 && codeModule.maxLength > limit {
+		//less trampoline space, relocations needing more fail the load
+		codeModule.maxLength = alignof(codeModule.codeLen, PageSize)
+		if limit&^(PageSize-1) > codeModule.maxLength {
+			codeModule.maxLength = limit &^ (PageSize - 1)
+		}
+	}
+	var codeByte []byte
+	if options.Shared != nil && options.Shared.Size > 0 {
+		codeByte, err = options.Shared.mapShared(codeModule.maxLength, codeModule.hash)
+	} else {
+		codeByte, err = codeModule.mmapCode(codeModule.maxLength)
+	}
+	if err != nil {
+		Audit(AuditLoad, codeModule.hash, EmptyString, err)
+		return nil, err
+	}
+	var dataByte []byte
+	dataLen := alignof(codeModule.dataLen, PageSize)
+	codeStart := (*sliceHeader)(unsafe.Pointer(&codeByte)).Data
+	if options.Shared != nil && options.Shared.DataBase != 0 {
+		dataByte, err = options.Shared.mapData(dataLen)
+	} else if dataByte = codeModule.mmapNear(dataLen, false, codeStart, codeStart+uintptr(len(codeByte))); dataByte == nil {
+		dataByte, err = MmapData(dataLen)
 	}
-	codeModule.codeLen = len(linker.code)
-	codeModule.dataLen = len(linker.data)
-	codeModule.maxLength = alignof((codeModule.codeLen+codeModule.dataLen)*2, PageSize)
-	codeByte, err := Mmap(codeModule.maxLength)
 	if err != nil {
+		Munmap(codeByte)
+		Audit(AuditLoad, codeModule.hash, EmptyString, err)
 		return nil, err
 	}
 
 	codeModule.codeByte = codeByte
+	codeModule.dataByte = dataByte
+	//unmap releases the mappings of a failed load, the code with the
+	//trampolines grown after it
+	unmap := func() {
+		mapping := codeByte
+		if options.Shared == nil {
+			mapping = codeModule.codeByte
+		}
+		Munmap(mapping)
+		Munmap(dataByte)
+	}
+	if options.NUMA != nil {
+		if err = mbind(codeByte, options.NUMA); err == nil {
+			err = mbind(dataByte, options.NUMA)
+		}
+		if err != nil {
+			unmap()
+			Audit(AuditLoad, codeModule.hash, EmptyString, err)
+			return nil, err
+		}
+	}
 	codeModule.codeBase = int((*sliceHeader)(unsafe.Pointer(&codeByte)).Data)
-	codeModule.dataBase = codeModule.codeBase + len(linker.code)
-	codeModule.offset = codeModule.codeLen + codeModule.dataLen
-	copy(codeModule.codeByte, linker.code)
-	copy(codeModule.codeByte[codeModule.codeLen:], linker.data)
+	codeModule.dataBase = int((*sliceHeader)(unsafe.Pointer(&dataByte)).Data)
+	codeModule.offset = codeModule.codeLen
+	if growable {
+		codeModule.grow = codeModule.growCode
+	}
+	if options.Shared != nil {
+		//relocate into a copy, which is written to or compared with the shared image
+		codeModule.codeByte = make([]byte, len(codeByte))
+	}
+	if err = jitBeginWrite(); err != nil {
+		unmap()
+		Audit(AuditLoad, codeModule.hash, EmptyString, err)
+		return nil, err
+	}
+	writing := true
+	endWrite := func() {
+		if writing {
+			writing = false
+			jitEndWrite(codeModule.codeByte)
+		}
+	}
+	defer endWrite()
+	//a linker opened from a container is inflated into the mappings
+	if err = linker.copyCode(codeModule.codeByte); err == nil {
+		err = linker.copyData(codeModule.dataByte)
+	}
+	if err != nil {
+		unmap()
+		Audit(AuditLoad, codeModule.hash, EmptyString, err)
+		return nil, err
+	}
 
 	var symbolMap map[string]uintptr
 	if symbolMap, err = linker.addSymbolMap(symPtr, codeModule); err == nil {
-		if err = linker.relocate(codeModule, symbolMap); err == nil {
+		linker.addTypeMap(symPtr, symbolMap, codeModule)
+		if err = linker.relocate(codeModule, symbolMap); err == nil && options.Shared != nil {
+			codeModule.codeByte, err = options.Shared.publish(codeByte, codeModule.codeByte, uintptr(codeModule.dataBase), codeModule.hash, linker.hostSymbols(symPtr))
+		}
+		if err == nil && options.KeepDWARF {
+			linker.relocateDWARF(codeModule, symbolMap)
+		}
+		if err == nil && codeModule.enabled(FeatureProtectRodata) {
+			if err = linker.protectRodata(codeModule); err == errProtectUnsupported {
+				err = nil
+			}
+		}
+		if err == nil {
 			if err = linker.buildModule(codeModule, symbolMap); err == nil {
-				if err = linker.doInitialize(codeModule, symbolMap); err == nil {
+				Audit(AuditLoad, codeModule.hash, EmptyString, nil)
+				codeModule.pendingInit = func() error {
+					err := linker.doInitialize(codeModule, symbolMap)
+					Audit(AuditInit, codeModule.hash, EmptyString, err)
+					return err
+				}
+				if options.Lock {
+					if lockErr := codeModule.lock(); lockErr != nil && options.LockFailed != nil {
+						options.LockFailed(lockErr)
+					}
+				}
+				endWrite()
+				if codeModule.enabled(FeatureWXorX) {
+					if err = codeModule.protectCode(protReadExec); err == errProtectUnsupported {
+						err = nil
+					} else if err != nil {
+						Audit(AuditLoad, codeModule.hash, EmptyString, err)
+					}
+				}
+				if err == nil && !options.DeferInit {
+					err = codeModule.Init()
+				}
+				if err == nil {
+					if options.CompactSymbols {
+						codeModule.symIndex = newSymbolIndex(codeModule.Syms)
+						codeModule.Syms = nil
+					}
 					return codeModule, err
 				}
+				//the module is registered with the runtime, remove it
+				//before its mappings are released
+				codeModule.unregister()
+				codeModule.unlock()
+				unmap()
+				return nil, err
 			}
 		}
 	}
+	endWrite()
+	unmap()
+	if codeModule.growFailed {
+		//the pages after the code are taken, load again with the trampolines
+		//reserved for the worst case
+		codeModule.releaseSharedData()
+		options.fullTrampolines = true
+		return LoadWithOptions(linker, symPtr, options)
+	}
+	Audit(AuditLoad, codeModule.hash, EmptyString, err)
 	return nil, err
 }
 
-func (cm *CodeModule) Unload() {
+// unregister removes the itabs and the module from the runtime, the
+// garbage collector does not scan its data once it returns.
+func (cm *CodeModule) unregister() {
 	removeitabs(cm.module)
 	runtime.GC()
 	modulesLock.Lock()
 	removeModule(cm.module)
 	modulesLock.Unlock()
+	//a cycle started before the module was removed may still scan its data,
+	//runtime.GC returns once it and a new cycle are done
+	runtime.GC()
+}
+
+func (cm *CodeModule) Unload() {
+	cm.unload()
+}
+
+// unload unmaps the module, unless a host function patched to it can not be
+// restored: the host still jumps into the module, it stays mapped.
+func (cm *CodeModule) unload() error {
+	if err := cm.unpatchAll(); err != nil {
+		Audit(AuditUnload, cm.hash, EmptyString, err)
+		return err
+	}
+	cm.releaseCallbacks()
+	cm.unregister()
+	cm.unlock()
 	Munmap(cm.codeByte)
+	Munmap(cm.dataByte)
+	cm.releaseSharedData()
+	cm.closeLibs()
+	cm.pinLock.Lock()
+	cm.pins = nil
+	cm.pinLock.Unlock()
+	Audit(AuditUnload, cm.hash, EmptyString, nil)
+	return nil
 }
@@ -0,0 +1,28 @@
+package goloader
+
+import (
+	"testing"
+)
+
+func TestSymAlign(t *testing.T) {
+	for _, test := range []struct {
+		size, align, want int
+	}{
+		//by size, at least PtrSize, at most MaxDataAlign
+		{1, 0, PtrSize},
+		{PtrSize, 0, PtrSize},
+		{16, 0, 16},
+		{24, 0, 16},
+		{32, 0, 32},
+		{1000, 0, MaxDataAlign},
+		//recorded in the object
+		{1, 1, PtrSize},
+		{8, 64, 64},
+		{1000, 8, 8},
+	} {
+		objsym := &ObjSymbol{Data: make([]byte, test.size), Align: test.align}
+		if got := symAlign(objsym); got != test.want {
+			t.Errorf("symAlign of %d bytes aligned to %d = %d, want %d", test.size, test.align, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,176 @@
+package goloader
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// EventBus delivers events published by the host or by modules to the
+// subscribers of a topic. Each topic has one event type, set by its first
+// subscriber or publisher, or by DefineTopic. A type of a module is the
+// type of the host if the module resolves it to the host, e.g. for the
+// types of a shared package registered with RegTypes. Subscriptions of a
+// module are removed when it is unloaded.
+//
+// Modules linked against goloader reach the bus of the host through a
+// package-level variable, e.g. DefaultEventBus.
+type EventBus struct {
+	lock   sync.RWMutex
+	topics map[string]*topic
+}
+
+// DefaultEventBus is an EventBus shared by the host and its modules.
+var DefaultEventBus = &EventBus{}
+
+type subscriber struct {
+	fn reflect.Value
+}
+
+type topic struct {
+	typ         reflect.Type
+	subscribers []*subscriber
+}
+
+// DefineTopic sets the event type of name to the type of sample.
+func (bus *EventBus) DefineTopic(name string, sample interface{}) error {
+	if sample == nil {
+		return fmt.Errorf("goloader: topic %s needs a typed sample", name)
+	}
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	_, err := bus.topic(name, reflect.TypeOf(sample))
+	return err
+}
+
+// topic returns the topic name, creating it with the event type typ.
+// bus.lock must be held.
+func (bus *EventBus) topic(name string, typ reflect.Type) (*topic, error) {
+	if bus.topics == nil {
+		bus.topics = make(map[string]*topic)
+	}
+	t, ok := bus.topics[name]
+	if !ok {
+		t = &topic{typ: typ}
+		bus.topics[name] = t
+	}
+	if t.typ != typ {
+		return nil, fmt.Errorf("goloader: topic %s carries %s, not %s", name, t.typ, typ)
+	}
+	return t, nil
+}
+
+// eventType returns the type of the events fn handles, fn must be a func
+// with one parameter and no results.
+func eventType(fnType reflect.Type) (reflect.Type, error) {
+	if fnType.Kind() != reflect.Func || fnType.NumIn() != 1 || fnType.NumOut() != 0 || fnType.IsVariadic() {
+		return nil, fmt.Errorf("goloader: subscriber %s is not a func(event)", fnType)
+	}
+	return fnType.In(0), nil
+}
+
+func (bus *EventBus) subscribe(name string, sub *subscriber) error {
+	typ, err := eventType(sub.fn.Type())
+	if err != nil {
+		return err
+	}
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	t, err := bus.topic(name, typ)
+	if err != nil {
+		return err
+	}
+	t.subscribers = append(t.subscribers, sub)
+	return nil
+}
+
+func (bus *EventBus) unsubscribe(name string, sub *subscriber) {
+	bus.lock.Lock()
+	defer bus.lock.Unlock()
+	if t, ok := bus.topics[name]; ok {
+		for i, s := range t.subscribers {
+			if s == sub {
+				t.subscribers = append(t.subscribers[:i:i], t.subscribers[i+1:]...)
+				break
+			}
+		}
+	}
+}
+
+// Subscribe calls fn, a func(T), with the events published on topic name.
+// The returned func removes the subscription.
+func (bus *EventBus) Subscribe(name string, fn interface{}) (unsubscribe func(), err error) {
+	v := reflect.ValueOf(fn)
+	if !v.IsValid() || v.Kind() != reflect.Func || v.IsNil() {
+		return nil, fmt.Errorf("goloader: Subscribe needs a func, got %T", fn)
+	}
+	sub := &subscriber{fn: v}
+	if err := bus.subscribe(name, sub); err != nil {
+		return nil, err
+	}
+	return func() { bus.unsubscribe(name, sub) }, nil
+}
+
+// SubscribeModule subscribes the function symbol of module to topic name,
+// its signature is taken from the type information of the object. The
+// subscription is removed when the module is unloaded, or by Release of
+// the returned callback.
+func (bus *EventBus) SubscribeModule(module *CodeModule, name, symbol string) (*Callback, error) {
+	return module.Bind(symbol, nil, &topicRegistry{bus: bus, name: name})
+}
+
+// topicRegistry adds the callbacks bound by SubscribeModule to a topic.
+type topicRegistry struct {
+	bus  *EventBus
+	name string
+	lock sync.Mutex
+	subs map[*Callback]*subscriber
+}
+
+func (r *topicRegistry) Register(cb *Callback) error {
+	sub := &subscriber{fn: cb.fn}
+	if err := r.bus.subscribe(r.name, sub); err != nil {
+		return err
+	}
+	r.lock.Lock()
+	defer r.lock.Unlock()
+	if r.subs == nil {
+		r.subs = make(map[*Callback]*subscriber)
+	}
+	r.subs[cb] = sub
+	return nil
+}
+
+func (r *topicRegistry) Unregister(cb *Callback) {
+	r.lock.Lock()
+	sub, ok := r.subs[cb]
+	delete(r.subs, cb)
+	r.lock.Unlock()
+	if ok {
+		r.bus.unsubscribe(r.name, sub)
+	}
+}
+
+// Publish calls the subscribers of topic name with event, in the order
+// they subscribed, and returns after the last one returned. The type of
+// event must be the event type of the topic.
+func (bus *EventBus) Publish(name string, event interface{}) error {
+	if event == nil {
+		return fmt.Errorf("goloader: nil event published on topic %s", name)
+	}
+	bus.lock.Lock()
+	t, err := bus.topic(name, reflect.TypeOf(event))
+	var subscribers []*subscriber
+	if err == nil {
+		subscribers = append(subscribers, t.subscribers...)
+	}
+	bus.lock.Unlock()
+	if err != nil {
+		return err
+	}
+	args := []reflect.Value{reflect.ValueOf(event)}
+	for _, sub := range subscribers {
+		sub.fn.Call(args)
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package main
+
+import (
+	"crypto/tls"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+)
+
+// loads net/http and crypto/tls from a module, a release is not shipped
+// unless this example runs:
+//
+//	go tool compile https.go
+//	./loader -o https.o -run main.main
+func main() {
+	client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{MinVersion: tls.VersionTLS12}}}
+	resp, err := client.Get("https://golang.org/")
+	if err != nil {
+		panic(err)
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		panic(err)
+	}
+	fmt.Println(resp.Status, resp.TLS.HandshakeComplete, len(body))
+}
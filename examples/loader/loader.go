@@ -1,6 +1,7 @@
 package main
 
 import (
+	"crypto/tls"
 	"flag"
 	"fmt"
 	"net/http"
@@ -8,6 +9,7 @@ import (
 	"runtime"
 	"strings"
 	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/pkujhd/goloader"
@@ -40,9 +42,17 @@ func main() {
 	var parseFile = flag.String("parse", "", "parse go object file")
 	var run = flag.String("run", "main.main", "run function")
 	var times = flag.Int("times", 1, "run count")
+	var doc = flag.Bool("doc", false, "print the exported API of the object files without loading them")
+	var diff = flag.Bool("diff", false, "print the functions and types changed between two bundles: -diff old.bundle new.bundle")
+	var chaos = flag.Int("chaos", 0, "load and unload the objects this many times while the GC runs and goroutines churn")
 
 	flag.Parse()
 
+	if *diff {
+		diffBundles(flag.Args())
+		return
+	}
+
 	if *parseFile != "" {
 		parse(parseFile, pkgpath)
 		return
@@ -67,6 +77,7 @@ func main() {
 		&http.Request{}, &http.Server{})
 	goloader.RegTypes(symPtr, runtime.LockOSThread, &w, w.Wait)
 	goloader.RegTypes(symPtr, fmt.Sprint)
+	goloader.RegTypes(symPtr, http.Get, &http.Client{}, &http.Transport{}, &tls.Config{}, tls.Dial)
 
 	linker, err := goloader.ReadObjs(files.File, files.PkgPath)
 	if err != nil {
@@ -74,6 +85,16 @@ func main() {
 		return
 	}
 
+	if *doc {
+		linker.Describe().Print(os.Stdout)
+		return
+	}
+
+	if *chaos > 0 {
+		chaosLoad(linker, symPtr, *chaos)
+		return
+	}
+
 	var mmapByte []byte
 	for i := 0; i < *times; i++ {
 		codeModule, err := goloader.Load(linker, symPtr)
@@ -126,3 +147,68 @@ func parse(file, pkgpath *string) {
 		return
 	}
 }
+
+func diffBundles(files []string) {
+	if len(files) != 2 {
+		flag.PrintDefaults()
+		return
+	}
+	linkers := make([]*goloader.Linker, 0, len(files))
+	for _, file := range files {
+		f, err := os.Open(file)
+		if err != nil {
+			fmt.Println(err)
+			return
+		}
+		linker, err := goloader.DecodeLinker(f)
+		f.Close()
+		if err != nil {
+			fmt.Printf("error reading %s: %v\n", file, err)
+			return
+		}
+		linkers = append(linkers, linker)
+	}
+	goloader.Diff(linkers[0], linkers[1]).Print(os.Stdout)
+}
+
+// chaosLoad loads and unloads linker n times while a goroutine
+// forces garbage collections and others allocate and exit, a load or an
+// unload deadlocking with the runtime hangs it, a module scanned after it
+// is unmapped crashes it.
+func chaosLoad(linker *goloader.Linker, symPtr map[string]uintptr, n int) {
+	var stop int32
+	churn := sync.WaitGroup{}
+	churn.Add(1)
+	go func() {
+		defer churn.Done()
+		for atomic.LoadInt32(&stop) == 0 {
+			runtime.GC()
+		}
+	}()
+	for i := 0; i < 8; i++ {
+		churn.Add(1)
+		go func() {
+			defer churn.Done()
+			for atomic.LoadInt32(&stop) == 0 {
+				done := make(chan []byte)
+				go func() { done <- make([]byte, 64<<10) }()
+				<-done
+			}
+		}()
+	}
+	failed := false
+	for i := 0; i < n; i++ {
+		codeModule, err := goloader.Load(linker, symPtr)
+		if err != nil {
+			fmt.Println("Load error:", err)
+			failed = true
+			break
+		}
+		codeModule.Unload()
+	}
+	atomic.StoreInt32(&stop, 1)
+	churn.Wait()
+	if !failed {
+		fmt.Println("chaos: ok")
+	}
+}
@@ -0,0 +1,250 @@
+package goloader
+
+import (
+	"bufio"
+	"debug/dwarf"
+	"debug/elf"
+	"debug/macho"
+	"debug/pe"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// kinds of Extern
+const (
+	ExternFunc = "func"
+	ExternVar  = "var"
+)
+
+// Extern is a function or a variable of the host a module expects, Type is
+// the signature of a function, e.g. "(string) (*net/http.Response, error)",
+// or the type of a variable, with the import paths of the named types.
+type Extern struct {
+	Kind string
+	Name string
+	Type string
+}
+
+func (e Extern) String() string {
+	return strings.TrimSpace(fmt.Sprintf("%s %s %s", e.Kind, e.Name, e.Type))
+}
+
+// ParseExterns reads an externs file, one "kind name type" declaration by
+// line, e.g. "func net/http.Get (string) (*net/http.Response, error)" or
+// "var os.Stdout *os.File". Empty lines and lines starting with # are skipped.
+func ParseExterns(r io.Reader) ([]Extern, error) {
+	externs := make([]Extern, 0)
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == EmptyString || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 3)
+		if len(fields) < 2 || (fields[0] != ExternFunc && fields[0] != ExternVar) {
+			return nil, fmt.Errorf("goloader: externs line %d: want \"func name signature\" or \"var name type\", got %q", line, text)
+		}
+		e := Extern{Kind: fields[0], Name: fields[1]}
+		if len(fields) == 3 {
+			e.Type = strings.TrimSpace(fields[2])
+		}
+		externs = append(externs, e)
+	}
+	return externs, scanner.Err()
+}
+
+// WriteExterns writes externs in the format read by ParseExterns.
+func WriteExterns(w io.Writer, externs []Extern) error {
+	for _, e := range externs {
+		if _, err := fmt.Fprintln(w, e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SetExterns records the functions and variables of the host the objects
+// expect, Encode ships them and VerifyExterns checks them.
+func (linker *Linker) SetExterns(externs []Extern) {
+	linker.externs = externs
+}
+
+// Externs returns the externs recorded by SetExterns.
+func (linker *Linker) Externs() []Extern {
+	return linker.externs
+}
+
+// VerifyExterns checks the externs the objects refer to against the host:
+// each must be in symPtr and, if the host has DWARF, have the recorded
+// signature or type. Externs the objects do not refer to, e.g. inlined
+// functions, are not checked. Load runs it before mapping the module.
+func (linker *Linker) VerifyExterns(symPtr map[string]uintptr) error {
+	if len(linker.externs) == 0 {
+		return nil
+	}
+	refs := make(map[string]bool)
+	for _, name := range linker.references() {
+		refs[name] = true
+	}
+	types, _ := hostTypes()
+	problems := make([]string, 0)
+	for _, e := range linker.externs {
+		if !refs[e.Name] {
+			continue
+		}
+		if _, ok := symPtr[e.Name]; !ok {
+			problems = append(problems, fmt.Sprintf("%s %s is missing", e.Kind, e.Name))
+			continue
+		}
+		if typ, ok := types[e.Name]; ok && e.Type != EmptyString && normalizeType(typ) != normalizeType(e.Type) {
+			problems = append(problems, fmt.Sprintf("%s %s is %s in the host, want %s", e.Kind, e.Name, typ, e.Type))
+		}
+	}
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("goloader: externs do not match the host:\n\t%s", strings.Join(problems, "\n\t"))
+	}
+	return nil
+}
+
+// normalizeType drops the spaces of a type, the DWARF of the host writes
+// "interface {}" where go/types writes "interface{}".
+func normalizeType(typ string) string {
+	return strings.Replace(typ, " ", EmptyString, -1)
+}
+
+var (
+	hostTypesOnce sync.Once
+	hostTypesMap  map[string]string
+	hostTypesErr  error
+)
+
+// hostTypes returns the signatures of the functions and the types of the
+// variables of the host, read once from its DWARF.
+func hostTypes() (map[string]string, error) {
+	hostTypesOnce.Do(func() {
+		var path string
+		if path, hostTypesErr = os.Executable(); hostTypesErr == nil {
+			hostTypesMap, hostTypesErr = readDWARFTypes(path)
+		}
+	})
+	return hostTypesMap, hostTypesErr
+}
+
+func openDWARF(path string) (*dwarf.Data, error) {
+	if f, err := elf.Open(path); err == nil {
+		defer f.Close()
+		return f.DWARF()
+	}
+	if f, err := macho.Open(path); err == nil {
+		defer f.Close()
+		return f.DWARF()
+	}
+	if f, err := pe.Open(path); err == nil {
+		defer f.Close()
+		return f.DWARF()
+	}
+	return nil, fmt.Errorf("goloader: %s is not an ELF, Mach-O or PE file", path)
+}
+
+// readDWARFTypes reads the go signatures of the functions and the types of
+// the package-level variables in the DWARF of the executable at path. The
+// results of a function are the parameters marked DW_AT_variable_parameter,
+// the receiver of a method is dropped. Functions the compiler may inline
+// are left out, only their availability is checked.
+func readDWARFTypes(path string) (map[string]string, error) {
+	data, err := openDWARF(path)
+	if err != nil {
+		return nil, err
+	}
+	typeNames := make(map[dwarf.Offset]string)
+	typeName := func(off dwarf.Offset) string {
+		if name, ok := typeNames[off]; ok {
+			return name
+		}
+		r := data.Reader()
+		r.Seek(off)
+		name := "?"
+		if entry, err := r.Next(); err == nil && entry != nil {
+			if s, ok := entry.Val(dwarf.AttrName).(string); ok {
+				name = s
+			}
+		}
+		typeNames[off] = name
+		return name
+	}
+	types := make(map[string]string)
+	r := data.Reader()
+	for {
+		entry, err := r.Next()
+		if err != nil {
+			return nil, err
+		}
+		if entry == nil {
+			break
+		}
+		name, _ := entry.Val(dwarf.AttrName).(string)
+		switch entry.Tag {
+		case dwarf.TagVariable:
+			if off, ok := entry.Val(dwarf.AttrType).(dwarf.Offset); ok && name != EmptyString {
+				types[name] = typeName(off)
+			}
+		case dwarf.TagSubprogram:
+			if !entry.Children {
+				continue
+			}
+			params, results := make([]string, 0), make([]string, 0)
+			for {
+				child, err := r.Next()
+				if err != nil {
+					return nil, err
+				}
+				if child == nil || child.Tag == 0 {
+					break
+				}
+				if child.Children {
+					r.SkipChildren()
+				}
+				if child.Tag != dwarf.TagFormalParameter {
+					continue
+				}
+				off, _ := child.Val(dwarf.AttrType).(dwarf.Offset)
+				if result, _ := child.Val(dwarf.AttrVarParam).(bool); result {
+					results = append(results, typeName(off))
+				} else {
+					params = append(params, typeName(off))
+				}
+			}
+			//the abstract function of an inlined function omits unnamed
+			//results, it has no reliable signature
+			if name == EmptyString || entry.Val(dwarf.AttrInline) != nil {
+				continue
+			}
+			if pkg := symbolPkg(name); pkg != EmptyString && strings.Contains(name[len(pkg)+1:], ".") && len(params) > 0 {
+				params = params[1:]
+			}
+			if _, ok := types[name]; !ok {
+				types[name] = funcSignature(params, results)
+			}
+			continue
+		}
+		if entry.Children && entry.Tag != dwarf.TagCompileUnit {
+			r.SkipChildren()
+		}
+	}
+	return types, nil
+}
+
+// funcSignature formats a signature as written in externs files, the
+// results are always parenthesized.
+func funcSignature(params, results []string) string {
+	signature := "(" + strings.Join(params, ", ") + ")"
+	if len(results) > 0 {
+		signature += " (" + strings.Join(results, ", ") + ")"
+	}
+	return signature
+}
@@ -0,0 +1,58 @@
+package goloader
+
+import (
+	"fmt"
+	"sort"
+)
+
+// Feature is a relocation or loading behavior which can be turned on or off
+// per Load with LoadOptions.Features. Feature names are stable, lowercase
+// and dash separated, report issues against them.
+type Feature struct {
+	Name    string
+	Doc     string
+	Default bool // enabled unless LoadOptions.Features says otherwise
+}
+
+const (
+	FeatureGCData        = "gc-data"
+	FeatureTypelinks     = "typelinks"
+	FeatureProtectRodata = "protect-rodata"
+	FeatureWXorX         = "w-xor-x"
+	FeatureNearHost      = "near-host"
+)
+
+var features = map[string]Feature{
+	FeatureGCData:        {Name: FeatureGCData, Doc: "garbage collector scans the variables of the module", Default: true},
+	FeatureTypelinks:     {Name: FeatureTypelinks, Doc: "reflect finds the unnamed composite types of the module", Default: true},
+	FeatureProtectRodata: {Name: FeatureProtectRodata, Doc: "pages of read-only data are made read only", Default: true},
+	FeatureWXorX:         {Name: FeatureWXorX, Doc: "code is read-execute after load, data is never executable", Default: true},
+	FeatureNearHost:      {Name: FeatureNearHost, Doc: "code and data are mapped near the text of the host, fewer relocations need trampolines", Default: true},
+}
+
+// ListFeatures returns the features known to Load sorted by name.
+func ListFeatures() []Feature {
+	list := make([]Feature, 0, len(features))
+	for _, feature := range features {
+		list = append(list, feature)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Name < list[j].Name })
+	return list
+}
+
+func checkFeatures(enabled map[string]bool) error {
+	for name := range enabled {
+		if _, ok := features[name]; !ok {
+			return fmt.Errorf("goloader: unknown feature %s", name)
+		}
+	}
+	return nil
+}
+
+// enabled reports whether the feature is on for the module.
+func (cm *CodeModule) enabled(name string) bool {
+	if on, ok := cm.options.Features[name]; ok {
+		return on
+	}
+	return features[name].Default
+}
@@ -0,0 +1,12 @@
+// +build go1.10
+
+package goloader
+
+import (
+	"cmd/objfile/dwarf"
+)
+
+// dwarfAbbrev returns the abbreviations of the DWARF the compiler emits.
+func dwarfAbbrev() []byte {
+	return append(dwarf.GetAbbrev(), 0)
+}
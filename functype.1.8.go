@@ -0,0 +1,9 @@
+// +build !go1.10
+
+package goloader
+
+// dwarfAbbrev returns nil, the signatures of functions are not recovered,
+// the abbreviations of the compiler are not exported.
+func dwarfAbbrev() []byte {
+	return nil
+}
@@ -0,0 +1,221 @@
+package goloader
+
+import (
+	"debug/dwarf"
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// the prefixes of the DWARF symbols the compiler emits for a function and
+// its types, and the suffix of the abstract function of an inlined one,
+// see $GOROOT/src/cmd/internal/dwarf/dwarf.go
+const (
+	dwarfInfoPrefix     = "go.info."
+	dwarfAbstractSuffix = "$abstract"
+)
+
+// funcSig is the signature of a function recovered from its DWARF: the
+// addresses of the go types of its parameters and results, in order.
+type funcSig struct {
+	in, out []uintptr
+	// the type.func of the function if the module or the host has it, it
+	// is exact, the DWARF does not tell a variadic parameter from a slice
+	funcType uintptr
+	args     uint32 // size of the arguments and results on the stack
+}
+
+// funcSigUnit is the compile unit holding the DWARF of the functions of a
+// module, the references to types are replaced by their index in refs.
+type funcSigUnit struct {
+	info   []byte
+	starts map[string]int // offset of the DIE of each function in info
+	refs   []string       // names of the referenced DWARF symbols
+}
+
+// addFuncSigs recovers the signatures of the functions of the module from
+// the DWARF of the objects, the compiler records a go type only for
+// package-level variables. The DIE of a function lists its parameters in
+// order, those of an inlined function are in its abstract function.
+func (linker *Linker) addFuncSigs(symPtr, symbolMap map[string]uintptr, codeModule *CodeModule) {
+	abbrev := dwarfAbbrev()
+	if abbrev == nil {
+		return
+	}
+	unit := &funcSigUnit{starts: make(map[string]int)}
+	//unit header: length, version, abbrev offset, address size
+	unit.info = make([]byte, 11)
+	refIndex := make(map[string]uint32)
+	for name, sym := range linker.symMap {
+		objsym, ok := linker.objsymbolMap[name]
+		if sym.Kind != STEXT || !ok || objsym.Func == nil {
+			continue
+		}
+		info, ok := linker.objsymbolMap[dwarfInfoPrefix+name+dwarfAbstractSuffix]
+		if !ok {
+			if info, ok = linker.objsymbolMap[dwarfInfoPrefix+name]; !ok {
+				continue
+			}
+		}
+		start := len(unit.info)
+		unit.starts[name] = start
+		unit.info = append(unit.info, info.Data...)
+		for _, loc := range info.Reloc {
+			if loc.Type != R_DWARFSECREF || loc.Size != Uint32Size || loc.Offset+loc.Size > len(info.Data) {
+				continue
+			}
+			index, ok := refIndex[loc.Sym.Name]
+			if !ok {
+				unit.refs = append(unit.refs, loc.Sym.Name)
+				index = uint32(len(unit.refs))
+				refIndex[loc.Sym.Name] = index
+			}
+			byteOrder.PutUint32(unit.info[start+loc.Offset:], index)
+		}
+	}
+	if len(unit.starts) == 0 {
+		return
+	}
+	byteOrder.PutUint32(unit.info, uint32(len(unit.info)-4))
+	byteOrder.PutUint16(unit.info[4:], 4)
+	unit.info[10] = byte(PtrSize)
+	data, err := dwarf.New(abbrev, nil, nil, unit.info, nil, nil, nil, nil)
+	if err != nil {
+		return
+	}
+	typeAddr := func(name string) (uintptr, bool) {
+		if addr, ok := symbolMap[name]; ok && addr != InvalidHandleValue {
+			return addr, true
+		}
+		addr, ok := symPtr[name]
+		return addr, ok
+	}
+	codeModule.funcSigs = make(map[string]*funcSig)
+	for name, start := range unit.starts {
+		in, out, ok := unit.params(data.Reader(), start)
+		if !ok {
+			continue
+		}
+		sig := &funcSig{args: linker.objsymbolMap[name].Func.Args}
+		var inNames, outNames []string
+		for _, params := range []struct {
+			refs  []uint32
+			addrs *[]uintptr
+			names *[]string
+		}{{in, &sig.in, &inNames}, {out, &sig.out, &outNames}} {
+			for _, ref := range params.refs {
+				typeName := TypePrefix + strings.TrimPrefix(unit.refs[ref-1], dwarfInfoPrefix)
+				typeName = strings.Replace(typeName, EmptyPkgPath, symbolPkg(name), -1)
+				addr, found := typeAddr(typeName)
+				if !found {
+					ok = false
+					break
+				}
+				*params.addrs = append(*params.addrs, addr)
+				*params.names = append(*params.names, strings.TrimPrefix(typeName, TypePrefix))
+			}
+		}
+		if !ok {
+			continue
+		}
+		//the type.func symbol spells the variadic parameter, if it exists
+		if len(inNames) > 0 && strings.HasPrefix(inNames[len(inNames)-1], "[]") {
+			variadic := append(append([]string(nil), inNames[:len(inNames)-1]...), "..."+strings.TrimPrefix(inNames[len(inNames)-1], "[]"))
+			sig.funcType, _ = typeAddr(TypePrefix + funcTypeName(variadic, outNames))
+		}
+		if sig.funcType == 0 {
+			sig.funcType, _ = typeAddr(TypePrefix + funcTypeName(inNames, outNames))
+		}
+		codeModule.funcSigs[name] = sig
+	}
+}
+
+// funcTypeName spells a func type as the compiler names its symbol.
+func funcTypeName(in, out []string) string {
+	name := "func(" + strings.Join(in, ", ") + ")"
+	switch len(out) {
+	case 0:
+	case 1:
+		name += " " + out[0]
+	default:
+		name += " (" + strings.Join(out, ", ") + ")"
+	}
+	return name
+}
+
+// params returns the indexes in refs of the types of the parameters and
+// the results of the function whose DIE is at start, results are variable
+// parameters.
+func (unit *funcSigUnit) params(r *dwarf.Reader, start int) (in, out []uint32, ok bool) {
+	r.Seek(dwarf.Offset(start))
+	entry, err := r.Next()
+	if err != nil || entry == nil || entry.Tag != dwarf.TagSubprogram {
+		return nil, nil, false
+	}
+	if !entry.Children {
+		return nil, nil, true
+	}
+	for depth := 1; depth > 0; {
+		entry, err = r.Next()
+		if err != nil || entry == nil {
+			return nil, nil, false
+		}
+		if entry.Tag == 0 {
+			depth--
+			continue
+		}
+		if depth == 1 && entry.Tag == dwarf.TagFormalParameter {
+			ref, isRef := entry.Val(dwarf.AttrType).(dwarf.Offset)
+			if !isRef || ref == 0 || int(ref) > len(unit.refs) {
+				return nil, nil, false
+			}
+			if result, _ := entry.Val(dwarf.AttrVarParam).(bool); result {
+				out = append(out, uint32(ref))
+			} else {
+				in = append(in, uint32(ref))
+			}
+		}
+		if entry.Children {
+			depth++
+		}
+	}
+	return in, out, true
+}
+
+// funcSigType returns the type of the function name recovered from its
+// DWARF. The parameters must fill the arguments of the function on the
+// stack, the compiler omits blank ones from the DWARF.
+func (cm *CodeModule) funcSigType(name string) (reflect.Type, error) {
+	sig, ok := cm.funcSigs[name]
+	if !ok {
+		return nil, fmt.Errorf("goloader: no type information for %s, its object has no DWARF, use CallFunc", name)
+	}
+	if sig.funcType != 0 {
+		return toType(sig.funcType), nil
+	}
+	in := make([]reflect.Type, len(sig.in))
+	for i, addr := range sig.in {
+		in[i] = toType(addr)
+	}
+	out := make([]reflect.Type, len(sig.out))
+	for i, addr := range sig.out {
+		out[i] = toType(addr)
+	}
+	if size := argsSize(in, out); size != uintptr(sig.args) {
+		return nil, fmt.Errorf("goloader: the DWARF of %s gives %d bytes of arguments, the function has %d, use CallFunc", name, size, sig.args)
+	}
+	return reflect.FuncOf(in, out, false), nil
+}
+
+// argsSize returns the size of the arguments and results of a function on
+// the stack, the results start at a pointer aligned offset.
+func argsSize(in, out []reflect.Type) uintptr {
+	size := uintptr(0)
+	for _, params := range [][]reflect.Type{in, out} {
+		for _, t := range params {
+			size = uintptr(alignof(int(size), t.Align())) + t.Size()
+		}
+		size = uintptr(alignof(int(size), PtrSize))
+	}
+	return size
+}
@@ -0,0 +1,83 @@
+package goloader
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"unsafe"
+)
+
+// FuzzParse parses data as an object file, for go test -fuzz:
+//
+//	func FuzzParse(f *testing.F) {
+//		f.Fuzz(func(t *testing.T, data []byte) { goloader.FuzzParse(data) })
+//	}
+//
+// A malformed object is an error, a panic is a bug.
+func FuzzParse(data []byte) error {
+	_, err := ReadObjBytes(data, DefaultPkgPath)
+	return err
+}
+
+// FuzzLoadDryRun parses data and lays out and relocates the module against
+// symPtr, as Load with options does, in heap memory: nothing is mapped
+// executable, registered to the runtime or run. Give it the symPtr and
+// options deployed, e.g. built by RegSymbol.
+func FuzzLoadDryRun(data []byte, symPtr map[string]uintptr, options LoadOptions) error {
+	linker, err := ReadObjBytes(data, DefaultPkgPath)
+	if err != nil {
+		return err
+	}
+	_, err = linker.dryRun(symPtr, options)
+	return err
+}
+
+// dryRun lays out and relocates the module in heap memory, the module is
+// returned with its relocation statistics even if relocating fails.
+func (linker *Linker) dryRun(symPtr map[string]uintptr, options LoadOptions) (*CodeModule, error) {
+	codeModule := &CodeModule{
+		Syms:   make(map[string]uintptr),
+		module: &moduledata{typemap: make(map[typeOff]uintptr)},
+		types:  make(map[string]uintptr),
+		vars:   make(map[string]uintptr),
+		hash:   linker.Hash(),
+		dryRun: true,
+	}
+	codeModule.options = options
+	if err := checkFeatures(options.Features); err != nil {
+		return nil, err
+	}
+	if err := linker.inflate(); err != nil {
+		return nil, err
+	}
+	codeModule.codeLen = len(linker.code)
+	codeModule.dataLen = len(linker.data)
+	codeModule.maxLength = alignof(codeModule.codeLen+linker.reserveTrampolines(codeModule), PageSize)
+	codeModule.codeByte = make([]byte, codeModule.maxLength)
+	codeModule.dataByte = make([]byte, alignof(codeModule.dataLen, PageSize))
+	codeModule.codeBase = int((*sliceHeader)(unsafe.Pointer(&codeModule.codeByte)).Data)
+	codeModule.dataBase = int((*sliceHeader)(unsafe.Pointer(&codeModule.dataByte)).Data)
+	codeModule.offset = codeModule.codeLen
+	copy(codeModule.codeByte, linker.code)
+	copy(codeModule.dataByte, linker.data)
+	symbolMap, err := linker.addSymbolMap(symPtr, codeModule)
+	if err != nil {
+		return codeModule, err
+	}
+	linker.addTypeMap(symPtr, symbolMap, codeModule)
+	return codeModule, linker.relocate(codeModule, symbolMap)
+}
+
+// WriteCorpusFile writes data as a seed corpus entry of go test -fuzz in
+// dir, e.g. testdata/fuzz/FuzzParse, and returns the path of the entry.
+// Seed the corpus with the object files deployed.
+func WriteCorpusFile(dir string, data []byte) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return EmptyString, err
+	}
+	path := filepath.Join(dir, fmt.Sprintf("%x", sha256.Sum256(data))[:16])
+	entry := fmt.Sprintf("go test fuzz v1\n[]byte(%q)\n", data)
+	return path, ioutil.WriteFile(path, []byte(entry), 0644)
+}
@@ -0,0 +1,54 @@
+package goloader
+
+import (
+	"unsafe"
+)
+
+// see runtime/typekind.go
+const kindGCProg = 1 << 6
+
+//go:linkname progToPointerMask runtime.progToPointerMask
+func progToPointerMask(prog *byte, size uintptr) bitvector
+
+// buildGCData marks the pointer words of the variables in the data segment
+// of the module. The garbage collector scans module.data to module.edata
+// with gcdatamask as it scans the data section of the host, so heap objects
+// referenced only by module globals are kept alive.
+func (linker *Linker) buildGCData(codeModule *CodeModule) {
+	segment := &codeModule.segment
+	module := codeModule.module
+	words := (segment.dataLen + PtrSize - 1) / PtrSize
+	//modulesinit builds gcdatamask from gcdata if it is empty, keep it not empty
+	if !codeModule.enabled(FeatureGCData) {
+		codeModule.gcdata = make([]byte, 1)
+		module.gcdatamask = bitvector{n: 0, bytedata: &codeModule.gcdata[0]}
+		return
+	}
+	mask := make([]byte, (words+7)/8+1)
+	for name, addr := range codeModule.vars {
+		typ := codeModule.types[name]
+		offset := int(addr) - segment.dataBase
+		if typ == 0 || offset < 0 || offset >= segment.dataLen {
+			//no type information, or a variable of the host
+			continue
+		}
+		t := (*_type)(adduintptr(typ, 0))
+		if t.ptrdata == 0 {
+			continue
+		}
+		bits := t.gcdata
+		if t.kind&kindGCProg != 0 {
+			bits = progToPointerMask(t.gcdata, t.ptrdata).bytedata
+		}
+		base := offset / PtrSize
+		for i := 0; i < int(t.ptrdata)/PtrSize && base+i < words; i++ {
+			if *(*byte)(add(unsafe.Pointer(bits), uintptr(i/8)))>>uint(i%8)&1 != 0 {
+				mask[(base+i)/8] |= 1 << uint((base+i)%8)
+			}
+		}
+	}
+	module.data = uintptr(segment.dataBase)
+	module.edata = uintptr(segment.dataBase + segment.dataLen)
+	module.gcdatamask = bitvector{n: int32(words), bytedata: &mask[0]}
+	codeModule.gcdata = mask // hold reference
+}
@@ -0,0 +1,51 @@
+package goloader
+
+import (
+	"reflect"
+	"testing"
+	"unsafe"
+)
+
+func TestBuildGCData(t *testing.T) {
+	type pointers struct {
+		a int
+		p *int
+		b int
+		q *int
+	}
+	typeAddr := func(typ reflect.Type) uintptr {
+		return uintptr((*emptyInterface)(unsafe.Pointer(&typ)).word)
+	}
+	cm := &CodeModule{
+		module: &moduledata{},
+		types:  make(map[string]uintptr),
+		vars:   make(map[string]uintptr),
+	}
+	cm.dataBase, cm.dataLen = 0x10000, 16*PtrSize
+	//words 2 to 5, pointers at 3 and 5
+	cm.vars["main.p"] = uintptr(cm.dataBase + 2*PtrSize)
+	cm.types["main.p"] = typeAddr(reflect.TypeOf(pointers{}))
+	//word 8, no pointers
+	cm.vars["main.n"] = uintptr(cm.dataBase + 8*PtrSize)
+	cm.types["main.n"] = typeAddr(reflect.TypeOf(0))
+	//word 9, a pointer without type information is not marked
+	cm.vars["main.u"] = uintptr(cm.dataBase + 9*PtrSize)
+	//a variable of the host is not marked
+	cm.vars["main.h"] = 0x100
+	cm.types["main.h"] = typeAddr(reflect.TypeOf((*int)(nil)))
+
+	(&Linker{}).buildGCData(cm)
+	mask := cm.module.gcdatamask
+	if mask.n != 16 {
+		t.Fatalf("gcdatamask has %d words, want 16", mask.n)
+	}
+	for i := 0; i < int(mask.n); i++ {
+		marked := cm.gcdata[i/8]>>uint(i%8)&1 != 0
+		if want := i == 3 || i == 5; marked != want {
+			t.Errorf("word %d marked %v, want %v", i, marked, want)
+		}
+	}
+	if cm.module.data != uintptr(cm.dataBase) || cm.module.edata != uintptr(cm.dataBase+cm.dataLen) {
+		t.Errorf("module data is 0x%x-0x%x, want 0x%x-0x%x", cm.module.data, cm.module.edata, cm.dataBase, cm.dataBase+cm.dataLen)
+	}
+}
@@ -0,0 +1,51 @@
+package goloader
+
+// gotEntry returns the offset in the code mapping of the GOT entry holding
+// addr, the address of the symbol name. The entries are allocated in the
+// trampolines the first time a symbol is referred to through the GOT, from
+// is the offset of the reference.
+func (seg *segment) gotEntry(name string, addr uintptr, from int) (int, error) {
+	if entry, ok := seg.got[name]; ok {
+		return entry, nil
+	}
+	seg.offset = alignof(seg.offset, PtrSize)
+	if err := seg.reserve(PtrSize, from); err != nil {
+		return 0, err
+	}
+	if seg.got == nil {
+		seg.got = make(map[string]int)
+	}
+	entry := seg.offset
+	seg.got[name] = entry
+	putAddressAddOffset(seg.codeByte, &seg.offset, uint64(addr))
+	return entry, nil
+}
+
+// relocateGOTPCREL relocates the 32-bit PC-relative offset of a MOVQ
+// sym@GOT(SB) to the GOT entry of the symbol.
+func relocateGOTPCREL(addr uintptr, loc Reloc, segment *segment, relocByte []byte, addrBase int) error {
+	entry, err := segment.gotEntry(loc.Sym.Name, addr, addrBase-segment.codeBase+loc.Offset)
+	if err != nil {
+		return err
+	}
+	offset := segment.codeBase + entry - (addrBase + loc.Offset + loc.Size) + loc.Add
+	byteOrder.PutUint32(relocByte[loc.Offset:], uint32(offset))
+	return nil
+}
+
+// relocateARM64GOTPCREL relocates the ADRP and the 64-bit LDR loading the
+// GOT entry of the symbol, the entry is aligned so its offset in the page
+// is scaled by 8.
+func relocateARM64GOTPCREL(addr uintptr, loc Reloc, segment *segment) error {
+	entry, err := segment.gotEntry(loc.Sym.Name, addr, loc.Offset)
+	if err != nil {
+		return err
+	}
+	offset := uint64(int64(segment.codeBase+entry+loc.Add) - (int64(segment.codeBase+loc.Offset) &^ 0xFFF))
+	low := (uint32((offset>>12)&3) << 29) | (uint32((offset>>12>>2)&0x7FFFF) << 5)
+	high := uint32(offset&0xFFF) >> 3 << 10
+	value := byteOrder.Uint64(segment.codeByte[loc.Offset:])
+	value = (uint64(uint32(value>>32)|high) << 32) | uint64(uint32(value&0xFFFFFFFF)|low)
+	byteOrder.PutUint64(segment.codeByte[loc.Offset:], value)
+	return nil
+}
@@ -0,0 +1,45 @@
+package goloader
+
+import (
+	"unsafe"
+)
+
+const (
+	// distance from the host within which mmapNear places a mapping, 32-bit
+	// relative offsets reach 2GB and the host and the module take some of it
+	hintRange = 1 << 30
+	// addresses tried by mmapNear are aligned to hintStride
+	hintStride = 1 << 24
+)
+
+// mmapNear maps size bytes within hintRange of [start, end), e.g. the text
+// of the host, so the 32-bit relative relocations between them need no
+// trampoline. It tries free ranges above end and below start alternately,
+// and returns nil with FeatureNearHost off, on 32-bit platforms, where
+// every address is in range, or if no range is found.
+func (cm *CodeModule) mmapNear(size int, exec bool, start, end uintptr) []byte {
+	if !cm.enabled(FeatureNearHost) || PtrSize == Uint32Size {
+		return nil
+	}
+	stride := uintptr(alignof(size, hintStride))
+	above := uintptr(alignof(int(end), hintStride))
+	below := start &^ (hintStride - 1)
+	for i := uintptr(0); i*stride < hintRange; i++ {
+		hints := []uintptr{above + i*stride}
+		if below > (i+1)*stride {
+			hints = append(hints, below-(i+1)*stride)
+		}
+		for _, hint := range hints {
+			b, err := mmapAt(size, hint, exec)
+			if err != nil {
+				continue
+			}
+			base := uintptr(unsafe.Pointer(&b[0]))
+			if base+hintRange >= start && base+uintptr(size) <= end+hintRange {
+				return b
+			}
+			Munmap(b)
+		}
+	}
+	return nil
+}
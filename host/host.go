@@ -0,0 +1,322 @@
+// Package host runs plugins built as goloader modules with the patterns
+// goloader recommends: a registry of named plugins, typed lookup of their
+// functions, draining the calls running on a module before it is unloaded,
+// swapping a plugin for a new build, running modules as background
+// services and counters for monitoring.
+//
+// A function looked up in a plugin must only be called until the release
+// func returned with it is called, a swap or unload waits for it:
+//
+//	var encode func(v interface{}) ([]byte, error)
+//	release, err := h.Plugin("codec").Lookup("codec.Encode", &encode)
+//	if err != nil {
+//		return err
+//	}
+//	defer release()
+//	return encode(v)
+package host
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"sync"
+	"sync/atomic"
+
+	"github.com/pkujhd/goloader"
+)
+
+// Metrics counts the operations of a Host.
+type Metrics struct {
+	Loads        int64 // modules loaded, including swaps
+	LoadFailures int64 // failed loads, the plugin kept its module
+	Swaps        int64 // plugins given a new module
+	Unloads      int64 // modules unloaded
+	Calls        int64 // lookups released
+	InFlight     int64 // lookups not released yet
+}
+
+// Host is a registry of plugins, it is safe for concurrent use.
+type Host struct {
+	metrics Metrics // first for the alignment of atomic operations
+
+	// Options are used to load every module.
+	Options goloader.LoadOptions
+
+	lock    sync.Mutex
+	symbols *goloader.SymbolTable
+	plugins map[string]*Plugin
+}
+
+// New returns a Host whose modules are linked against the symbols of the
+// running executable.
+func New() (*Host, error) {
+	symbols, err := goloader.HostSymbolTable()
+	if err != nil {
+		return nil, err
+	}
+	return NewWithTable(symbols), nil
+}
+
+// NewWithSymbols returns a Host whose modules are linked against a copy
+// of symPtr, e.g. to add RegTypes of the host.
+func NewWithSymbols(symPtr map[string]uintptr) *Host {
+	symbols := goloader.NewSymbolTable()
+	symbols.Merge(symPtr)
+	return NewWithTable(symbols)
+}
+
+// NewWithTable returns a Host whose modules are linked against a snapshot
+// of symbols taken by each load, symbols may be registered concurrently.
+func NewWithTable(symbols *goloader.SymbolTable) *Host {
+	return &Host{symbols: symbols, plugins: make(map[string]*Plugin)}
+}
+
+// Metrics returns a snapshot of the counters of h.
+func (h *Host) Metrics() Metrics {
+	return Metrics{
+		Loads:        atomic.LoadInt64(&h.metrics.Loads),
+		LoadFailures: atomic.LoadInt64(&h.metrics.LoadFailures),
+		Swaps:        atomic.LoadInt64(&h.metrics.Swaps),
+		Unloads:      atomic.LoadInt64(&h.metrics.Unloads),
+		Calls:        atomic.LoadInt64(&h.metrics.Calls),
+		InFlight:     atomic.LoadInt64(&h.metrics.InFlight),
+	}
+}
+
+// Load loads linker as the plugin name. If the plugin exists it is swapped
+// to the new module: new lookups use it, Load waits for the lookups of the
+// previous module to be released and unloads it. If loading fails the
+// plugin keeps its module.
+func (h *Host) Load(name string, linker *goloader.Linker) (*Plugin, error) {
+	return h.load(name, linker, nil)
+}
+
+// LoadService is like Load, the module is run as service: it is started
+// after its packages are initialized and stopped before it is unloaded.
+// On a swap the service of the previous module is stopped before the new
+// one is started, if the new one fails to start the previous one is
+// started again and keeps the plugin.
+func (h *Host) LoadService(name string, linker *goloader.Linker, service Service) (*Plugin, error) {
+	return h.load(name, linker, &service)
+}
+
+func (h *Host) load(name string, linker *goloader.Linker, service *Service) (*Plugin, error) {
+	h.lock.Lock()
+	module, err := goloader.LoadWithTable(linker, h.symbols, h.Options)
+	if err != nil {
+		h.lock.Unlock()
+		atomic.AddInt64(&h.metrics.LoadFailures, 1)
+		return nil, fmt.Errorf("host: load %s: %v", name, err)
+	}
+	atomic.AddInt64(&h.metrics.Loads, 1)
+	next := &generation{module: module, service: service}
+	p, ok := h.plugins[name]
+	if !ok {
+		defer h.lock.Unlock()
+		if err := next.start(); err != nil {
+			h.release(next)
+			return nil, fmt.Errorf("host: start %s: %v", name, err)
+		}
+		p = &Plugin{name: name, host: h, current: next}
+		h.plugins[name] = p
+		return p, nil
+	}
+	p.loadLock.Lock()
+	h.lock.Unlock()
+	defer p.loadLock.Unlock()
+
+	prev := p.get()
+	if prev != nil {
+		prev.stop()
+	}
+	if err := next.start(); err != nil {
+		if prev != nil {
+			if restartErr := prev.start(); restartErr != nil {
+				err = fmt.Errorf("%v, restart of the previous module: %v", err, restartErr)
+			}
+		}
+		h.release(next)
+		return nil, fmt.Errorf("host: start %s: %v", name, err)
+	}
+	p.swap(next)
+	atomic.AddInt64(&h.metrics.Swaps, 1)
+	if prev != nil {
+		goloader.Audit(goloader.AuditSwap, module.Hash(), prev.module.Hash(), nil)
+		h.release(prev)
+	}
+	return p, nil
+}
+
+// Plugin returns the plugin name, or nil if it is not loaded.
+func (h *Host) Plugin(name string) *Plugin {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	return h.plugins[name]
+}
+
+// Plugins returns the sorted names of the loaded plugins.
+func (h *Host) Plugins() []string {
+	h.lock.Lock()
+	defer h.lock.Unlock()
+	names := make([]string, 0, len(h.plugins))
+	for name := range h.plugins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Unload removes the plugin name, waits for its lookups to be released
+// and unloads its module.
+func (h *Host) Unload(name string) error {
+	h.lock.Lock()
+	p, ok := h.plugins[name]
+	if !ok {
+		h.lock.Unlock()
+		return fmt.Errorf("host: plugin %s not loaded", name)
+	}
+	delete(h.plugins, name)
+	p.loadLock.Lock()
+	h.lock.Unlock()
+	defer p.loadLock.Unlock()
+	if prev := p.swap(nil); prev != nil {
+		prev.stop()
+		h.release(prev)
+	}
+	return nil
+}
+
+// release waits for the lookups of g and unloads its module.
+func (h *Host) release(g *generation) {
+	g.inflight.Wait()
+	g.module.Unload()
+	atomic.AddInt64(&h.metrics.Unloads, 1)
+}
+
+// Service names the functions of a module run as a background service,
+// e.g. Service{Start: "main.Start", Stop: "main.Stop"} for
+//
+//	func Start(ctx context.Context) error
+//	func Stop()
+//
+// Start returns once the service runs, ctx is canceled when the service is
+// stopped. Stop is optional, it returns once the goroutines of the service
+// exited, the module is unloaded after it.
+type Service struct {
+	Start string
+	Stop  string
+}
+
+type generation struct {
+	module   *goloader.CodeModule
+	inflight sync.WaitGroup
+	service  *Service
+	cancel   context.CancelFunc
+	stopFunc func()
+}
+
+// start initializes the module and starts its service, if any.
+func (g *generation) start() error {
+	if g.service == nil {
+		return nil
+	}
+	if err := g.module.Init(); err != nil {
+		return err
+	}
+	var start func(ctx context.Context) error
+	if err := g.module.LookupFunc(g.service.Start, &start); err != nil {
+		return err
+	}
+	var stop func()
+	if g.service.Stop != "" {
+		if err := g.module.LookupFunc(g.service.Stop, &stop); err != nil {
+			return err
+		}
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	if err := start(ctx); err != nil {
+		cancel()
+		return err
+	}
+	g.cancel, g.stopFunc = cancel, stop
+	return nil
+}
+
+// stop stops the service of the module, if it runs.
+func (g *generation) stop() {
+	if g.cancel == nil {
+		return
+	}
+	g.cancel()
+	if g.stopFunc != nil {
+		g.stopFunc()
+	}
+	g.cancel, g.stopFunc = nil, nil
+}
+
+// Plugin is a named module of a Host.
+type Plugin struct {
+	name     string
+	host     *Host
+	loadLock sync.Mutex // serializes swaps and unload
+	lock     sync.RWMutex
+	current  *generation
+}
+
+// Name returns the name of p.
+func (p *Plugin) Name() string {
+	return p.name
+}
+
+func (p *Plugin) get() *generation {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	return p.current
+}
+
+func (p *Plugin) swap(next *generation) *generation {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	prev := p.current
+	p.current = next
+	return prev
+}
+
+// Acquire returns the current module of p, it is not unloaded until
+// release is called.
+func (p *Plugin) Acquire() (module *goloader.CodeModule, release func(), err error) {
+	p.lock.RLock()
+	g := p.current
+	if g == nil {
+		p.lock.RUnlock()
+		return nil, nil, fmt.Errorf("host: plugin %s unloaded", p.name)
+	}
+	g.inflight.Add(1)
+	p.lock.RUnlock()
+	atomic.AddInt64(&p.host.metrics.InFlight, 1)
+	var once sync.Once
+	release = func() {
+		once.Do(func() {
+			atomic.AddInt64(&p.host.metrics.InFlight, -1)
+			atomic.AddInt64(&p.host.metrics.Calls, 1)
+			g.inflight.Done()
+		})
+	}
+	return g.module, release, nil
+}
+
+// Lookup sets *fnPtr to the function symbol of the current module of p,
+// see goloader.CodeModule.LookupFunc. The function must not be called
+// after release.
+func (p *Plugin) Lookup(symbol string, fnPtr interface{}) (release func(), err error) {
+	module, release, err := p.Acquire()
+	if err != nil {
+		return nil, err
+	}
+	if err := module.LookupFunc(symbol, fnPtr); err != nil {
+		release()
+		return nil, fmt.Errorf("host: plugin %s: %v", p.name, err)
+	}
+	return release, nil
+}
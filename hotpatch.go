@@ -0,0 +1,190 @@
+package goloader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"reflect"
+	"runtime"
+	"sync"
+	"unsafe"
+)
+
+// Patch is a host function whose prologue jumps to a function of a module,
+// see CodeModule.HotPatch.
+type Patch struct {
+	Host   string  // name of the host function
+	Symbol string  // name of the module function
+	Entry  uintptr // entry of the host function
+	saved  []byte  // bytes of the prologue overwritten
+	module *CodeModule
+}
+
+// patches applied to the host
+var (
+	patches   []*Patch
+	patchLock sync.Mutex
+)
+
+// jumpCode returns the code jumping from the entry of a host function at
+// pc to target.
+func jumpCode(pc, target uintptr) ([]byte, error) {
+	switch runtime.GOARCH {
+	case "amd64":
+		code := make([]byte, len(x86amd64JMPLcode)+PtrSize)
+		copy(code, x86amd64JMPLcode)
+		putAddress(code[len(x86amd64JMPLcode):], uint64(target))
+		return code, nil
+	case "386":
+		//JMP rel32 reaches the whole address space
+		code := []byte{0xE9, 0x00, 0x00, 0x00, 0x00}
+		byteOrder.PutUint32(code[1:], uint32(target-(pc+uintptr(len(code)))))
+		return code, nil
+	case "arm64":
+		if runtime.GOOS == "darwin" {
+			return nil, errors.New("goloader: hot patching is not supported on darwin/arm64, the hardened runtime keeps the host text read-only")
+		}
+		code := make([]byte, len(arm64code)+PtrSize)
+		copy(code, arm64code)
+		putAddress(code[len(arm64code):], uint64(target))
+		return code, nil
+	case "arm":
+		code := make([]byte, len(armcode)+PtrSize)
+		copy(code, armcode)
+		putAddress(code[len(armcode):], uint64(target))
+		return code, nil
+	}
+	return nil, fmt.Errorf("goloader: hot patching is not supported on %s", runtime.GOARCH)
+}
+
+// writeHostCode writes code at pc in the text of the host, making its
+// pages writable while it is written.
+func writeHostCode(pc uintptr, code []byte) error {
+	pageSize := uintptr(os.Getpagesize())
+	start := pc &^ (pageSize - 1)
+	pages := sliceOf(unsafe.Pointer(start), alignof(int(pc-start)+len(code), int(pageSize)))
+	//the pages stay executable, other goroutines may run their code
+	if err := protect(pages, 0, len(pages), protReadWriteExec); err != nil {
+		if err == errProtectUnsupported {
+			return errors.New("goloader: hot patching needs memory protection")
+		}
+		return err
+	}
+	if err := jitBeginWrite(); err != nil {
+		return err
+	}
+	target := sliceOf(unsafe.Pointer(pc), len(code))
+	copy(target, code)
+	jitEndWrite(target)
+	return protect(pages, 0, len(pages), protReadExec)
+}
+
+// HotPatch rewrites the prologue of hostFunc, a func value of a host
+// function, with a jump to the function held by symbol, a package-level
+// func variable of the module, e.g. var Fixed = fixed, so every call of the
+// host function runs the replacement without restarting the process. The
+// signature is checked against the type information of the variable, see
+// checkFuncType; the variable must hold a function declared at package
+// level, the jump does not pass the context of a closure. Unpatch restores
+// the host function, Unload unpatches the patches of the module first.
+// Calls which the compiler inlined into their callers are not redirected,
+// build the host with -gcflags=-l for the functions to patch. A goroutine
+// running the prologue while it is rewritten may crash, patch while the
+// host function is not running.
+func (cm *CodeModule) HotPatch(hostFunc interface{}, symbol string) (*Patch, error) {
+	host := reflect.ValueOf(hostFunc)
+	if host.Kind() != reflect.Func || host.IsNil() {
+		return nil, fmt.Errorf("goloader: hot patch %s: %T is not a func", symbol, hostFunc)
+	}
+	if err := cm.checkFuncType(symbol, host.Type()); err != nil {
+		return nil, fmt.Errorf("goloader: hot patch: %v", err)
+	}
+	fn, err := cm.funcValue(symbol, host.Type())
+	if err != nil {
+		return nil, err
+	}
+	target := fn.Pointer()
+	if !cm.Contains(target) {
+		return nil, fmt.Errorf("goloader: hot patch: %s does not hold a function of the module", symbol)
+	}
+	entry := host.Pointer()
+	f := runtime.FuncForPC(entry)
+	if f == nil || f.Entry() != entry {
+		return nil, fmt.Errorf("goloader: hot patch %s: 0x%x is not the entry of a host function", symbol, entry)
+	}
+	if cm.Contains(entry) {
+		return nil, fmt.Errorf("goloader: hot patch %s: %s is a function of the module", symbol, f.Name())
+	}
+	code, err := jumpCode(entry, target)
+	if err != nil {
+		return nil, err
+	}
+	//the jump must not overwrite the next function
+	if last := runtime.FuncForPC(entry + uintptr(len(code)) - 1); last == nil || last.Entry() != entry {
+		return nil, fmt.Errorf("goloader: hot patch %s: %s is shorter than the %d bytes of a jump", symbol, f.Name(), len(code))
+	}
+
+	patchLock.Lock()
+	defer patchLock.Unlock()
+	for _, patched := range patches {
+		if patched.Entry == entry {
+			return nil, fmt.Errorf("goloader: hot patch %s: %s is already patched by %s", symbol, f.Name(), patched.Symbol)
+		}
+	}
+	patch := &Patch{Host: f.Name(), Symbol: symbol, Entry: entry, module: cm}
+	patch.saved = make([]byte, len(code))
+	copy(patch.saved, sliceOf(unsafe.Pointer(entry), len(code)))
+	if err := writeHostCode(entry, code); err != nil {
+		return nil, err
+	}
+	patches = append(patches, patch)
+	return patch, nil
+}
+
+// Unpatch restores the prologue of the host function.
+func (p *Patch) Unpatch() error {
+	patchLock.Lock()
+	defer patchLock.Unlock()
+	return p.unpatch()
+}
+
+func (p *Patch) unpatch() error {
+	for i, patched := range patches {
+		if patched == p {
+			if err := writeHostCode(p.Entry, p.saved); err != nil {
+				return err
+			}
+			patches = append(patches[:i], patches[i+1:]...)
+			return nil
+		}
+	}
+	return fmt.Errorf("goloader: %s is not patched", p.Host)
+}
+
+// Patches returns the host functions patched to functions of the module.
+func (cm *CodeModule) Patches() []*Patch {
+	patchLock.Lock()
+	defer patchLock.Unlock()
+	list := make([]*Patch, 0)
+	for _, p := range patches {
+		if p.module == cm {
+			list = append(list, p)
+		}
+	}
+	return list
+}
+
+// unpatchAll restores the host functions patched to the module, before it
+// is unloaded.
+func (cm *CodeModule) unpatchAll() error {
+	patchLock.Lock()
+	defer patchLock.Unlock()
+	for _, p := range append([]*Patch(nil), patches...) {
+		if p.module == cm {
+			if err := p.unpatch(); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,144 @@
+// Package httpmount mounts http handlers exported by a loaded module on a
+// ServeMux, so that they can be hot-reloaded with Swap.
+//
+// A handler is a module function with the signature
+//
+//	func(w http.ResponseWriter, r *http.Request)
+package httpmount
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+
+	"github.com/pkujhd/goloader"
+)
+
+type binding struct {
+	module   *goloader.CodeModule
+	handlers map[string]http.HandlerFunc
+	inflight sync.WaitGroup
+}
+
+// Mounter binds mux patterns to handler symbols of the current module.
+type Mounter struct {
+	// ErrorLog logs recovered panics, if nil the log package's standard logger is used.
+	ErrorLog *log.Logger
+
+	mux     *http.ServeMux
+	lock    sync.RWMutex
+	symbols map[string]string // pattern -> symbol
+	current *binding
+}
+
+func New(mux *http.ServeMux, module *goloader.CodeModule) *Mounter {
+	return &Mounter{
+		mux:     mux,
+		symbols: make(map[string]string),
+		current: &binding{module: module, handlers: make(map[string]http.HandlerFunc)},
+	}
+}
+
+func lookup(module *goloader.CodeModule, symbol string) (http.HandlerFunc, error) {
+	var handler func(http.ResponseWriter, *http.Request)
+	if err := module.LookupFunc(symbol, &handler); err != nil {
+		return nil, fmt.Errorf("httpmount: %v", err)
+	}
+	return handler, nil
+}
+
+// Mount registers symbol of the current module as the handler for pattern.
+// It fails if pattern is invalid or already registered on the mux.
+func (m *Mounter) Mount(pattern, symbol string) error {
+	m.lock.Lock()
+	defer m.lock.Unlock()
+	if _, ok := m.symbols[pattern]; ok {
+		return fmt.Errorf("httpmount: pattern %s already mounted", pattern)
+	}
+	handler, err := lookup(m.current.module, symbol)
+	if err != nil {
+		return err
+	}
+	if err := handle(m.mux, pattern, &route{mounter: m, pattern: pattern}); err != nil {
+		return err
+	}
+	m.symbols[pattern] = symbol
+	m.current.handlers[pattern] = handler
+	return nil
+}
+
+// handle registers h for pattern on mux. ServeMux.Handle panics if the
+// pattern is invalid or registered already, e.g. by the host, and has no
+// method to check it first.
+func handle(mux *http.ServeMux, pattern string, h http.Handler) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("httpmount: mount %s: %v", pattern, v)
+		}
+	}()
+	mux.Handle(pattern, h)
+	return nil
+}
+
+// Swap rebinds every mounted pattern to module, waits for the requests still
+// running on the previous module and returns it, the caller may then unload it.
+// If a symbol is missing in module, nothing is changed.
+func (m *Mounter) Swap(module *goloader.CodeModule) (*goloader.CodeModule, error) {
+	m.lock.Lock()
+	next := &binding{module: module, handlers: make(map[string]http.HandlerFunc)}
+	for pattern, symbol := range m.symbols {
+		handler, err := lookup(module, symbol)
+		if err != nil {
+			m.lock.Unlock()
+			goloader.Audit(goloader.AuditSwap, module.Hash(), symbol, err)
+			return nil, err
+		}
+		next.handlers[pattern] = handler
+	}
+	prev := m.current
+	m.current = next
+	m.lock.Unlock()
+	goloader.Audit(goloader.AuditSwap, module.Hash(), prev.module.Hash(), nil)
+
+	prev.inflight.Wait()
+	return prev.module, nil
+}
+
+// Module returns the module currently serving requests.
+func (m *Mounter) Module() *goloader.CodeModule {
+	m.lock.RLock()
+	defer m.lock.RUnlock()
+	return m.current.module
+}
+
+type route struct {
+	mounter *Mounter
+	pattern string
+}
+
+func (rt *route) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m := rt.mounter
+	m.lock.RLock()
+	b := m.current
+	handler := b.handlers[rt.pattern]
+	b.inflight.Add(1)
+	m.lock.RUnlock()
+	defer b.inflight.Done()
+
+	defer func() {
+		if v := recover(); v != nil {
+			m.logf("httpmount: panic serving %s: %v", rt.pattern, v)
+			http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+		}
+	}()
+	handler(w, r)
+}
+
+func (m *Mounter) logf(format string, args ...interface{}) {
+	if m.ErrorLog != nil {
+		m.ErrorLog.Printf(format, args...)
+	} else {
+		log.Printf(format, args...)
+	}
+}
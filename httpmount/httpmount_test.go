@@ -0,0 +1,20 @@
+package httpmount
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestHandleRegisteredPattern(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.Handle("/host", http.NotFoundHandler())
+	if err := handle(mux, "/host", http.NotFoundHandler()); err == nil {
+		t.Error("handle of a pattern registered by the host succeeded")
+	}
+	if err := handle(mux, "", http.NotFoundHandler()); err == nil {
+		t.Error("handle of an empty pattern succeeded")
+	}
+	if err := handle(mux, "/module", http.NotFoundHandler()); err != nil {
+		t.Error(err)
+	}
+}
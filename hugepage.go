@@ -0,0 +1,36 @@
+package goloader
+
+// HugePages selects the huge pages backing the code of a module, they
+// reduce the iTLB misses of large modules.
+type HugePages int
+
+const (
+	// HugePagesOff maps the code with the base page size.
+	HugePagesOff HugePages = iota
+	// HugePagesTransparent aligns the code to huge pages and asks for
+	// transparent huge pages with madvise, the kernel may back it with
+	// base pages, e.g. if transparent huge pages are disabled.
+	HugePagesTransparent
+	// HugePagesExplicit maps the code from the huge page pool with
+	// MAP_HUGETLB, pages must be reserved in /proc/sys/vm/nr_hugepages.
+	HugePagesExplicit
+)
+
+// mmapCode maps size bytes for the code of the module, with huge pages if
+// LoadOptions.HugePages asks for them, else near the text of the host if
+// a free range is found there.
+func (cm *CodeModule) mmapCode(size int) ([]byte, error) {
+	if cm.options.HugePages != HugePagesOff {
+		b, err := mmapHuge(size, cm.options.HugePages)
+		if err == nil {
+			return b, nil
+		}
+		if cm.options.HugePagesFailed != nil {
+			cm.options.HugePagesFailed(err)
+		}
+	}
+	if b := cm.mmapNear(size, true, firstmoduledata.text, firstmoduledata.etext); b != nil {
+		return b, nil
+	}
+	return Mmap(size)
+}
@@ -0,0 +1,46 @@
+// +build linux
+
+package goloader
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	hugePageSize = 2 << 20
+	madvHugepage = 14
+	mapHugetlb   = 0x40000
+)
+
+// mmapHuge maps size bytes rounded up to huge pages for the code of a module.
+func mmapHuge(size int, mode HugePages) ([]byte, error) {
+	size = alignof(size, hugePageSize)
+	prot := syscall.PROT_READ | syscall.PROT_WRITE | syscall.PROT_EXEC
+	if mode == HugePagesExplicit {
+		b, err := syscall.Mmap(-1, 0, size, prot, syscall.MAP_PRIVATE|syscall.MAP_ANON|mapHugetlb)
+		if err != nil {
+			return nil, os.NewSyscallError("syscall.Mmap", err)
+		}
+		return b, nil
+	}
+	//transparent huge pages only back aligned ranges, map one huge page
+	//more and unmap the unaligned head and tail
+	b, err := syscall.Mmap(-1, 0, size+hugePageSize, prot, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return nil, os.NewSyscallError("syscall.Mmap", err)
+	}
+	base := uintptr(unsafe.Pointer(&b[0]))
+	head := int((hugePageSize - base%hugePageSize) % hugePageSize)
+	if head > 0 {
+		Munmap(b[:head])
+	}
+	Munmap(b[head+size:])
+	b = b[head : head+size : head+size]
+	if err := syscall.Madvise(b, madvHugepage); err != nil {
+		Munmap(b)
+		return nil, os.NewSyscallError("syscall.Madvise", err)
+	}
+	return b, nil
+}
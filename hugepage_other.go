@@ -0,0 +1,11 @@
+// +build !linux
+
+package goloader
+
+import (
+	"errors"
+)
+
+func mmapHuge(size int, mode HugePages) ([]byte, error) {
+	return nil, errors.New("goloader: huge pages are only supported on linux")
+}
@@ -37,8 +37,10 @@ var itabLock mutex
 //go:linkname itabAdd runtime.itabAdd
 func itabAdd(m *itab)
 
+// additabs adds the itabs of module to the itab table of the runtime. The
+// names and types of the methods are resolved before itabLock is taken,
+// resolving them may take the locks of the runtime ordered before it.
 func additabs(module *moduledata) {
-	lock(&itabLock)
 	for _, itab := range module.itablinks {
 		methods := itab._type.uncommon().methods()
 		for k := 0; k < len(methods); k++ {
@@ -50,6 +52,9 @@ func additabs(module *moduledata) {
 				}
 			}
 		}
+	}
+	lock(&itabLock)
+	for _, itab := range module.itablinks {
 		itabAdd(itab)
 	}
 	unlock(&itabLock)
@@ -32,8 +32,10 @@ var ifaceLock mutex
 //go:linkname additab runtime.additab
 func additab(m *itab, locked, canfail bool)
 
+// additabs adds the itabs of module to the itab table of the runtime. The
+// names and types of the methods are resolved before ifaceLock is taken,
+// resolving them may take the locks of the runtime ordered before it.
 func additabs(module *moduledata) {
-	lock(&ifaceLock)
 	for _, itab := range module.itablinks {
 		if itab.inhash == 0 {
 			methods := itab._type.uncommon().methods()
@@ -46,6 +48,11 @@ func additabs(module *moduledata) {
 					}
 				}
 			}
+		}
+	}
+	lock(&ifaceLock)
+	for _, itab := range module.itablinks {
+		if itab.inhash == 0 {
 			additab(itab, true, false)
 		}
 	}
@@ -17,7 +17,7 @@ func getInitFuncName(packagename string) string {
 
 func (linker *Linker) doInitialize(codeModule *CodeModule, symbolMap map[string]uintptr) error {
 	for _, name := range linker.initFuncs {
-		if funcPtr, ok := codeModule.Syms[name]; ok {
+		if funcPtr, ok := symbolMap[name]; ok {
 			funcPtrContainer := (uintptr)(unsafe.Pointer(&funcPtr))
 			runFunc := *(*func())(unsafe.Pointer(&funcPtrContainer))
 			runFunc()
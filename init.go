@@ -0,0 +1,15 @@
+package goloader
+
+// Init runs the init functions of the packages of a module loaded with
+// LoadOptions.DeferInit, the dependencies of a package first. They run
+// once, later calls return the error of the first. Load runs them itself
+// unless DeferInit is set.
+func (cm *CodeModule) Init() error {
+	cm.initLock.Lock()
+	defer cm.initLock.Unlock()
+	if cm.pendingInit != nil {
+		cm.initErr = cm.pendingInit()
+		cm.pendingInit = nil
+	}
+	return cm.initErr
+}
@@ -8,19 +8,18 @@ import (
 )
 
 func findFileTab(linker *Linker, filename string) int32 {
-	tab := linker.namemap[filename]
-	for index, value := range linker.filetab {
-		if uint32(tab) == value {
-			return int32(index)
-		}
-	}
-	return -1
+	return linker.addFile(filename)
 }
 
 func (linker *Linker) _addInlineTree(_func *_func, symbol *ObjSymbol) (err error) {
 	funcname := symbol.Name
 	Func := symbol.Func
 	sym := linker.symMap[funcname]
+	if Func != nil && len(Func.InlTree) != 0 && len(Func.PCInline) == 0 {
+		//the inline tree is useless without the table indexing it
+		linker.degrade(DegradedInlineTraceback, funcname)
+		return nil
+	}
 	if Func != nil && len(Func.InlTree) != 0 {
 		name := funcname + InlineTreeSuffix
 
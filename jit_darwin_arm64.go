@@ -0,0 +1,32 @@
+// +build darwin,arm64,cgo
+
+package goloader
+
+/*
+#include <pthread.h>
+#include <libkern/OSCacheControl.h>
+*/
+import "C"
+
+import (
+	"runtime"
+	"unsafe"
+)
+
+// jitBeginWrite makes the MAP_JIT pages writable for the current thread,
+// which is locked until jitEndWrite.
+func jitBeginWrite() error {
+	runtime.LockOSThread()
+	C.pthread_jit_write_protect_np(0)
+	return nil
+}
+
+// jitEndWrite makes the MAP_JIT pages executable again for the current
+// thread and invalidates the instruction cache of b.
+func jitEndWrite(b []byte) {
+	C.pthread_jit_write_protect_np(1)
+	if len(b) > 0 {
+		C.sys_icache_invalidate(unsafe.Pointer(&b[0]), C.size_t(len(b)))
+	}
+	runtime.UnlockOSThread()
+}
@@ -0,0 +1,13 @@
+// +build darwin,arm64,!cgo
+
+package goloader
+
+import (
+	"errors"
+)
+
+func jitBeginWrite() error {
+	return errors.New("goloader: darwin/arm64 needs cgo to write to MAP_JIT pages")
+}
+
+func jitEndWrite(b []byte) {}
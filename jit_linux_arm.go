@@ -0,0 +1,25 @@
+// +build linux,arm
+
+package goloader
+
+import (
+	"syscall"
+	"unsafe"
+)
+
+// __ARM_NR_cacheflush
+const sysCacheFlush = 0xF0002
+
+func jitBeginWrite() error {
+	return nil
+}
+
+// jitEndWrite flushes the data cache and invalidates the instruction cache
+// of b, arm does not keep them coherent, the code and the veneers written
+// would not be seen by the instruction fetch.
+func jitEndWrite(b []byte) {
+	if len(b) > 0 {
+		start := uintptr(unsafe.Pointer(&b[0]))
+		syscall.Syscall(sysCacheFlush, start, start+uintptr(len(b)), 0)
+	}
+}
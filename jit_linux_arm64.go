@@ -0,0 +1,25 @@
+// +build linux,arm64
+
+package goloader
+
+import (
+	"unsafe"
+)
+
+func jitBeginWrite() error {
+	return nil
+}
+
+// clearCache cleans the data cache to the point of unification and
+// invalidates the instruction cache of [start, end), see jit_linux_arm64.s.
+func clearCache(start, end uintptr)
+
+// jitEndWrite makes the code written to b visible to the instruction fetch,
+// arm64 does not keep the instruction cache coherent with the data cache,
+// a host prologue rewritten by HotPatch may still be cached.
+func jitEndWrite(b []byte) {
+	if len(b) > 0 {
+		start := uintptr(unsafe.Pointer(&b[0]))
+		clearCache(start, start+uintptr(len(b)))
+	}
+}
@@ -0,0 +1,42 @@
+// +build linux,arm64
+
+#include "textflag.h"
+
+// func clearCache(start, end uintptr)
+// the cache maintenance instructions are encoded as words, the assembler of
+// older go releases does not know them
+TEXT ·clearCache(SB), NOSPLIT, $0-16
+	MOVD	start+0(FP), R0
+	MOVD	end+8(FP), R1
+	WORD	$0xd53b0022	// MRS CTR_EL0, R2
+	// data cache line size, 4 << CTR_EL0.DminLine
+	LSR	$16, R2, R3
+	AND	$15, R3, R3
+	MOVD	$4, R4
+	LSL	R3, R4, R4
+	// instruction cache line size, 4 << CTR_EL0.IminLine
+	AND	$15, R2, R3
+	MOVD	$4, R5
+	LSL	R3, R5, R5
+	SUB	$1, R4, R6
+	BIC	R6, R0, R7
+dcache:
+	CMP	R1, R7
+	BHS	dcachedone
+	WORD	$0xd50b7b27	// DC CVAU, R7
+	ADD	R4, R7, R7
+	B	dcache
+dcachedone:
+	WORD	$0xd5033b9f	// DSB ISH
+	SUB	$1, R5, R6
+	BIC	R6, R0, R7
+icache:
+	CMP	R1, R7
+	BHS	icachedone
+	WORD	$0xd50b7527	// IC IVAU, R7
+	ADD	R5, R7, R7
+	B	icache
+icachedone:
+	WORD	$0xd5033b9f	// DSB ISH
+	WORD	$0xd5033fdf	// ISB
+	RET
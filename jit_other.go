@@ -0,0 +1,14 @@
+// +build !darwin !arm64
+// +build !linux !arm
+// +build !linux !arm64
+
+package goloader
+
+// jitBeginWrite and jitEndWrite bracket the writes to the pages of a module,
+// only darwin/arm64 toggles their protection and linux/arm and linux/arm64
+// flush the caches.
+func jitBeginWrite() error {
+	return nil
+}
+
+func jitEndWrite(b []byte) {}
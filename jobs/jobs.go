@@ -0,0 +1,192 @@
+// Package jobs runs functions exported by loaded modules on a worker pool.
+//
+// A job function is a module function with the signature
+//
+//	func(payload interface{}) (interface{}, error)
+package jobs
+
+import (
+	"errors"
+	"fmt"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/pkujhd/goloader"
+)
+
+var (
+	ErrTimeout  = errors.New("jobs: timeout")
+	ErrClosed   = errors.New("jobs: pool closed")
+	ErrUnloaded = errors.New("jobs: module is unloaded")
+)
+
+// PanicError is returned when a job function panics.
+type PanicError struct {
+	Value interface{}
+	Stack []byte
+}
+
+func (e *PanicError) Error() string {
+	return fmt.Sprintf("jobs: panic: %v", e.Value)
+}
+
+type Job struct {
+	Module  *goloader.CodeModule
+	Symbol  string
+	Payload interface{}
+	// Timeout of one attempt, 0 uses Pool.Timeout.
+	// A timed out function keeps running, it can not be stopped, and holds
+	// its module and its slot of the module limit until it returns.
+	Timeout time.Duration
+	// Retries is the number of attempts made after a failed one. A timed
+	// out attempt is not retried, the function would run twice at once.
+	Retries int
+}
+
+type Result struct {
+	Job      *Job
+	Value    interface{}
+	Err      error
+	Attempts int
+}
+
+type task struct {
+	job    *Job
+	result chan Result
+}
+
+type Pool struct {
+	// Timeout is the default timeout of an attempt, 0 means no timeout.
+	Timeout time.Duration
+
+	queue     chan *task
+	wg        sync.WaitGroup
+	lock      sync.RWMutex
+	closed    bool
+	limitLock sync.Mutex
+	limits    map[*goloader.CodeModule]chan struct{}
+}
+
+func NewPool(workers int) *Pool {
+	pool := &Pool{
+		queue:  make(chan *task, workers),
+		limits: make(map[*goloader.CodeModule]chan struct{}),
+	}
+	for i := 0; i < workers; i++ {
+		pool.wg.Add(1)
+		go pool.worker()
+	}
+	return pool
+}
+
+// SetModuleLimit limits the number of jobs of module running at the same time.
+// It must be called before jobs of module are submitted. A job waits in its
+// worker for a slot, its timeout starts once it has one.
+func (p *Pool) SetModuleLimit(module *goloader.CodeModule, n int) {
+	p.limitLock.Lock()
+	defer p.limitLock.Unlock()
+	if n <= 0 {
+		delete(p.limits, module)
+	} else {
+		p.limits[module] = make(chan struct{}, n)
+	}
+}
+
+// Submit queues job, the returned channel receives its result.
+func (p *Pool) Submit(job *Job) (<-chan Result, error) {
+	p.lock.RLock()
+	defer p.lock.RUnlock()
+	if p.closed {
+		return nil, ErrClosed
+	}
+	t := &task{job: job, result: make(chan Result, 1)}
+	p.queue <- t
+	return t.result, nil
+}
+
+// Close stops accepting jobs and waits for queued jobs to finish.
+func (p *Pool) Close() {
+	p.lock.Lock()
+	if !p.closed {
+		p.closed = true
+		close(p.queue)
+	}
+	p.lock.Unlock()
+	p.wg.Wait()
+}
+
+func (p *Pool) worker() {
+	defer p.wg.Done()
+	for t := range p.queue {
+		t.result <- p.run(t.job)
+	}
+}
+
+func (p *Pool) run(job *Job) (result Result) {
+	result.Job = job
+	var fn func(interface{}) (interface{}, error)
+	if err := job.Module.LookupFunc(job.Symbol, &fn); err != nil {
+		result.Err = err
+		return result
+	}
+	timeout := job.Timeout
+	if timeout == 0 {
+		timeout = p.Timeout
+	}
+	for result.Attempts <= job.Retries {
+		result.Attempts++
+		result.Value, result.Err = p.attempt(job, fn, timeout)
+		if result.Err == nil || result.Err == ErrTimeout || result.Err == ErrUnloaded {
+			break
+		}
+	}
+	return result
+}
+
+type outcome struct {
+	value interface{}
+	err   error
+}
+
+func (p *Pool) attempt(job *Job, fn func(interface{}) (interface{}, error), timeout time.Duration) (interface{}, error) {
+	p.limitLock.Lock()
+	limit := p.limits[job.Module]
+	p.limitLock.Unlock()
+
+	//the reference and the slot of the limit are held until the function
+	//returns, after a timeout too
+	if !job.Module.Acquire() {
+		return nil, ErrUnloaded
+	}
+	if limit != nil {
+		limit <- struct{}{}
+	}
+	done := make(chan outcome, 1)
+	go func() {
+		defer job.Module.Release()
+		if limit != nil {
+			defer func() { <-limit }()
+		}
+		defer func() {
+			if v := recover(); v != nil {
+				done <- outcome{err: &PanicError{Value: v, Stack: debug.Stack()}}
+			}
+		}()
+		value, err := fn(job.Payload)
+		done <- outcome{value: value, err: err}
+	}()
+
+	if timeout <= 0 {
+		o := <-done
+		return o.value, o.err
+	}
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	select {
+	case o := <-done:
+		return o.value, o.err
+	case <-timer.C:
+		return nil, ErrTimeout
+	}
+}
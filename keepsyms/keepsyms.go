@@ -0,0 +1,150 @@
+// Package keepsyms generates a go file for the host which makes the go
+// linker keep the symbols plugins need: the packages they refer to are
+// imported and the functions they call are anchored with go:linkname, so
+// they are in the host even if the host itself does not use them.
+package keepsyms
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pkujhd/goloader"
+	"github.com/pkujhd/goloader/objparse"
+)
+
+// Symbols are the symbols of the host the objects of plugins need.
+type Symbols struct {
+	Packages []string // packages the objects refer to which the host can import
+	Funcs    []string // functions the objects call
+}
+
+// ScanFiles parses the object files, pkgPaths holds the package path of
+// each, and returns the symbols of the host they need, see Scan.
+func ScanFiles(files, pkgPaths, hostPkgs []string) (*Symbols, error) {
+	if len(files) != len(pkgPaths) {
+		return nil, fmt.Errorf("keepsyms: %d files, %d package paths", len(files), len(pkgPaths))
+	}
+	objs := make([]*objparse.Object, 0, len(files))
+	for i, file := range files {
+		obj, err := objparse.ParseFile(file, pkgPaths[i])
+		if err != nil {
+			return nil, err
+		}
+		objs = append(objs, obj)
+	}
+	return Scan(objs, hostPkgs), nil
+}
+
+// Scan returns the symbols of the host the objects need, the symbols they
+// refer to and none of them defines. A function is anchored only if its
+// package is imported by the generated file or linked in the host anyway:
+// the runtime and hostPkgs, the packages the host already links, a
+// go:linkname to a package which is not linked fails to link.
+func Scan(objs []*objparse.Object, hostPkgs []string) *Symbols {
+	linked := make(map[string]bool)
+	for _, pkg := range hostPkgs {
+		linked[pkg] = true
+	}
+	defined := make(map[string]bool)
+	for _, obj := range objs {
+		for name := range obj.Syms {
+			defined[strings.Replace(name, goloader.EmptyPkgPath, obj.PkgPath, -1)] = true
+		}
+	}
+	pkgs := make(map[string]bool)
+	funcs := make(map[string]bool)
+	for _, obj := range objs {
+		for _, sym := range obj.Syms {
+			for _, reloc := range sym.Reloc {
+				name := strings.Replace(reloc.Sym.Name, goloader.EmptyPkgPath, obj.PkgPath, -1)
+				pkg := goloader.SymbolPackage(name)
+				if defined[name] || pkg == goloader.EmptyString || pkg == "main" || strings.HasPrefix(name, goloader.CgoSymPrefix) {
+					continue
+				}
+				if importable(pkg) {
+					pkgs[pkg] = true
+				}
+				if isCall(reloc.Type) && (importable(pkg) || linked[pkg] || isRuntime(pkg)) && anchorable(name, pkg) {
+					funcs[name] = true
+				}
+			}
+		}
+	}
+	return &Symbols{Packages: sorted(pkgs), Funcs: sorted(funcs)}
+}
+
+func isCall(relocType int) bool {
+	switch relocType {
+	case goloader.R_CALL, goloader.R_CALLARM, goloader.R_CALLARM64, goloader.R_CALLMIPS, goloader.R_JMPMIPS:
+		return true
+	}
+	return false
+}
+
+// importable reports whether the host may import pkg.
+func importable(pkg string) bool {
+	for _, elem := range strings.Split(pkg, "/") {
+		if elem == "internal" || elem == "vendor" {
+			return false
+		}
+	}
+	return !strings.HasPrefix(pkg, "_")
+}
+
+// isRuntime reports whether pkg is linked in every host.
+func isRuntime(pkg string) bool {
+	return pkg == "runtime" || strings.HasPrefix(pkg, "runtime/internal/")
+}
+
+// anchorable reports whether the function name exists in the host if its
+// package is linked, closures and wrappers are generated per use.
+func anchorable(name, pkg string) bool {
+	rest := name[len(pkg):]
+	return !strings.Contains(rest, ".func") && !strings.Contains(rest, "·") && !strings.Contains(rest, "-fm")
+}
+
+func sorted(set map[string]bool) []string {
+	list := make([]string, 0, len(set))
+	for name := range set {
+		list = append(list, name)
+	}
+	sort.Strings(list)
+	return list
+}
+
+// Generate writes the go file of package pkgName keeping the symbols. The
+// functions are declared without body, the package needs an assembly file,
+// which may be empty, see GenerateAsm.
+func (s *Symbols) Generate(w io.Writer, pkgName string) error {
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "// Code generated by keepsyms. DO NOT EDIT.\n\npackage %s\n\nimport (\n", pkgName)
+	imports := append([]string{"unsafe"}, s.Packages...)
+	sort.Strings(imports)
+	for _, pkg := range imports {
+		if pkg != "unsafe" || len(s.Funcs) > 0 {
+			fmt.Fprintf(bw, "\t_ %q\n", pkg)
+		}
+	}
+	fmt.Fprintf(bw, ")\n")
+	if len(s.Funcs) > 0 {
+		for i, name := range s.Funcs {
+			fmt.Fprintf(bw, "\n//go:linkname keepsyms%d %s\nfunc keepsyms%d()\n", i, name, i)
+		}
+		fmt.Fprintf(bw, "\n// keptSymbols is set by init, which the linker keeps, so the functions\n// it refers to are kept.\nvar keptSymbols []func()\n\nfunc init() {\n\tkeptSymbols = []func(){\n")
+		for i := range s.Funcs {
+			fmt.Fprintf(bw, "\t\tkeepsyms%d,\n", i)
+		}
+		fmt.Fprintf(bw, "\t}\n}\n")
+	}
+	return bw.Flush()
+}
+
+// GenerateAsm writes the empty assembly file which lets the go file of
+// Generate declare functions without body.
+func GenerateAsm(w io.Writer) error {
+	_, err := io.WriteString(w, "// Code generated by keepsyms. DO NOT EDIT.\n\n// Empty, the go file of keepsyms declares functions without body.\n")
+	return err
+}
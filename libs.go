@@ -0,0 +1,56 @@
+package goloader
+
+// LoadWithLibs is like Load, the external symbols missing in symPtr are
+// resolved with the exports of the shared libraries libs, opened with
+// Dlopen in order. The libraries are closed when the module is unloaded.
+func LoadWithLibs(linker *Linker, symPtr map[string]uintptr, libs []string) (*CodeModule, error) {
+	return LoadWithLibsOptions(linker, symPtr, libs, LoadOptions{})
+}
+
+// LoadWithLibsOptions is like LoadWithLibs, with options. The libraries are
+// searched after options.ResolveSymbol, if set.
+func LoadWithLibsOptions(linker *Linker, symPtr map[string]uintptr, libs []string, options LoadOptions) (*CodeModule, error) {
+	opened := make([]*Library, 0, len(libs))
+	closeLibs := func() {
+		for _, lib := range opened {
+			lib.Close()
+		}
+	}
+	for _, path := range libs {
+		lib, err := Dlopen(path)
+		if err != nil {
+			closeLibs()
+			return nil, err
+		}
+		opened = append(opened, lib)
+	}
+	resolve := options.ResolveSymbol
+	options.ResolveSymbol = func(name string) (uintptr, bool) {
+		if resolve != nil {
+			if ptr, ok := resolve(name); ok {
+				return ptr, ok
+			}
+		}
+		for _, lib := range opened {
+			if ptr, ok := lib.Sym(name); ok {
+				return ptr, ok
+			}
+		}
+		return 0, false
+	}
+	codeModule, err := LoadWithOptions(linker, symPtr, options)
+	if err != nil {
+		closeLibs()
+		return nil, err
+	}
+	codeModule.libs = opened
+	return codeModule, nil
+}
+
+// closeLibs closes the libraries opened by LoadWithLibs.
+func (cm *CodeModule) closeLibs() {
+	for _, lib := range cm.libs {
+		lib.Close()
+	}
+	cm.libs = nil
+}
@@ -0,0 +1,35 @@
+// Package link reads object files into a goloader.Linker, which holds
+// the symbols of the objects laid out for loading, and resolves them
+// against the symbols of the host.
+package link
+
+import (
+	"io"
+
+	"github.com/pkujhd/goloader"
+)
+
+// Files reads the object files, pkgPaths holds the package path of each.
+func Files(files []string, pkgPaths []string, options goloader.ReadOptions) (*goloader.Linker, error) {
+	return goloader.ReadObjsWithOptions(files, pkgPaths, options)
+}
+
+// Reader reads one object file from r.
+func Reader(r io.ReaderAt, pkgPath string) (*goloader.Linker, error) {
+	return goloader.ReadObjFrom(r, pkgPath)
+}
+
+// Decode reads a linker written by goloader.Linker.Encode.
+func Decode(r io.Reader) (*goloader.Linker, error) {
+	return goloader.DecodeLinker(r)
+}
+
+// HostSymbols returns the symbols of the running executable, the
+// relocations of a linker are resolved against them.
+func HostSymbols() (map[string]uintptr, error) {
+	symPtr := make(map[string]uintptr)
+	if err := goloader.RegSymbol(symPtr); err != nil {
+		return nil, err
+	}
+	return symPtr, nil
+}
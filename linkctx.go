@@ -0,0 +1,186 @@
+package goloader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// LinkContext links the modules loaded with it against each other: the
+// packages a module loads, other than main, are provided to the modules
+// loaded after it, which resolve their functions, variables, types and
+// itabs to it instead of loading a copy. Ten plugins embedding the same
+// helper package load it once and share its state and its types.
+type LinkContext struct {
+	lock   sync.Mutex
+	symPtr map[string]uintptr            // symbols of the host
+	syms   map[string]uintptr            // symbols of the provided packages
+	owners map[string]*CodeModule        // module providing each symbol of syms
+	pkgs   map[string]*CodeModule        // module providing each package
+	users  map[*CodeModule]int           // number of loaded modules using each module
+	uses   map[*CodeModule][]*CodeModule // modules each module uses
+}
+
+// NewLinkContext returns a context resolving the symbols of its modules
+// against the host symbols symPtr first.
+func NewLinkContext(symPtr map[string]uintptr) *LinkContext {
+	return &LinkContext{
+		symPtr: symPtr,
+		syms:   make(map[string]uintptr),
+		owners: make(map[string]*CodeModule),
+		pkgs:   make(map[string]*CodeModule),
+		users:  make(map[*CodeModule]int),
+		uses:   make(map[*CodeModule][]*CodeModule),
+	}
+}
+
+// Packages returns the sorted packages provided by the modules of ctx.
+func (ctx *LinkContext) Packages() []string {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+	return ctx.packages()
+}
+
+func (ctx *LinkContext) packages() []string {
+	pkgs := make([]string, 0, len(ctx.pkgs))
+	for path := range ctx.pkgs {
+		pkgs = append(pkgs, path)
+	}
+	sort.Strings(pkgs)
+	return pkgs
+}
+
+// typePkg returns the package of a named type, pointer types included,
+// or "" for the unnamed types.
+func typePkg(name string) string {
+	name = strings.TrimLeft(strings.TrimPrefix(name, TypePrefix), "*")
+	if strings.ContainsAny(name, "[]{}(), ") {
+		return EmptyString
+	}
+	return symbolPkg(name)
+}
+
+// ReadObjs is like ReadObjsWithOptions, the packages provided by ctx are
+// bound to the modules which loaded them, as host packages.
+func (ctx *LinkContext) ReadObjs(files []string, pkgPaths []string, options ReadOptions) (*Linker, error) {
+	ctx.lock.Lock()
+	options.HostPackages = append(append([]string(nil), options.HostPackages...), ctx.packages()...)
+	provided := append([]string(nil), options.Provided...)
+	for name := range ctx.syms {
+		if strings.HasPrefix(name, TypePrefix) || strings.HasPrefix(name, ItabPrefix) {
+			provided = append(provided, name)
+		}
+	}
+	ctx.lock.Unlock()
+	sort.Strings(provided)
+	options.Provided = provided
+	return ReadObjsWithOptions(files, pkgPaths, options)
+}
+
+// Load is like LoadWithOptions with the symbols of the host and of the
+// packages provided by ctx. The packages of the module are provided to
+// the modules loaded after it.
+func (ctx *LinkContext) Load(linker *Linker, options LoadOptions) (*CodeModule, error) {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+	symPtr := make(map[string]uintptr, len(ctx.symPtr)+len(ctx.syms))
+	for name, ptr := range ctx.syms {
+		symPtr[name] = ptr
+	}
+	//the host wins, as it does for the objects of a module
+	for name, ptr := range ctx.symPtr {
+		symPtr[name] = ptr
+	}
+	cm, err := LoadWithOptions(linker, symPtr, options)
+	if err != nil {
+		return nil, err
+	}
+	used := make(map[*CodeModule]bool)
+	for name, sym := range linker.symMap {
+		if sym.Offset != InvalidOffset {
+			continue
+		}
+		if _, ok := ctx.symPtr[name]; ok {
+			continue
+		}
+		if owner, ok := ctx.owners[name]; ok && !used[owner] {
+			used[owner] = true
+			ctx.users[owner]++
+			ctx.uses[cm] = append(ctx.uses[cm], owner)
+		}
+	}
+	ctx.provide(linker, cm)
+	return cm, nil
+}
+
+// provide records the packages cm loaded and their symbols.
+func (ctx *LinkContext) provide(linker *Linker, cm *CodeModule) {
+	isShared := func(path string) bool {
+		return path != EmptyString && path != DefaultPkgPath && ctx.pkgs[path] == cm
+	}
+	for name, sym := range linker.symMap {
+		if sym.Offset == InvalidOffset || sym.Kind != STEXT || name == TLSNAME {
+			continue
+		}
+		if path := symbolPkg(name); path != EmptyString && path != DefaultPkgPath && ctx.pkgs[path] == nil {
+			ctx.pkgs[path] = cm
+		}
+	}
+	for name, sym := range linker.symMap {
+		if sym.Offset == InvalidOffset || name == TLSNAME || linker.sharedData[name] {
+			continue
+		}
+		shared := false
+		switch {
+		case strings.HasPrefix(name, TypePrefix):
+			shared = isShared(typePkg(name))
+		case strings.HasPrefix(name, ItabPrefix):
+			//an itab is shared if its interface is provided too, or of the host
+			pair := strings.SplitN(strings.TrimPrefix(name, ItabPrefix), ",", 2)
+			if len(pair) == 2 {
+				_, hostIface := ctx.symPtr[TypePrefix+pair[1]]
+				shared = isShared(typePkg(pair[0])) && (ctx.pkgs[typePkg(pair[1])] != nil || hostIface)
+			}
+		default:
+			shared = isShared(symbolPkg(name))
+		}
+		if !shared {
+			continue
+		}
+		if sym.Kind == STEXT {
+			ctx.syms[name] = uintptr(cm.codeBase + sym.Offset)
+		} else {
+			ctx.syms[name] = uintptr(cm.dataBase + sym.Offset)
+		}
+		ctx.owners[name] = cm
+	}
+}
+
+// Unload unloads cm, unless modules loaded after it use its packages.
+// The packages cm provided are not provided any more.
+func (ctx *LinkContext) Unload(cm *CodeModule) error {
+	ctx.lock.Lock()
+	defer ctx.lock.Unlock()
+	if users := ctx.users[cm]; users > 0 {
+		return fmt.Errorf("goloader: module %s is used by %d modules of the link context", cm.hash, users)
+	}
+	for _, owner := range ctx.uses[cm] {
+		ctx.users[owner]--
+	}
+	delete(ctx.uses, cm)
+	delete(ctx.users, cm)
+	for name, owner := range ctx.owners {
+		if owner == cm {
+			delete(ctx.owners, name)
+			delete(ctx.syms, name)
+		}
+	}
+	for path, owner := range ctx.pkgs {
+		if owner == cm {
+			delete(ctx.pkgs, path)
+		}
+	}
+	cm.Unload()
+	return nil
+}
@@ -0,0 +1,27 @@
+package goloader
+
+import (
+	"testing"
+)
+
+func TestTypePkg(t *testing.T) {
+	tests := []struct {
+		name string
+		pkg  string
+	}{
+		{"type.main.T", "main"},
+		{"type.*main.T", "main"},
+		{"type.**net/http.Client", "net/http"},
+		{"type.github.com/a/b.c.T", "github.com/a/b"},
+		{"type.[]main.T", ""},
+		{"type.map[string]int", ""},
+		{"type.func(int) error", ""},
+		{"type.struct { X int }", ""},
+		{"type.int", ""},
+	}
+	for _, test := range tests {
+		if pkg := typePkg(test.name); pkg != test.pkg {
+			t.Errorf("typePkg(%q) = %q, want %q", test.name, pkg, test.pkg)
+		}
+	}
+}
@@ -0,0 +1,68 @@
+package goloader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// diagnostics listed in LinkError.Error, the others are in Diagnostics
+const linkErrorSummary = 3
+
+// LinkError lists everything Load could not link, all the unresolved
+// symbols and all the failing relocations, not only the first. Load returns
+// it as the Err and Link of a LoadError.
+type LinkError struct {
+	UnresolvedSymbols []string      // sorted
+	OverflowedRelocs  []FailedReloc // relocations whose target is out of range of their instruction
+	UnsupportedRelocs []FailedReloc // relocations of a type goloader does not relocate
+	Diagnostics       []SymbolDiagnostic
+}
+
+// SymbolDiagnostic is an error linking a symbol, the unresolved symbol or
+// the symbol holding a failing relocation.
+type SymbolDiagnostic struct {
+	Symbol string
+	Reloc  *FailedReloc // nil for an unresolved symbol
+	Err    error
+}
+
+func (e *LinkError) Error() string {
+	messages := make([]string, 0, linkErrorSummary)
+	for i := 0; i < len(e.Diagnostics) && i < linkErrorSummary; i++ {
+		messages = append(messages, e.Diagnostics[i].Err.Error())
+	}
+	message := strings.Join(messages, "; ")
+	if more := len(e.Diagnostics) - len(messages); more > 0 {
+		message = fmt.Sprintf("%s; and %d more", message, more)
+	}
+	return fmt.Sprintf("goloader: link failed, %d unresolved symbols, %d overflowed relocations, %d unsupported relocations: %s",
+		len(e.UnresolvedSymbols), len(e.OverflowedRelocs), len(e.UnsupportedRelocs), message)
+}
+
+func (e *LinkError) unresolved(name string, err error) {
+	e.UnresolvedSymbols = append(e.UnresolvedSymbols, name)
+	e.Diagnostics = append(e.Diagnostics, SymbolDiagnostic{Symbol: name, Err: err})
+}
+
+func (e *LinkError) failed(reloc *FailedReloc, overflow, unsupported bool, err error) {
+	if overflow {
+		e.OverflowedRelocs = append(e.OverflowedRelocs, *reloc)
+	}
+	if unsupported {
+		e.UnsupportedRelocs = append(e.UnsupportedRelocs, *reloc)
+	}
+	e.Diagnostics = append(e.Diagnostics, SymbolDiagnostic{Symbol: reloc.Symbol, Reloc: reloc, Err: err})
+}
+
+// err returns e sorted, or nil if nothing failed.
+func (e *LinkError) err() error {
+	if len(e.Diagnostics) == 0 {
+		return nil
+	}
+	sort.Strings(e.UnresolvedSymbols)
+	sort.Slice(e.OverflowedRelocs, func(i, j int) bool { return e.OverflowedRelocs[i].Offset < e.OverflowedRelocs[j].Offset })
+	sort.Slice(e.UnsupportedRelocs, func(i, j int) bool { return e.UnsupportedRelocs[i].Offset < e.UnsupportedRelocs[j].Offset })
+	sort.SliceStable(e.Diagnostics, func(i, j int) bool { return e.Diagnostics[i].Symbol < e.Diagnostics[j].Symbol })
+	return e
+}
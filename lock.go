@@ -0,0 +1,75 @@
+package goloader
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+const unlimitedMemlock = ^uint64(0)
+
+var (
+	lockedBytes int // bytes locked by all modules, accounted against RLIMIT_MEMLOCK
+	lockedLock  sync.Mutex
+)
+
+// lockSize is the size charged by the kernel for locking b.
+func lockSize(b []byte) int {
+	if len(b) == 0 {
+		return 0
+	}
+	return alignof(len(b), os.Getpagesize()) + os.Getpagesize()
+}
+
+// lock locks the segment and the pclntable of the module in memory, unless
+// they do not fit in RLIMIT_MEMLOCK with the pages locked by the other modules.
+func (cm *CodeModule) lock() error {
+	lockedLock.Lock()
+	defer lockedLock.Unlock()
+	if cm.lockedBytes > 0 {
+		return nil
+	}
+	size := lockSize(cm.codeByte) + lockSize(cm.dataByte) + lockSize(cm.module.pclntable)
+	if limit := memlockLimit(); limit != unlimitedMemlock && uint64(lockedBytes+size) > limit {
+		return fmt.Errorf("goloader: locking %d bytes exceeds RLIMIT_MEMLOCK %d, %d bytes are locked", size, limit, lockedBytes)
+	}
+	if err := mlock(cm.codeByte); err != nil {
+		return err
+	}
+	if err := mlock(cm.dataByte); err != nil {
+		munlock(cm.codeByte)
+		return err
+	}
+	if len(cm.module.pclntable) > 0 {
+		if err := mlock(cm.module.pclntable); err != nil {
+			munlock(cm.codeByte)
+			munlock(cm.dataByte)
+			return err
+		}
+	}
+	cm.lockedBytes = size
+	lockedBytes += size
+	return nil
+}
+
+func (cm *CodeModule) unlock() {
+	lockedLock.Lock()
+	defer lockedLock.Unlock()
+	if cm.lockedBytes == 0 {
+		return
+	}
+	munlock(cm.codeByte)
+	munlock(cm.dataByte)
+	if len(cm.module.pclntable) > 0 {
+		munlock(cm.module.pclntable)
+	}
+	lockedBytes -= cm.lockedBytes
+	cm.lockedBytes = 0
+}
+
+// Locked reports whether the pages of the module are locked in memory.
+func (cm *CodeModule) Locked() bool {
+	lockedLock.Lock()
+	defer lockedLock.Unlock()
+	return cm.lockedBytes > 0
+}
@@ -0,0 +1,77 @@
+package goloader
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// LookupFunc sets *fnPtr to the function named name in the module.
+// fnPtr must be a non-nil pointer to a func variable whose type matches
+// the signature of the loaded function, it is not checked.
+func (cm *CodeModule) LookupFunc(name string, fnPtr interface{}) error {
+	v := reflect.ValueOf(fnPtr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Func {
+		return fmt.Errorf("goloader: LookupFunc needs a pointer to func, got %T", fnPtr)
+	}
+	ptr, ok := cm.Lookup(name)
+	if !ok || ptr == 0 {
+		return fmt.Errorf("goloader: function %s not found", name)
+	}
+	*(*unsafe.Pointer)(unsafe.Pointer(v.Elem().UnsafeAddr())) = funcValue(ptr)
+	return nil
+}
+
+// Var returns the address of the package-level variable named name,
+// it lives in the data segment of the module, or in the host if the
+// variable belongs to a package bound to the host.
+func (cm *CodeModule) Var(name string) (uintptr, bool) {
+	addr, ok := cm.vars[name]
+	return addr, ok && addr != 0
+}
+
+// LookupVar sets *varPtr to the address of the package-level variable
+// named name, varPtr must be a non-nil pointer to a pointer variable,
+// e.g. a **int for an int variable. The kind and size of the variable
+// are checked against its go type information if the object has it.
+func (cm *CodeModule) LookupVar(name string, varPtr interface{}) error {
+	v := reflect.ValueOf(varPtr)
+	if v.Kind() != reflect.Ptr || v.IsNil() || v.Elem().Kind() != reflect.Ptr {
+		return fmt.Errorf("goloader: LookupVar needs a pointer to pointer, got %T", varPtr)
+	}
+	addr, ok := cm.Var(name)
+	if !ok {
+		return fmt.Errorf("goloader: variable %s not found", name)
+	}
+	elem := v.Elem().Type().Elem()
+	if typ, ok := cm.types[name]; ok && typ != 0 {
+		if t := toType(typ); t.Kind() != elem.Kind() || t.Size() != elem.Size() {
+			return fmt.Errorf("goloader: variable %s is %s, not %s", name, t, elem)
+		}
+	}
+	*(*unsafe.Pointer)(unsafe.Pointer(v.Elem().UnsafeAddr())) = adduintptr(addr, 0)
+	return nil
+}
+
+// VarValue returns the package-level variable named name as an addressable
+// reflect.Value of its type, recovered from the go type information of the
+// object file, e.g. to use a value of a type of the module through reflect.
+func (cm *CodeModule) VarValue(name string) (reflect.Value, error) {
+	addr, ok := cm.Var(name)
+	if !ok {
+		return reflect.Value{}, fmt.Errorf("goloader: variable %s not found", name)
+	}
+	typ, ok := cm.types[name]
+	if !ok || typ == 0 {
+		return reflect.Value{}, fmt.Errorf("goloader: no type information for %s", name)
+	}
+	return reflect.NewAt(toType(typ), adduintptr(addr, 0)).Elem(), nil
+}
+
+// funcValue returns a closure word for the code at entry, the memory layout
+// of a func value is a pointer to a struct whose first word is the entry pc.
+func funcValue(entry uintptr) unsafe.Pointer {
+	container := new(uintptr)
+	*container = entry
+	return unsafe.Pointer(container)
+}
@@ -0,0 +1,21 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!openbsd,!netbsd
+
+package goloader
+
+import (
+	"errors"
+)
+
+var errMlockUnsupported = errors.New("goloader: mlock is not supported on this platform")
+
+func mlock(b []byte) error {
+	return errMlockUnsupported
+}
+
+func munlock(b []byte) error {
+	return errMlockUnsupported
+}
+
+func memlockLimit() uint64 {
+	return unlimitedMemlock
+}
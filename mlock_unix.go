@@ -0,0 +1,32 @@
+// +build darwin dragonfly freebsd linux openbsd netbsd
+
+package goloader
+
+import (
+	"os"
+	"syscall"
+)
+
+func mlock(b []byte) error {
+	if err := syscall.Mlock(b); err != nil {
+		return os.NewSyscallError("syscall.Mlock", err)
+	}
+	return nil
+}
+
+func munlock(b []byte) error {
+	if err := syscall.Munlock(b); err != nil {
+		return os.NewSyscallError("syscall.Munlock", err)
+	}
+	return nil
+}
+
+// memlockLimit returns the soft RLIMIT_MEMLOCK of the process,
+// unlimitedMemlock if there is none or it is not known.
+func memlockLimit() uint64 {
+	var rlim syscall.Rlimit
+	if rlimitMemlock < 0 || syscall.Getrlimit(rlimitMemlock, &rlim) != nil || int64(rlim.Cur) < 0 {
+		return unlimitedMemlock
+	}
+	return uint64(rlim.Cur)
+}
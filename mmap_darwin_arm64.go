@@ -0,0 +1,74 @@
+// +build darwin,arm64
+
+package goloader
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// _MAP_JIT allows writable and executable pages under the hardened
+// runtime of apple silicon, a thread writes to them between jitBeginWrite
+// and jitEndWrite.
+const _MAP_JIT = 0x800
+
+func Mmap(size int) ([]byte, error) {
+	data, err := syscall.Mmap(
+		0,
+		0,
+		size,
+		syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC,
+		syscall.MAP_PRIVATE|syscall.MAP_ANON|_MAP_JIT)
+	if err != nil {
+		err = os.NewSyscallError("syscall.Mmap", err)
+	}
+	return data, err
+}
+
+// MmapData maps the data of a module, it is not MAP_JIT so the variables
+// of the module are written without jitBeginWrite.
+func MmapData(size int) ([]byte, error) {
+	data, err := syscall.Mmap(
+		0,
+		0,
+		size,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		err = os.NewSyscallError("syscall.Mmap", err)
+	}
+	return data, err
+}
+
+// canGrowCode reports whether the code mapping can grow after it, see
+// CodeModule.growCode.
+const canGrowCode = true
+
+// mmapAt maps size bytes at addr, which is only a hint, executable pages
+// are MAP_JIT as with Mmap.
+func mmapAt(size int, addr uintptr, exec bool) ([]byte, error) {
+	prot, flags := syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON
+	if exec {
+		prot, flags = prot|syscall.PROT_EXEC, flags|_MAP_JIT
+	}
+	ptr, _, errno := syscall.Syscall6(syscall.SYS_MMAP, addr, uintptr(size), uintptr(prot), uintptr(flags), ^uintptr(0), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("mmap", errno)
+	}
+	b := sliceHeader{Data: ptr, Len: size, Cap: size}
+	return *(*[]byte)(unsafe.Pointer(&b)), nil
+}
+
+// Munmap unmaps b with munmap directly, syscall.Munmap only unmaps the
+// mappings of syscall.Mmap, not those mapped at a hint or a shared image.
+func Munmap(b []byte) (err error) {
+	if len(b) == 0 {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MUNMAP, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), 0)
+	if errno != 0 {
+		err = os.NewSyscallError("munmap", errno)
+	}
+	return
+}
@@ -0,0 +1,29 @@
+// +build linux,amd64 linux,arm64 linux,riscv64 linux,mips64 linux,mips64le darwin,!arm64 freebsd,amd64
+
+package goloader
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// canGrowCode reports whether the code mapping can grow after it, see
+// CodeModule.growCode.
+const canGrowCode = true
+
+// mmapAt maps size bytes at addr, which is only a hint, the pages are
+// executable if exec.
+func mmapAt(size int, addr uintptr, exec bool) ([]byte, error) {
+	prot := syscall.PROT_READ | syscall.PROT_WRITE
+	if exec {
+		prot |= syscall.PROT_EXEC
+	}
+	ptr, _, errno := syscall.Syscall6(syscall.SYS_MMAP, addr, uintptr(size),
+		uintptr(prot), syscall.MAP_PRIVATE|syscall.MAP_ANON, ^uintptr(0), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("mmap", errno)
+	}
+	b := sliceHeader{Data: ptr, Len: size, Cap: size}
+	return *(*[]byte)(unsafe.Pointer(&b)), nil
+}
@@ -0,0 +1,18 @@
+// +build !linux !amd64,!arm64,!riscv64,!mips64,!mips64le,!s390x
+// +build !darwin
+// +build !freebsd !amd64
+// +build !windows
+
+package goloader
+
+import (
+	"errors"
+)
+
+// canGrowCode reports whether the code mapping can grow after it, see
+// CodeModule.growCode, mmapAt is not supported.
+const canGrowCode = false
+
+func mmapAt(size int, addr uintptr, exec bool) ([]byte, error) {
+	return nil, errors.New("goloader: mapping at an address is not supported on this platform")
+}
@@ -5,6 +5,7 @@ package goloader
 import (
 	"os"
 	"syscall"
+	"unsafe"
 )
 
 func Mmap(size int) ([]byte, error) {
@@ -20,10 +21,28 @@ func Mmap(size int) ([]byte, error) {
 	return data, err
 }
 
-func Munmap(b []byte) (err error) {
-	err = syscall.Munmap(b)
+func MmapData(size int) ([]byte, error) {
+	data, err := syscall.Mmap(
+		0,
+		0,
+		size,
+		syscall.PROT_READ|syscall.PROT_WRITE,
+		syscall.MAP_PRIVATE|syscall.MAP_ANON|syscall.MAP_32BIT)
 	if err != nil {
-		err = os.NewSyscallError("syscall.Munmap", err)
+		err = os.NewSyscallError("syscall.Mmap", err)
+	}
+	return data, err
+}
+
+// Munmap unmaps b with munmap directly, syscall.Munmap only unmaps the
+// mappings of syscall.Mmap, not those mapped at a hint or a shared image.
+func Munmap(b []byte) (err error) {
+	if len(b) == 0 {
+		return nil
+	}
+	_, _, errno := syscall.Syscall(syscall.SYS_MUNMAP, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), 0)
+	if errno != 0 {
+		err = os.NewSyscallError("munmap", errno)
 	}
 	return
 }
@@ -0,0 +1,40 @@
+package goloader
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"testing"
+	"unsafe"
+)
+
+// TestMmapDataIsNotExecutable checks the permissions of the mapping of the
+// data of a module in /proc/self/maps.
+func TestMmapDataIsNotExecutable(t *testing.T) {
+	data, err := MmapData(PageSize)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Munmap(data)
+	data[0] = 1
+	addr := uintptr(unsafe.Pointer(&data[0]))
+
+	f, err := os.Open("/proc/self/maps")
+	if err != nil {
+		t.Skip(err)
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var start, end uintptr
+		var perms string
+		if _, err := fmt.Sscanf(scanner.Text(), "%x-%x %s", &start, &end, &perms); err != nil || addr < start || addr >= end {
+			continue
+		}
+		if perms[:3] != "rw-" {
+			t.Errorf("data is mapped %s, want rw-", perms)
+		}
+		return
+	}
+	t.Errorf("no mapping of the data at 0x%x", addr)
+}
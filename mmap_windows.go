@@ -8,25 +8,46 @@ import (
 	"unsafe"
 )
 
+const (
+	_MEM_COMMIT  = 0x1000
+	_MEM_RESERVE = 0x2000
+	_MEM_RELEASE = 0x8000
+)
+
+var (
+	procVirtualAlloc = syscall.NewLazyDLL("kernel32.dll").NewProc("VirtualAlloc")
+	procVirtualFree  = syscall.NewLazyDLL("kernel32.dll").NewProc("VirtualFree")
+)
+
+// Mmap reserves and commits size bytes with VirtualAlloc, the pages are
+// PAGE_EXECUTE_READWRITE until the module is sealed, see CodeModule.Seal.
+// The memory is private to the process like the anonymous mappings of unix.
 func Mmap(size int) ([]byte, error) {
+	return virtualAlloc(0, size, syscall.PAGE_EXECUTE_READWRITE)
+}
 
-	sizelo := uint32(size >> 32)
-	sizehi := uint32(size) & 0xFFFFFFFF
-	h, errno := syscall.CreateFileMapping(syscall.InvalidHandle, nil,
-		syscall.PAGE_EXECUTE_READWRITE, sizelo, sizehi, nil)
-	if h == 0 {
-		return nil, os.NewSyscallError("CreateFileMapping", errno)
-	}
+// MmapData is like Mmap, the pages are PAGE_READWRITE.
+func MmapData(size int) ([]byte, error) {
+	return virtualAlloc(0, size, syscall.PAGE_READWRITE)
+}
 
-	addr, errno := syscall.MapViewOfFile(h,
-		syscall.FILE_MAP_READ|syscall.FILE_MAP_WRITE|syscall.FILE_MAP_EXECUTE,
-		0, 0, uintptr(size))
-	if addr == 0 {
-		return nil, os.NewSyscallError("MapViewOfFile", errno)
+// canGrowCode reports whether the code mapping can grow after it, see
+// CodeModule.growCode. VirtualFree releases an allocation as a whole, the
+// code can not be extended by another one.
+const canGrowCode = false
+
+// mmapAt allocates size bytes at addr, it fails if the range is not free.
+func mmapAt(size int, addr uintptr, exec bool) ([]byte, error) {
+	if exec {
+		return virtualAlloc(addr, size, syscall.PAGE_EXECUTE_READWRITE)
 	}
+	return virtualAlloc(addr, size, syscall.PAGE_READWRITE)
+}
 
-	if err := syscall.CloseHandle(syscall.Handle(h)); err != nil {
-		return nil, os.NewSyscallError("CloseHandle", err)
+func virtualAlloc(at uintptr, size int, prot uintptr) ([]byte, error) {
+	addr, _, err := procVirtualAlloc.Call(at, uintptr(size), _MEM_COMMIT|_MEM_RESERVE, prot)
+	if addr == 0 {
+		return nil, os.NewSyscallError("VirtualAlloc", err)
 	}
 
 	var header sliceHeader
@@ -39,10 +60,9 @@ func Mmap(size int) ([]byte, error) {
 }
 
 func Munmap(b []byte) error {
-
 	addr := (uintptr)(unsafe.Pointer(&b[0]))
-	if err := syscall.UnmapViewOfFile(addr); err != nil {
-		return os.NewSyscallError("UnmapViewOfFile", err)
+	if r, _, err := procVirtualFree.Call(addr, 0, _MEM_RELEASE); r == 0 {
+		return os.NewSyscallError("VirtualFree", err)
 	}
 	return nil
 }
@@ -0,0 +1,25 @@
+// +build go1.12
+
+package goloader
+
+import (
+	"runtime/debug"
+)
+
+// HostModules returns the modules the host is built with and their hashes,
+// e.g. as LoadOptions.Modules so a module must be built with the versions
+// of the host. It is nil if the host is not built in module mode.
+func HostModules() []ModuleSum {
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return nil
+	}
+	sums := make([]ModuleSum, 0, len(info.Deps))
+	for _, dep := range info.Deps {
+		if dep.Replace != nil {
+			dep = dep.Replace
+		}
+		sums = append(sums, ModuleSum{Path: dep.Path, Version: dep.Version, Sum: dep.Sum})
+	}
+	return sums
+}
@@ -0,0 +1,9 @@
+// +build !go1.12
+
+package goloader
+
+// HostModules returns nil, the build information of the host is read
+// since go1.12.
+func HostModules() []ModuleSum {
+	return nil
+}
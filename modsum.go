@@ -0,0 +1,97 @@
+package goloader
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ModuleSum is a module at a version with its hash, as in go.sum.
+type ModuleSum struct {
+	Path    string
+	Version string
+	Sum     string
+}
+
+// ParseGoSum returns the module hashes of a go.sum file, the hashes of
+// go.mod files are skipped.
+func ParseGoSum(data []byte) ([]ModuleSum, error) {
+	sums := make([]ModuleSum, 0)
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 0 {
+			continue
+		}
+		if len(fields) != 3 {
+			return nil, fmt.Errorf("goloader: go.sum line %d: want module, version and hash", line)
+		}
+		if strings.HasSuffix(fields[1], "/go.mod") {
+			continue
+		}
+		sums = append(sums, ModuleSum{Path: fields[0], Version: fields[1], Sum: fields[2]})
+	}
+	return sums, scanner.Err()
+}
+
+// PinModules records in the linker the modules of sums, e.g. from the
+// go.sum the objects were built with, whose packages the objects define or
+// refer to. Encode keeps them, Load checks them against LoadOptions.Modules.
+func (linker *Linker) PinModules(sums []ModuleSum) {
+	pkgs := make(map[string]bool)
+	for name, objsym := range linker.objsymbolMap {
+		pkgs[symbolPkg(name)] = true
+		for _, loc := range objsym.Reloc {
+			pkgs[symbolPkg(loc.Sym.Name)] = true
+		}
+	}
+	linker.modules = linker.modules[:0]
+	for _, sum := range sums {
+		for pkg := range pkgs {
+			if pkg == sum.Path || strings.HasPrefix(pkg, sum.Path+"/") {
+				linker.modules = append(linker.modules, sum)
+				break
+			}
+		}
+	}
+	sort.Slice(linker.modules, func(i, j int) bool { return linker.modules[i].Path < linker.modules[j].Path })
+}
+
+// Modules returns the modules pinned in the linker.
+func (linker *Linker) Modules() []ModuleSum {
+	return linker.modules
+}
+
+// checkModules checks that every module pinned in the linker is in allowed
+// with the same version and hash.
+func (linker *Linker) checkModules(allowed []ModuleSum) error {
+	if allowed == nil {
+		return nil
+	}
+	versions := make(map[string][]ModuleSum)
+	for _, sum := range allowed {
+		versions[sum.Path] = append(versions[sum.Path], sum)
+	}
+	for _, pinned := range linker.modules {
+		candidates, ok := versions[pinned.Path]
+		if !ok {
+			return fmt.Errorf("goloader: module %s is not allowed", pinned.Path)
+		}
+		found := false
+		for _, sum := range candidates {
+			if sum.Version != pinned.Version {
+				continue
+			}
+			if sum.Sum != pinned.Sum {
+				return fmt.Errorf("goloader: module %s@%s has hash %s, want %s", pinned.Path, pinned.Version, pinned.Sum, sum.Sum)
+			}
+			found = true
+		}
+		if !found {
+			return fmt.Errorf("goloader: module %s@%s is not allowed, want version %s", pinned.Path, pinned.Version, candidates[0].Version)
+		}
+	}
+	return nil
+}
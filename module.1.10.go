@@ -85,3 +85,11 @@ func init_func(symbol *ObjSymbol, nameOff, spOff, pcfileOff, pclnOff int) _func
 	}
 	return fdata
 }
+
+// lineTable returns the pcln table of f, nil if it has none.
+func (md *moduledata) lineTable(f *_func) []byte {
+	if f.pcln == 0 {
+		return nil
+	}
+	return md.pclntable[f.pcln:]
+}
@@ -94,8 +94,22 @@ func init_func(symbol *ObjSymbol, nameOff, spOff, pcfileOff, pclnOff int) _func
 		pcfile:    int32(pcfileOff),
 		pcln:      int32(pclnOff),
 		npcdata:   int32(len(symbol.Func.PCData)),
-		funcID:    funcID(objabi.GetFuncID(symbol.Name, strings.TrimLeft(symbol.Func.File[0], FileSymPrefix))),
+		funcID:    funcID(objabi.GetFuncID(symbol.Name, strings.TrimPrefix(funcFile(symbol), FileSymPrefix))),
 		nfuncdata: uint8(len(symbol.Func.FuncData)),
 	}
 	return fdata
 }
+
+// inlinedFuncID returns the funcID of a function inlined into another,
+// e.g. the runtime elides inlined wrappers in tracebacks.
+func (linker *Linker) inlinedFuncID(inl InlTreeNode) funcID {
+	return funcID(objabi.GetFuncID(inl.Func, strings.TrimPrefix(inl.File, FileSymPrefix)))
+}
+
+// lineTable returns the pcln table of f, nil if it has none.
+func (md *moduledata) lineTable(f *_func) []byte {
+	if f.pcln == 0 {
+		return nil
+	}
+	return md.pclntable[f.pcln:]
+}
@@ -121,3 +121,20 @@ func init_func(symbol *ObjSymbol, nameOff, spOff, pcfileOff, pclnOff int) _func
 	}
 	return fdata
 }
+
+// inlinedFuncID returns the funcID of a function inlined into another,
+// recorded by the object defining it, 0 for a function of another object.
+func (linker *Linker) inlinedFuncID(inl InlTreeNode) funcID {
+	if objsym, ok := linker.objsymbolMap[inl.Func]; ok && objsym.Func != nil {
+		return funcID(objsym.Func.FuncID)
+	}
+	return funcID(0)
+}
+
+// lineTable returns the pcln table of f, nil if it has none.
+func (md *moduledata) lineTable(f *_func) []byte {
+	if f.pcln == 0 {
+		return nil
+	}
+	return md.pctab[f.pcln:]
+}
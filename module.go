@@ -88,25 +88,39 @@ func gostringnocopy(str *byte) string
 //go:linkname moduledataverify1 runtime.moduledataverify1
 func moduledataverify1(datap *moduledata)
 
+//go:linkname modulesinit runtime.modulesinit
+func modulesinit()
+
+// addModule and removeModule change the list of modules of the runtime with
+// modulesLock held. The runtime walks the list without a lock, e.g. in
+// findmoduledatap, so a module is linked and unlinked with an atomic store
+// once it is complete, and the active modules the garbage collector scans
+// are published atomically by modulesinit.
 func addModule(codeModule *CodeModule) {
 	modules[codeModule.module] = true
 	for datap := &firstmoduledata; ; {
 		if datap.next == nil {
-			datap.next = codeModule.module
+			atomicstorep(unsafe.Pointer(&datap.next), unsafe.Pointer(codeModule.module))
 			break
 		}
 		datap = datap.next
 	}
+	//rebuild the active modules of the runtime, the garbage collector scans their data
+	modulesinit()
 }
+
+// removeModule unlinks module, a garbage collection in progress may still
+// scan it: its memory must not be unmapped before the cycle ends, see Unload.
 func removeModule(module interface{}) {
 	prevp := &firstmoduledata
 	for datap := &firstmoduledata; datap != nil; {
 		if datap == module {
-			prevp.next = datap.next
+			atomicstorep(unsafe.Pointer(&prevp.next), unsafe.Pointer(datap.next))
 			break
 		}
 		prevp = datap
 		datap = datap.next
 	}
 	delete(modules, module)
+	modulesinit()
 }
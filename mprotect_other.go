@@ -0,0 +1,7 @@
+// +build !darwin,!dragonfly,!freebsd,!linux,!openbsd,!netbsd,!windows
+
+package goloader
+
+func mprotect(b []byte, prot memProt) error {
+	return errProtectUnsupported
+}
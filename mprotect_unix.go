@@ -0,0 +1,24 @@
+// +build darwin dragonfly freebsd linux openbsd netbsd
+
+package goloader
+
+import (
+	"os"
+	"syscall"
+)
+
+func mprotect(b []byte, prot memProt) error {
+	flags := syscall.PROT_READ
+	switch prot {
+	case protReadExec:
+		flags |= syscall.PROT_EXEC
+	case protReadWrite:
+		flags |= syscall.PROT_WRITE
+	case protReadWriteExec:
+		flags |= syscall.PROT_WRITE | syscall.PROT_EXEC
+	}
+	if err := syscall.Mprotect(b, flags); err != nil {
+		return os.NewSyscallError("syscall.Mprotect", err)
+	}
+	return nil
+}
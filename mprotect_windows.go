@@ -0,0 +1,29 @@
+// +build windows
+
+package goloader
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+var procVirtualProtect = syscall.NewLazyDLL("kernel32.dll").NewProc("VirtualProtect")
+
+func mprotect(b []byte, prot memProt) error {
+	flags := uint32(syscall.PAGE_READONLY)
+	switch prot {
+	case protReadExec:
+		flags = syscall.PAGE_EXECUTE_READ
+	case protReadWrite:
+		flags = syscall.PAGE_READWRITE
+	case protReadWriteExec:
+		flags = syscall.PAGE_EXECUTE_READWRITE
+	}
+	var old uint32
+	r, _, err := procVirtualProtect.Call(uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)), uintptr(flags), uintptr(unsafe.Pointer(&old)))
+	if r == 0 {
+		return os.NewSyscallError("VirtualProtect", err)
+	}
+	return nil
+}
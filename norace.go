@@ -0,0 +1,5 @@
+// +build !race
+
+package goloader
+
+const raceEnabled = false
@@ -0,0 +1,12 @@
+package goloader
+
+// NUMAPolicy places the pages of a module on a NUMA node, so hot loaded
+// code and data are local to the cores running them.
+type NUMAPolicy struct {
+	// Node is the node the pages are bound to.
+	Node int
+	// Local places the pages on the node of the thread loading the module
+	// instead, lock the goroutine to its thread (runtime.LockOSThread) on a
+	// core of that node before Load. Node is ignored.
+	Local bool
+}
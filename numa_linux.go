@@ -0,0 +1,46 @@
+// +build linux
+
+package goloader
+
+import (
+	"fmt"
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+// from linux/mempolicy.h
+const (
+	mpolBind    = 2
+	mpolLocal   = 4
+	mpolMfMove  = 1 << 1
+	nodeMaskLen = 64
+)
+
+// mbind applies the NUMA policy to the pages of b.
+func mbind(b []byte, policy *NUMAPolicy) error {
+	if len(b) == 0 {
+		return nil
+	}
+	mode := uintptr(mpolLocal)
+	var nodemask []uint64
+	if !policy.Local {
+		if policy.Node < 0 {
+			return fmt.Errorf("goloader: bad NUMA node %d", policy.Node)
+		}
+		mode = mpolBind
+		nodemask = make([]uint64, policy.Node/nodeMaskLen+1)
+		nodemask[policy.Node/nodeMaskLen] = 1 << uint(policy.Node%nodeMaskLen)
+	}
+	var mask, maxnode uintptr
+	if len(nodemask) > 0 {
+		mask = uintptr(unsafe.Pointer(&nodemask[0]))
+		maxnode = uintptr(len(nodemask)*nodeMaskLen + 1)
+	}
+	_, _, errno := syscall.Syscall6(syscall.SYS_MBIND, uintptr(unsafe.Pointer(&b[0])), uintptr(len(b)),
+		mode, mask, maxnode, mpolMfMove)
+	if errno != 0 {
+		return os.NewSyscallError("mbind", errno)
+	}
+	return nil
+}
@@ -0,0 +1,11 @@
+// +build !linux
+
+package goloader
+
+import (
+	"errors"
+)
+
+func mbind(b []byte, policy *NUMAPolicy) error {
+	return errors.New("goloader: NUMA placement is only supported on linux")
+}
@@ -0,0 +1,72 @@
+// Package objparse reads go object files and archives without linking
+// them, e.g. to list the symbols, relocations and dependencies of an
+// object in analysis tools. The parsed symbols are the ones the linker
+// of goloader works on.
+package objparse
+
+import (
+	"bytes"
+	"os"
+	"sort"
+
+	"github.com/pkujhd/goloader"
+)
+
+// Object is a parsed object file, Syms maps symbol names to symbols.
+type Object struct {
+	*goloader.Pkg
+}
+
+// ParseFile parses the object file name of the package pkgPath, symbol
+// names keep the empty package path "" of the compiler for the package
+// itself.
+func ParseFile(name, pkgPath string) (*Object, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	pkg, err := goloader.ParseObj(f, name, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{pkg}, nil
+}
+
+// ParseBytes is like ParseFile, the object file is held in obj.
+func ParseBytes(obj []byte, pkgPath string) (*Object, error) {
+	pkg, err := goloader.ParseObj(bytes.NewReader(obj), "[]byte", pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	return &Object{pkg}, nil
+}
+
+// Symbols returns the sorted names of the symbols defined by the object.
+func (obj *Object) Symbols() []string {
+	names := make([]string, 0, len(obj.Syms))
+	for name := range obj.Syms {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// References returns the sorted names of the symbols the relocations of
+// the object refer to and the object does not define.
+func (obj *Object) References() []string {
+	seen := make(map[string]bool)
+	for _, sym := range obj.Syms {
+		for _, reloc := range sym.Reloc {
+			if _, ok := obj.Syms[reloc.Sym.Name]; !ok && reloc.Sym.Name != "" {
+				seen[reloc.Sym.Name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
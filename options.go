@@ -0,0 +1,100 @@
+package goloader
+
+// ReadOptions changes the behavior of ReadObjsWithOptions.
+type ReadOptions struct {
+	// HostPackages are import paths always bound to the symbols of the host,
+	// "net/..." matches net and the packages below it. Their functions and
+	// variables are stripped from the module and their init is not run
+	// again, e.g. for cgo heavy packages like net or os/signal which
+	// goloader can not relocate safely. Load fails if the host does not
+	// link a symbol the module uses.
+	HostPackages []string
+	// Strict fails if a symbol of the objects, other than DWARF, is not
+	// loaded, with the skipped kinds of each package, see SkippedKinds.
+	Strict bool
+	// Skipped, if not nil, is called with the skipped symbols, e.g. to warn.
+	Skipped func(skipped SkippedKinds)
+	// SharedData are read-only tables, matched as the patterns of
+	// SymbolPolicy, mapped once for all modules loaded from the same build.
+	// Only data symbols without relocations are shared, the others are
+	// loaded with the module; writing to a shared table faults.
+	SharedData []string
+	// Provided are symbols the objects define which are resolved by the
+	// host instead, e.g. the types of the packages shared by a LinkContext,
+	// so a module uses the same type descriptors as the module defining
+	// them. They must be in the symbols given to Load.
+	Provided []string
+}
+
+// LoadOptions changes the behavior of LoadWithOptions, the zero value is
+// the behavior of Load.
+type LoadOptions struct {
+	// StrictWeak makes a missing target of a weak relocation an error,
+	// by default the relocation resolves to zero as it does in the go linker.
+	StrictWeak bool
+	// WeakZeroed, if not nil, is called for each weak relocation resolved
+	// to zero, symbol holds the relocation and target is missing.
+	WeakZeroed func(symbol, target string)
+	// CompactSymbols replaces the Syms map of the module by a sorted index
+	// after the module is loaded, it saves memory for modules with many
+	// functions. Use Lookup, Symbols and LookupPrefix to find functions.
+	CompactSymbols bool
+	// DeferInit leaves the init functions of the packages to CodeModule.Init,
+	// e.g. to pin values or bind callbacks before package state is set up.
+	DeferInit bool
+	// Lock locks the code, data and pclntable pages of the module in memory
+	// so they are not paged out. Locked pages of all modules are accounted
+	// against RLIMIT_MEMLOCK, if they do not fit or mlock fails the module
+	// is loaded unlocked and LockFailed, if not nil, is called.
+	Lock       bool
+	LockFailed func(err error)
+	// NUMA, if not nil, places the pages of the module on a NUMA node,
+	// Load fails if the policy can not be applied.
+	NUMA *NUMAPolicy
+	// Shared, if not nil, places the module in a shared image, see SharedImage.
+	Shared *SharedImage
+	// Features turns features on or off, by default a feature is on if it
+	// is marked Default, see ListFeatures.
+	Features map[string]bool
+	// ResolveSymbol, if not nil, resolves external symbols missing in the
+	// symbols of the host, e.g. Dlsym for the C symbols of cgo packages.
+	ResolveSymbol func(name string) (uintptr, bool)
+	// HugePages backs the code of the module with huge pages, if they can not
+	// be mapped the code is mapped with base pages and HugePagesFailed, if
+	// not nil, is called.
+	HugePages       HugePages
+	HugePagesFailed func(err error)
+	// Modules, if not nil, are the modules a linker may be built with, each
+	// module pinned by Linker.PinModules must be in Modules with the same
+	// version and hash, e.g. ParseGoSum of the go.sum of the host or
+	// HostModules.
+	Modules []ModuleSum
+	// KeepDWARF relocates the DWARF symbols of the objects, see CodeModule.DWARF.
+	KeepDWARF bool
+	// DumpFarRelocs records the instruction words before and after each
+	// relocation whose target is out of range of its instruction, see
+	// CodeModule.RelocDumps. FarReloc, if not nil, is called with each of
+	// them, e.g. to route them to a logger.
+	DumpFarRelocs bool
+	FarReloc      func(dump RelocDump)
+	// Verifiers check the linker in order before anything is mapped, the
+	// first failing fails Load, see Verifier.
+	Verifiers []Verifier
+	// Symbols, if not nil, fails Load with a PolicyError if the module links
+	// a symbol it denies, a symbol of the host not allowed or any symbol
+	// denied, e.g. Deny: []string{"os/exec", "syscall"}. With StubDenied
+	// denied functions which are only called are linked to a stub panicking
+	// instead, see CodeModule.Stubbed.
+	Symbols    *SymbolPolicy
+	StubDenied bool
+	// MaxCodeBytes, MaxDataBytes and MaxSymbols limit the size of the module,
+	// 0 is no limit. They are checked before anything is mapped, the code
+	// mapping with its trampolines, grown or not, stays within MaxCodeBytes
+	// rounded up to a page.
+	MaxCodeBytes int
+	MaxDataBytes int
+	MaxSymbols   int
+	// fullTrampolines reserves the trampolines for the worst case instead of
+	// growing them, set to load again once the code could not grow
+	fullTrampolines bool
+}
@@ -0,0 +1,83 @@
+package goloader
+
+import (
+	"encoding/binary"
+	"errors"
+	"strings"
+)
+
+// addFile returns the index of file in the filetab of the module. Each
+// file is in filetab once, for all the functions of all the objects.
+func (linker *Linker) addFile(file string) int32 {
+	file = strings.TrimPrefix(file, FileSymPrefix)
+	if linker.fileIndex == nil {
+		linker.fileIndex = make(map[string]int32)
+	}
+	if index, ok := linker.fileIndex[file]; ok {
+		return index
+	}
+	index := int32(len(linker.filetab))
+	linker.fileIndex[file] = index
+	linker.filetab = append(linker.filetab, uint32(len(linker.pclntable)))
+	linker.pclntable = append(linker.pclntable, []byte(file)...)
+	linker.pclntable = append(linker.pclntable, ZeroByte)
+	return index
+}
+
+// remapPCValue rewrites a pc-value table whose values are indexes into
+// values, e.g. into the files of a function, to hold the values themselves.
+// See runtime.step for the encoding.
+func remapPCValue(p []byte, values []int32) ([]byte, error) {
+	if len(p) == 0 {
+		return p, nil
+	}
+	type entry struct {
+		val     int32
+		pcdelta uint64
+	}
+	entries := make([]entry, 0)
+	val := int32(-1)
+	for first := true; len(p) > 0; first = false {
+		uvdelta, n := binary.Uvarint(p)
+		if n <= 0 {
+			return nil, errors.New("bad pc-value table")
+		}
+		if uvdelta == 0 && !first {
+			break
+		}
+		p = p[n:]
+		val += int32(-(uint32(uvdelta) & 1) ^ (uint32(uvdelta) >> 1))
+		pcdelta, n := binary.Uvarint(p)
+		if n <= 0 {
+			return nil, errors.New("bad pc-value table")
+		}
+		p = p[n:]
+		mapped := val
+		if val >= 0 {
+			if int(val) >= len(values) {
+				return nil, errors.New("pc-value table index out of range")
+			}
+			mapped = values[val]
+		}
+		if len(entries) > 0 && entries[len(entries)-1].val == mapped {
+			//a delta of 0 ends the table, merge the entries
+			entries[len(entries)-1].pcdelta += pcdelta
+			continue
+		}
+		entries = append(entries, entry{val: mapped, pcdelta: pcdelta})
+	}
+	out := make([]byte, 0, len(entries)*2+1)
+	buf := make([]byte, binary.MaxVarintLen64)
+	prev := int32(-1)
+	for _, e := range entries {
+		delta := e.val - prev
+		uvdelta := uint32(delta) << 1
+		if delta < 0 {
+			uvdelta = uint32(^delta)<<1 | 1
+		}
+		out = append(out, buf[:binary.PutUvarint(buf, uint64(uvdelta))]...)
+		out = append(out, buf[:binary.PutUvarint(buf, e.pcdelta)]...)
+		prev = e.val
+	}
+	return append(out, ZeroByte), nil
+}
@@ -0,0 +1,43 @@
+package goloader
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"runtime"
+)
+
+// WritePerfMap writes the functions of the module to w in the format of
+// perf map files, one "START SIZE name" line per function in hex.
+func (cm *CodeModule) WritePerfMap(w io.Writer) error {
+	bw := bufio.NewWriter(w)
+	//the first entry of ftab is the start, the last one the end of the module
+	for i := 1; i+1 < len(cm.module.ftab); i++ {
+		entry := cm.module.ftab[i].entry
+		f := runtime.FuncForPC(entry)
+		if f == nil {
+			continue
+		}
+		size := cm.module.ftab[i+1].entry - entry
+		if _, err := fmt.Fprintf(bw, "%x %x %s\n", entry, size, f.Name()); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+// AppendPerfMap appends the functions of the module to /tmp/perf-<pid>.map,
+// where perf and other external profilers look up the symbols of code
+// generated at runtime.
+func (cm *CodeModule) AppendPerfMap() error {
+	f, err := os.OpenFile(fmt.Sprintf("/tmp/perf-%d.map", os.Getpid()), os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+	if err = cm.WritePerfMap(f); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,98 @@
+package goloader
+
+import (
+	"fmt"
+	"reflect"
+	"unsafe"
+)
+
+// Pointer passing rules between host and module:
+//
+// A module's data segment is mmaped outside the go heap. Unless the module
+// publishes gcdata/gcbss bitmaps, the garbage collector never scans it, so a
+// heap pointer handed to module code and stored in a module global is not a
+// GC root. Such values must be pinned for as long as the module may use them.
+//
+// Pointers into module memory (functions, types, data) must not be used by
+// the host after Unload.
+
+type pinned struct {
+	value interface{}
+	count int
+}
+
+func pinAddr(v interface{}) (uintptr, error) {
+	value := reflect.ValueOf(v)
+	switch value.Kind() {
+	case reflect.Ptr, reflect.UnsafePointer, reflect.Map, reflect.Chan, reflect.Func, reflect.Slice:
+		return value.Pointer(), nil
+	default:
+		return 0, fmt.Errorf("goloader: can not pin %T, not a pointer value", v)
+	}
+}
+
+// Pin keeps v reachable until it is unpinned or the module is unloaded.
+// Pin is reference counted, each Pin must be paired with an Unpin.
+func (cm *CodeModule) Pin(v interface{}) error {
+	addr, err := pinAddr(v)
+	if err != nil {
+		return err
+	}
+	if addr == 0 {
+		return nil
+	}
+	if cm.Contains(addr) {
+		return fmt.Errorf("goloader: pointer 0x%x is module memory, no need to pin", addr)
+	}
+	cm.pinLock.Lock()
+	defer cm.pinLock.Unlock()
+	if cm.pins == nil {
+		cm.pins = make(map[uintptr]*pinned)
+	}
+	if p, ok := cm.pins[addr]; ok {
+		p.count++
+	} else {
+		cm.pins[addr] = &pinned{value: v, count: 1}
+	}
+	return nil
+}
+
+// Unpin releases a reference taken by Pin.
+func (cm *CodeModule) Unpin(v interface{}) error {
+	addr, err := pinAddr(v)
+	if err != nil {
+		return err
+	}
+	cm.pinLock.Lock()
+	defer cm.pinLock.Unlock()
+	p, ok := cm.pins[addr]
+	if !ok {
+		return fmt.Errorf("goloader: pointer 0x%x is not pinned", addr)
+	}
+	p.count--
+	if p.count == 0 {
+		delete(cm.pins, addr)
+	}
+	return nil
+}
+
+// PinnedCount returns the number of distinct pointers pinned on the module.
+func (cm *CodeModule) PinnedCount() int {
+	cm.pinLock.Lock()
+	defer cm.pinLock.Unlock()
+	return len(cm.pins)
+}
+
+// Contains reports whether addr points into the module's mapped memory.
+func (cm *CodeModule) Contains(addr uintptr) bool {
+	for _, b := range [][]byte{cm.codeByte, cm.dataByte} {
+		if len(b) == 0 {
+			continue
+		}
+		base := uintptr(unsafe.Pointer(&b[0]))
+		if addr >= base && addr < base+uintptr(len(b)) {
+			return true
+		}
+	}
+	return false
+}
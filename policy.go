@@ -0,0 +1,120 @@
+package goloader
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var errDeniedCall = errors.New("goloader: called a function denied by LoadOptions.Symbols")
+
+// deniedStub is called instead of the functions stubbed by LoadOptions.StubDenied.
+func deniedStub() {
+	panic(errDeniedCall)
+}
+
+// DeniedSymbol is a symbol the module links which LoadOptions.Symbols does
+// not allow, with the symbols of the module referring to it.
+type DeniedSymbol struct {
+	Name      string
+	Referrers []string
+}
+
+// PolicyError is the error of Load if the module links symbols not allowed
+// by LoadOptions.Symbols.
+type PolicyError struct {
+	Denied []DeniedSymbol
+}
+
+func (e *PolicyError) Error() string {
+	names := make([]string, 0, len(e.Denied))
+	for _, denied := range e.Denied {
+		names = append(names, denied.Name)
+	}
+	return fmt.Sprintf("goloader: symbols not allowed: %s", strings.Join(names, ", "))
+}
+
+// isCallReloc reports whether a relocation of relocType is a direct call or
+// jump, which may be sent to a stub.
+func isCallReloc(relocType int) bool {
+	switch relocType {
+	case R_CALL, R_CALLARM, R_CALLARM64, R_CALLMIPS, R_JMPMIPS:
+		return true
+	}
+	return false
+}
+
+// deniedSymbols returns the symbols the module links which policy does not
+// allow, sorted by name, and whether every one of them is only called. Deny
+// applies to all the symbols linked, those of the objects too, e.g. an
+// os/exec compiled into the module, Allow to the symbols of the host.
+func (linker *Linker) deniedSymbols(policy *SymbolPolicy) (denied []DeniedSymbol, called bool) {
+	isDenied := func(name string) bool {
+		external := (linker.symMap[name] == nil || linker.symMap[name].Offset == InvalidOffset) && !linker.sharedData[name]
+		return matchAny(policy.Deny, name) || (external && len(policy.Allow) > 0 && !matchAny(policy.Allow, name))
+	}
+	referrers := make(map[string]map[string]bool)
+	called = true
+	for _, symbol := range linker.symMap {
+		for _, loc := range symbol.Reloc {
+			name := loc.Sym.Name
+			if isMarkerReloc(loc.Type) || loc.Type == R_CALLIND || name == TLSNAME || !isDenied(name) {
+				continue
+			}
+			if referrers[name] == nil {
+				referrers[name] = make(map[string]bool)
+			}
+			referrers[name][symbol.Name] = true
+			//the code of a stubbed function never runs
+			if !isDenied(symbol.Name) {
+				called = called && isCallReloc(loc.Type)
+			}
+		}
+	}
+	for name, refs := range referrers {
+		d := DeniedSymbol{Name: name}
+		for ref := range refs {
+			d.Referrers = append(d.Referrers, ref)
+		}
+		sort.Strings(d.Referrers)
+		denied = append(denied, d)
+	}
+	sort.Slice(denied, func(i, j int) bool { return denied[i].Name < denied[j].Name })
+	return denied, called
+}
+
+// checkPolicy fails if the module links denied symbols, unless they are
+// only called and stub is set.
+func (linker *Linker) checkPolicy(policy *SymbolPolicy, stub bool) error {
+	if policy == nil {
+		return nil
+	}
+	if denied, called := linker.deniedSymbols(policy); len(denied) > 0 && !(stub && called) {
+		return &PolicyError{Denied: denied}
+	}
+	return nil
+}
+
+// stubDenied makes the module call deniedStub instead of the denied symbols.
+func (cm *CodeModule) stubDenied(linker *Linker) {
+	if cm.options.Symbols == nil || !cm.options.StubDenied {
+		return
+	}
+	denied, _ := linker.deniedSymbols(cm.options.Symbols)
+	cm.stubbed = make(map[string]bool, len(denied))
+	for _, d := range denied {
+		cm.stubbed[d.Name] = true
+	}
+}
+
+// Stubbed returns the sorted symbols whose calls were sent to a stub
+// panicking, see LoadOptions.StubDenied.
+func (cm *CodeModule) Stubbed() []string {
+	names := make([]string, 0, len(cm.stubbed))
+	for name := range cm.stubbed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
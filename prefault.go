@@ -0,0 +1,35 @@
+package goloader
+
+import (
+	"os"
+	"runtime"
+)
+
+// prefaultSink keeps the reads of Prefault from being optimized away.
+var prefaultSink byte
+
+// Prefault touches every page of the module and looks up each of its
+// functions once, so the first calls after a hot load take no page faults
+// and find warm findfunctab and pclntable entries. If lock is true the
+// pages are also locked in memory as with LoadOptions.Lock.
+func (cm *CodeModule) Prefault(lock bool) error {
+	pageSize := os.Getpagesize()
+	var sum byte
+	for _, b := range [][]byte{cm.codeByte, cm.dataByte} {
+		for i := 0; i < len(b); i += pageSize {
+			sum += b[i]
+		}
+	}
+	prefaultSink = sum
+	//the last entry of ftab is the end of the module
+	for i := 0; i+1 < len(cm.module.ftab); i++ {
+		entry := cm.module.ftab[i].entry
+		if f := runtime.FuncForPC(entry); f != nil {
+			f.FileLine(entry)
+		}
+	}
+	if lock {
+		return cm.lock()
+	}
+	return nil
+}
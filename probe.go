@@ -0,0 +1,190 @@
+package goloader
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// return instruction of each arch
+var retCode = map[string][]byte{
+	"386":      {0xC3},                                           // RET
+	"amd64":    {0xC3},                                           // RET
+	"arm":      {0x1E, 0xFF, 0x2F, 0xE1},                         // BX LR
+	"arm64":    {0xC0, 0x03, 0x5F, 0xD6},                         // RET
+	"mips64":   {0x03, 0xE0, 0x00, 0x08, 0x00, 0x00, 0x00, 0x00}, // JR R31, NOP
+	"mips64le": {0x08, 0x00, 0xE0, 0x03, 0x00, 0x00, 0x00, 0x00}, // JR R31, NOP
+	"riscv64":  {0x67, 0x80, 0x00, 0x00},                         // JALR X0, 0(X1)
+	"s390x":    {0x07, 0xFE},                                     // BR R14
+}
+
+// SelfTest is a tiny module loaded by Probe, e.g. decoded from an encoded
+// linker embedded in the host and built by the same go version.
+type SelfTest struct {
+	Linker *Linker
+	SymPtr map[string]uintptr
+	// Func is the name of a func() of the module called by Probe.
+	Func string
+}
+
+// SelfTestFunc is the function of the registered self test modules.
+const SelfTestFunc = "main.Probe"
+
+// selfTest is a self test module registered by RegisterSelfTest.
+type selfTest struct {
+	variant string
+	bundle  []byte
+}
+
+var selfTests []selfTest
+
+// RegisterSelfTest registers an encoded self test module, a linker written
+// by Linker.Encode exporting SelfTestFunc, built as variant, e.g. "-N -l"
+// or "-race". Probe runs the registered modules when it is given no
+// SelfTest, "-race" only in a host built with -race. cmd/selftestgen
+// generates the file registering the variants for the go version running
+// it. Call it from init.
+func RegisterSelfTest(variant string, bundle []byte) {
+	selfTests = append(selfTests, selfTest{variant: variant, bundle: bundle})
+}
+
+// ProbeReport is the result of Probe, a nil error means the step passed.
+// The steps of the self test are skipped if the previous step failed
+// or no SelfTest is given and none is registered.
+type ProbeReport struct {
+	Arch        string
+	GoVersion   string
+	ExecMapping error    // executable memory can be mapped, written and run
+	Load        error    // the self test module is relocated and loaded
+	Traceback   error    // the runtime finds the functions of the module
+	GC          error    // the module runs and survives a garbage collection
+	SelfTest    bool     // the self test ran
+	Variants    []string // the registered self tests which ran, see RegisterSelfTest
+}
+
+// OK reports whether all steps passed.
+func (r *ProbeReport) OK() bool {
+	return r.ExecMapping == nil && r.Load == nil && r.Traceback == nil && r.GC == nil
+}
+
+func (r *ProbeReport) String() string {
+	lines := []string{fmt.Sprintf("%s %s", r.GoVersion, r.Arch)}
+	if len(r.Variants) > 0 {
+		lines[0] += " self tests: " + strings.Join(r.Variants, ", ")
+	}
+	steps := []struct {
+		name string
+		err  error
+	}{{"exec mapping", r.ExecMapping}, {"load", r.Load}, {"traceback", r.Traceback}, {"gc", r.GC}}
+	for i, step := range steps {
+		status := "ok"
+		if step.err != nil {
+			status = step.err.Error()
+		} else if i > 0 && !r.SelfTest {
+			status = "skipped"
+		}
+		lines = append(lines, fmt.Sprintf("%s: %s", step.name, status))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func probeExecMapping() error {
+	code, ok := retCode[runtime.GOARCH]
+	if !ok {
+		return fmt.Errorf("not support arch:%s", runtime.GOARCH)
+	}
+	b, err := Mmap(PageSize)
+	if err != nil {
+		return err
+	}
+	defer Munmap(b)
+	copy(b, code)
+	closure := funcValue(uintptr(unsafe.Pointer(&b[0])))
+	run := *(*func())(unsafe.Pointer(&closure))
+	run()
+	return nil
+}
+
+// Probe checks that goloader works in this process, it gives deployments
+// a fast go/no-go signal before real modules are accepted. If test is not
+// nil, the self test module is loaded, run, collected and unloaded, if it
+// is nil the modules registered by RegisterSelfTest are, each against the
+// symbols of the executable. A failure of a registered module is prefixed
+// with its variant.
+func Probe(test *SelfTest) *ProbeReport {
+	report := &ProbeReport{Arch: runtime.GOARCH, GoVersion: runtime.Version()}
+	if report.ExecMapping = probeExecMapping(); report.ExecMapping != nil {
+		return report
+	}
+	if test != nil {
+		report.SelfTest = true
+		report.selfTest(test)
+		return report
+	}
+	var symPtr map[string]uintptr
+	for _, registered := range selfTests {
+		if registered.variant == "-race" && !raceEnabled {
+			continue
+		}
+		report.SelfTest = true
+		report.Variants = append(report.Variants, registered.variant)
+		if symPtr == nil {
+			symPtr = make(map[string]uintptr)
+			if err := RegSymbol(symPtr); err != nil {
+				report.Load = fmt.Errorf("%s: %v", registered.variant, err)
+				return report
+			}
+		}
+		linker, err := DecodeLinker(bytes.NewReader(registered.bundle))
+		if err != nil {
+			report.Load = fmt.Errorf("%s: %v", registered.variant, err)
+			return report
+		}
+		report.selfTest(&SelfTest{Linker: linker, SymPtr: symPtr, Func: SelfTestFunc})
+		if !report.OK() {
+			for _, err := range []*error{&report.Load, &report.Traceback, &report.GC} {
+				if *err != nil {
+					*err = fmt.Errorf("%s: %v", registered.variant, *err)
+				}
+			}
+			return report
+		}
+	}
+	return report
+}
+
+// selfTest loads, runs, collects and unloads the self test module.
+func (report *ProbeReport) selfTest(test *SelfTest) {
+	codeModule, err := Load(test.Linker, test.SymPtr)
+	if err != nil {
+		report.Load = err
+		return
+	}
+	ptr, ok := codeModule.Lookup(test.Func)
+	if !ok {
+		report.Load = fmt.Errorf("function %s not found", test.Func)
+		codeModule.Unload()
+		return
+	}
+	if f := runtime.FuncForPC(ptr); f == nil || f.Name() != test.Func {
+		report.Traceback = fmt.Errorf("runtime does not find function %s at 0x%x", test.Func, ptr)
+	}
+	report.GC = func() (err error) {
+		defer func() {
+			if v := recover(); v != nil {
+				err = fmt.Errorf("%v", v)
+			}
+		}()
+		var run func()
+		if err = codeModule.LookupFunc(test.Func, &run); err != nil {
+			return err
+		}
+		run()
+		runtime.GC()
+		return nil
+	}()
+	codeModule.Unload()
+	runtime.GC()
+}
@@ -0,0 +1,23 @@
+package goloader
+
+import (
+	"testing"
+)
+
+// TestProbe runs the self tests registered by the file cmd/selftestgen
+// generates, CI generates it for each go version:
+//
+//	go run github.com/pkujhd/goloader/cmd/selftestgen -pkg goloader -out selftest_fixtures_test.go
+func TestProbe(t *testing.T) {
+	report := Probe(nil)
+	if report.ExecMapping != nil {
+		t.Fatalf("exec mapping: %v", report.ExecMapping)
+	}
+	if len(selfTests) == 0 {
+		t.Skip("no self test registered, generate selftest_fixtures_test.go")
+	}
+	if !report.SelfTest || !report.OK() {
+		t.Fatal(report)
+	}
+	t.Log(report)
+}
@@ -0,0 +1,7 @@
+// +build race
+
+package goloader
+
+// raceEnabled reports whether the host is built with -race, modules built
+// with -race call the race detector.
+const raceEnabled = true
@@ -4,19 +4,17 @@
 package goloader
 
 import (
-	"cmd/objfile/archive"
+	"bytes"
 	"cmd/objfile/goobj"
 	"cmd/objfile/objabi"
+	"errors"
 	"fmt"
 	"strings"
 	"unsafe"
 )
 
 //golang 1.16 change magic number
-var (
-	x86moduleHead = []byte{0xFA, 0xFF, 0xFF, 0xFF, 0x0, 0x0, 0x1, PtrSize}
-	armmoduleHead = []byte{0xFA, 0xFF, 0xFF, 0xFF, 0x0, 0x0, 0x4, PtrSize}
-)
+const pcHeaderMagic = 0xFFFFFFFA
 
 func initLinker() *Linker {
 	reloc := &Linker{
@@ -24,43 +22,30 @@ func initLinker() *Linker {
 		objsymbolMap: make(map[string]*ObjSymbol),
 		stkmaps:      make(map[string][]byte),
 		namemap:      make(map[string]int),
+		strtab:       make(map[string]string),
 	}
 	head := make([]byte, unsafe.Sizeof(pcHeader{}))
-	copy(head, x86moduleHead)
+	copy(head, pcHeaderHead(pcHeaderMagic))
 	reloc.pclntable = append(reloc.pclntable, head...)
 	return reloc
 }
 
 func (pkg *Pkg) symbols() error {
-	a, err := archive.Parse(pkg.f, false)
+	objs, err := readArchive(pkg.r, pkg.size)
 	if err != nil {
-		return err
+		return fmt.Errorf("Parse open %s: %v", pkg.name, err)
 	}
-	for _, e := range a.Entries {
-		switch e.Type {
-		case archive.EntryPkgDef:
-			//nothing todo
-		case archive.EntryGoObj:
-			b := make([]byte, e.Obj.Size)
-			_, err := pkg.f.ReadAt(b, e.Obj.Offset)
-			if err != nil {
-				return err
-			}
-			r := goobj.NewReaderFromBytes(b, false)
-			// Name of referenced indexed symbols.
-			nrefName := r.NRefName()
-			refNames := make(map[goobj.SymRef]string, nrefName)
-			for i := 0; i < nrefName; i++ {
-				rn := r.RefName(i)
-				refNames[rn.Sym()] = rn.Name(r)
-			}
-			pkg.Arch = e.Obj.Arch
-			nsym := r.NSym()
-			for i := 0; i < nsym; i++ {
-				pkg.addSym(r, uint32(i), &refNames)
-			}
-		default:
-			return fmt.Errorf("Parse open %s: unrecognized archive member %s", pkg.f.Name(), e.Name)
+	for _, obj := range objs {
+		b := make([]byte, obj.size)
+		if _, err := pkg.r.ReadAt(b, obj.offset); err != nil {
+			return err
+		}
+		if !bytes.HasPrefix(b, []byte(goobj.Magic)) {
+			//go1.17 and later change the object format again
+			return fmt.Errorf("Parse open %s: archive member %s built by %s is not in the go1.16 object format", pkg.name, obj.name, obj.version)
+		}
+		if err := pkg.objSymbols(b, obj); err != nil {
+			return fmt.Errorf("Parse open %s: archive member %s: %v", pkg.name, obj.name, err)
 		}
 	}
 	for _, sym := range pkg.Syms {
@@ -69,14 +54,45 @@ func (pkg *Pkg) symbols() error {
 	return nil
 }
 
-func resolveSymRef(s goobj.SymRef, r *goobj.Reader, refNames *map[goobj.SymRef]string) (string, uint32) {
+// objSymbols adds the symbols of the object file in b. The reader of
+// cmd/objfile/goobj indexes b without checking its bounds, a truncated or
+// corrupt object is returned as an error instead of a panic of the host.
+func (pkg *Pkg) objSymbols(b []byte, obj *archiveObj) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("corrupt object file: %v", r)
+		}
+	}()
+	r := goobj.NewReaderFromBytes(b, false)
+	if r == nil {
+		return errors.New("corrupt object file header")
+	}
+	// Name of referenced indexed symbols.
+	nrefName := r.NRefName()
+	refNames := make(map[goobj.SymRef]string, nrefName)
+	for i := 0; i < nrefName; i++ {
+		rn := r.RefName(i)
+		refNames[rn.Sym()] = rn.Name(r)
+	}
+	pkg.Arch = obj.arch
+	pkg.goVersion = obj.version
+	nsym := r.NSym()
+	for i := 0; i < nsym; i++ {
+		if err := pkg.addSym(r, uint32(i), &refNames); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func resolveSymRef(s goobj.SymRef, r *goobj.Reader, refNames *map[goobj.SymRef]string) (string, uint32, error) {
 	i := InvalidIndex
 	switch p := s.PkgIdx; p {
 	case goobj.PkgIdxInvalid:
 		if s.SymIdx != 0 {
-			panic("bad sym ref")
+			return EmptyString, i, fmt.Errorf("bad sym ref: package index %d symbol index %d", s.PkgIdx, s.SymIdx)
 		}
-		return EmptyString, i
+		return EmptyString, i, nil
 	case goobj.PkgIdxHashed64:
 		i = s.SymIdx + uint32(r.NSym())
 	case goobj.PkgIdxHashed:
@@ -85,23 +101,26 @@ func resolveSymRef(s goobj.SymRef, r *goobj.Reader, refNames *map[goobj.SymRef]s
 		i = s.SymIdx + uint32(r.NSym()+r.NHashed64def()+r.NHasheddef())
 	case goobj.PkgIdxBuiltin:
 		name, _ := goobj.BuiltinName(int(s.SymIdx))
-		return name, i
+		return name, i, nil
 	case goobj.PkgIdxSelf:
 		i = s.SymIdx
 	default:
-		return (*refNames)[s], i
+		return (*refNames)[s], i, nil
 	}
-	return r.Sym(i).Name(r), i
+	if i >= uint32(r.NSym()+r.NHashed64def()+r.NHasheddef()+r.NNonpkgdef()+r.NNonpkgref()) {
+		return EmptyString, InvalidIndex, fmt.Errorf("bad sym ref: symbol index %d out of range", i)
+	}
+	return r.Sym(i).Name(r), i, nil
 }
 
-func (pkg *Pkg) addSym(r *goobj.Reader, index uint32, refNames *map[goobj.SymRef]string) {
+func (pkg *Pkg) addSym(r *goobj.Reader, index uint32, refNames *map[goobj.SymRef]string) error {
 	s := r.Sym(index)
-	symbol := ObjSymbol{Name: s.Name(r), Kind: int(s.Type()), DupOK: s.Dupok(), Size: (int64)(s.Siz()), Func: &FuncInfo{}}
+	symbol := ObjSymbol{Name: pkg.intern(s.Name(r)), Kind: int(s.Type()), DupOK: s.Dupok(), Size: (int64)(s.Siz()), Align: int(s.Align()), Func: &FuncInfo{}}
 	if objabi.SymKind(symbol.Kind) == objabi.Sxxx || symbol.Name == EmptyString {
-		return
+		return nil
 	}
 	if _, ok := pkg.Syms[symbol.Name]; ok {
-		return
+		return nil
 	}
 	if symbol.Size > 0 {
 		symbol.Data = r.Data(index)
@@ -114,9 +133,14 @@ func (pkg *Pkg) addSym(r *goobj.Reader, index uint32, refNames *map[goobj.SymRef
 
 	auxs := r.Auxs(index)
 	for k := 0; k < len(auxs); k++ {
-		name, index := resolveSymRef(auxs[k].Sym(), r, refNames)
+		name, index, err := resolveSymRef(auxs[k].Sym(), r, refNames)
+		if err != nil {
+			return fmt.Errorf("symbol %s: %v", symbol.Name, err)
+		}
+		name = pkg.intern(name)
 		switch auxs[k].Type() {
 		case goobj.AuxGotype:
+			symbol.Type = name
 		case goobj.AuxFuncInfo:
 			funcInfo := goobj.FuncInfo{}
 			funcInfo.Read(r.Data(index))
@@ -124,16 +148,19 @@ func (pkg *Pkg) addSym(r *goobj.Reader, index uint32, refNames *map[goobj.SymRef
 			symbol.Func.Locals = funcInfo.Locals
 			symbol.Func.FuncID = (uint8)(funcInfo.FuncID)
 			for _, index := range funcInfo.File {
-				symbol.Func.File = append(symbol.Func.File, r.File(int(index)))
+				symbol.Func.File = append(symbol.Func.File, pkg.intern(r.File(int(index))))
 			}
 			for _, inl := range funcInfo.InlTree {
-				funcname, _ := resolveSymRef(inl.Func, r, refNames)
+				funcname, _, err := resolveSymRef(inl.Func, r, refNames)
+				if err != nil {
+					return fmt.Errorf("symbol %s: inline tree: %v", symbol.Name, err)
+				}
 				funcname = strings.Replace(funcname, EmptyPkgPath, pkg.PkgPath, -1)
 				inlNode := InlTreeNode{
 					Parent:   int64(inl.Parent),
-					File:     r.File(int(inl.File)),
+					File:     pkg.intern(r.File(int(inl.File))),
 					Line:     int64(inl.Line),
-					Func:     funcname,
+					Func:     pkg.intern(funcname),
 					ParentPC: int64(inl.ParentPC),
 				}
 				symbol.Func.InlTree = append(symbol.Func.InlTree, inlNode)
@@ -156,21 +183,32 @@ func (pkg *Pkg) addSym(r *goobj.Reader, index uint32, refNames *map[goobj.SymRef
 			symbol.Func.PCData = append(symbol.Func.PCData, r.Data(index))
 		}
 		if _, ok := pkg.Syms[name]; !ok && index != InvalidIndex {
-			pkg.addSym(r, index, refNames)
+			if err := pkg.addSym(r, index, refNames); err != nil {
+				return err
+			}
 		}
 	}
 
 	relocs := r.Relocs(index)
+	//allocate relocations and their symbols in one block
+	symbol.Reloc = make([]Reloc, len(relocs))
+	syms := make([]Sym, len(relocs))
 	for k := 0; k < len(relocs); k++ {
-		symbol.Reloc = append(symbol.Reloc, Reloc{})
 		symbol.Reloc[k].Add = int(relocs[k].Add())
 		symbol.Reloc[k].Offset = int(relocs[k].Off())
 		symbol.Reloc[k].Size = int(relocs[k].Siz())
 		symbol.Reloc[k].Type = int(relocs[k].Type())
-		name, index := resolveSymRef(relocs[k].Sym(), r, refNames)
-		symbol.Reloc[k].Sym = &Sym{Name: name, Offset: InvalidOffset}
+		name, index, err := resolveSymRef(relocs[k].Sym(), r, refNames)
+		if err != nil {
+			return fmt.Errorf("symbol %s: relocation %d: %v", symbol.Name, k, err)
+		}
+		syms[k] = Sym{Name: pkg.intern(name), Offset: InvalidOffset}
+		symbol.Reloc[k].Sym = &syms[k]
 		if _, ok := pkg.Syms[name]; !ok && index != InvalidIndex {
-			pkg.addSym(r, index, refNames)
+			if err := pkg.addSym(r, index, refNames); err != nil {
+				return err
+			}
 		}
 	}
+	return nil
 }
@@ -9,55 +9,75 @@ import (
 	"io"
 )
 
-var (
-	x86moduleHead = []byte{0xFB, 0xFF, 0xFF, 0xFF, 0x0, 0x0, 0x1, PtrSize}
-	armmoduleHead = []byte{0xFB, 0xFF, 0xFF, 0xFF, 0x0, 0x0, 0x4, PtrSize}
-)
-
 type readAtSeeker struct {
 	io.ReadSeeker
 }
 
+// BytesAt reads size bytes at offset, a short read is io.ErrUnexpectedEOF.
 func (r *readAtSeeker) BytesAt(offset, size int64) (bytes []byte, err error) {
+	if offset < 0 || size < 0 {
+		return nil, fmt.Errorf("invalid data range offset:%d size:%d", offset, size)
+	}
 	bytes = make([]byte, size)
 	_, err = r.Seek(offset, io.SeekStart)
 	if err == nil {
-		_, err = r.Read(bytes)
+		_, err = io.ReadFull(r, bytes)
 	}
 	return
 }
 
 func (pkg *Pkg) symbols() error {
-	obj, err := goobj.Parse(pkg.f, pkg.PkgPath)
+	members, err := readArchive(pkg.r, pkg.size)
 	if err != nil {
 		return fmt.Errorf("read error: %v", err)
 	}
+	for _, member := range members {
+		if err := pkg.memberSymbols(member); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (pkg *Pkg) memberSymbols(member *archiveObj) error {
+	sr := io.NewSectionReader(pkg.r, member.start, member.offset-member.start+member.size)
+	obj, err := goobj.Parse(sr, pkg.PkgPath)
+	if err != nil {
+		return fmt.Errorf("read error: %v, object file built by %s", err, member.version)
+	}
 	pkg.Arch = obj.Arch
-	fd := readAtSeeker{ReadSeeker: pkg.f}
+	pkg.goVersion = member.version
+	fd := readAtSeeker{ReadSeeker: sr}
 	for _, sym := range obj.Syms {
 		symbol := &ObjSymbol{}
-		symbol.Name = sym.Name
+		symbol.Name = pkg.intern(sym.Name)
 		symbol.Kind = int(sym.Kind)
 		symbol.DupOK = sym.DupOK
 		symbol.Size = int64(sym.Size)
+		symbol.Type = pkg.intern(sym.Type.Name)
 		symbol.Data, err = fd.BytesAt(sym.Data.Offset, sym.Data.Size)
 		if err != nil {
 			return fmt.Errorf("read error: %v", err)
 		}
 		grow(&symbol.Data, (int)(symbol.Size))
-		for _, loc := range sym.Reloc {
-			reloc := Reloc{
+		//allocate relocations and their symbols in one block
+		symbol.Reloc = make([]Reloc, len(sym.Reloc))
+		syms := make([]Sym, len(sym.Reloc))
+		for k, loc := range sym.Reloc {
+			syms[k] = Sym{Name: pkg.intern(loc.Sym.Name), Offset: InvalidOffset}
+			symbol.Reloc[k] = Reloc{
 				Offset: int(loc.Offset),
-				Sym:    &Sym{Name: loc.Sym.Name, Offset: InvalidOffset},
+				Sym:    &syms[k],
 				Type:   int(loc.Type),
 				Size:   int(loc.Size),
 				Add:    int(loc.Add)}
-			symbol.Reloc = append(symbol.Reloc, reloc)
 		}
 		if sym.Func != nil {
 			symbol.Func = &FuncInfo{}
 			symbol.Func.Args = uint32(sym.Func.Args)
-			symbol.Func.File = sym.Func.File
+			for _, file := range sym.Func.File {
+				symbol.Func.File = append(symbol.Func.File, pkg.intern(file))
+			}
 			symbol.Func.PCSP, err = fd.BytesAt(sym.Func.PCSP.Offset, sym.Func.PCSP.Size)
 			if err != nil {
 				return fmt.Errorf("read error: %v", err)
@@ -79,7 +99,7 @@ func (pkg *Pkg) symbols() error {
 				symbol.Func.PCData = append(symbol.Func.PCData, pcdata)
 			}
 			for _, data := range sym.Func.FuncData {
-				symbol.Func.FuncData = append(symbol.Func.FuncData, data.Sym.Name)
+				symbol.Func.FuncData = append(symbol.Func.FuncData, pkg.intern(data.Sym.Name))
 			}
 
 			if err = initInline(sym.Func, symbol.Func, pkg.PkgPath, &fd); err != nil {
@@ -91,13 +111,16 @@ func (pkg *Pkg) symbols() error {
 	return nil
 }
 
+const pcHeaderMagic = 0xFFFFFFFB
+
 func initLinker() *Linker {
 	reloc := &Linker{
 		symMap:       make(map[string]*Sym),
 		objsymbolMap: make(map[string]*ObjSymbol),
 		stkmaps:      make(map[string][]byte),
 		namemap:      make(map[string]int),
+		strtab:       make(map[string]string),
 	}
-	reloc.pclntable = append(reloc.pclntable, x86moduleHead...)
+	reloc.pclntable = append(reloc.pclntable, pcHeaderHead(pcHeaderMagic)...)
 	return reloc
 }
@@ -1,23 +1,46 @@
 package goloader
 
 import (
-	"cmd/objfile/sys"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"strings"
 )
 
 type Pkg struct {
-	Syms    map[string]*ObjSymbol
-	Arch    string
-	PkgPath string
-	f       *os.File
+	Syms      map[string]*ObjSymbol
+	Arch      string
+	PkgPath   string
+	name      string
+	r         io.ReaderAt
+	size      int64
+	strtab    map[string]string // interned strings, shared by the objects of a linker
+	goVersion string            // of the compiler, from the header of the objects
+}
+
+// intern returns the first copy seen of s, objects of a big bundle repeat
+// the same symbol and file names many times.
+func (pkg *Pkg) intern(s string) string {
+	if v, ok := pkg.strtab[s]; ok {
+		return v
+	}
+	pkg.strtab[s] = s
+	return s
+}
+
+func newPkg(r io.ReaderAt, name, pkgpath string) (*Pkg, error) {
+	size, err := readerSize(r)
+	if err != nil {
+		return nil, fmt.Errorf("read %s error: %v", name, err)
+	}
+	return &Pkg{Syms: make(map[string]*ObjSymbol, 0), PkgPath: pkgpath, name: name, r: r, size: size, strtab: make(map[string]string)}, nil
 }
 
 func Parse(f *os.File, pkgpath *string) ([]string, error) {
-	pkg := Pkg{Syms: make(map[string]*ObjSymbol, 0), f: f, PkgPath: *pkgpath}
 	symbols := make([]string, 0)
-	if err := pkg.symbols(); err != nil {
+	pkg, err := ParseObj(f, f.Name(), *pkgpath)
+	if err != nil {
 		return symbols, err
 	}
 	for _, sym := range pkg.Syms {
@@ -26,10 +49,27 @@ func Parse(f *os.File, pkgpath *string) ([]string, error) {
 	return symbols, nil
 }
 
+// ParseObj reads the symbols of the object file in r without linking
+// them, e.g. for tools which analyse objects. name is used in errors.
+func ParseObj(r io.ReaderAt, name, pkgPath string) (*Pkg, error) {
+	pkg, err := newPkg(r, name, pkgPath)
+	if err == nil {
+		err = pkg.symbols()
+	}
+	Audit(AuditParse, EmptyString, name, err)
+	if err != nil {
+		return nil, err
+	}
+	return pkg, nil
+}
+
 func readObj(pkg *Pkg, linker *Linker) error {
 	if pkg.PkgPath == EmptyString {
 		pkg.PkgPath = DefaultPkgPath
 	}
+	if linker.strtab != nil {
+		pkg.strtab = linker.strtab
+	}
 	if err := pkg.symbols(); err != nil {
 		return fmt.Errorf("read error: %v", err)
 	}
@@ -38,17 +78,17 @@ func readObj(pkg *Pkg, linker *Linker) error {
 	} else {
 		linker.Arch = pkg.Arch
 	}
-	switch linker.Arch {
-	case sys.ArchARM.Name, sys.ArchARM64.Name:
-		copy(linker.pclntable, armmoduleHead)
+	if err := linker.addToolchain(pkg); err != nil {
+		return err
 	}
 	for _, sym := range pkg.Syms {
 		for index, loc := range sym.Reloc {
-			sym.Reloc[index].Sym.Name = strings.Replace(loc.Sym.Name, EmptyPkgPath, pkg.PkgPath, -1)
+			sym.Reloc[index].Sym.Name = pkg.intern(strings.Replace(loc.Sym.Name, EmptyPkgPath, pkg.PkgPath, -1))
 		}
+		sym.Type = pkg.intern(strings.Replace(sym.Type, EmptyPkgPath, pkg.PkgPath, -1))
 		if sym.Func != nil {
 			for index, FuncData := range sym.Func.FuncData {
-				sym.Func.FuncData[index] = strings.Replace(FuncData, EmptyPkgPath, pkg.PkgPath, -1)
+				sym.Func.FuncData[index] = pkg.intern(strings.Replace(FuncData, EmptyPkgPath, pkg.PkgPath, -1))
 			}
 		}
 	}
@@ -60,32 +100,85 @@ func readObj(pkg *Pkg, linker *Linker) error {
 }
 
 func ReadObj(f *os.File, pkgpath *string) (*Linker, error) {
-	linker := initLinker()
-	pkg := Pkg{Syms: make(map[string]*ObjSymbol, 0), f: f, PkgPath: *pkgpath}
-	if err := readObj(&pkg, linker); err != nil {
+	return readObjFrom(f, f.Name(), *pkgpath)
+}
+
+// ReadObjFrom is like ReadObj, the object file is read from r,
+// e.g. a bytes.Reader or a file of embed.FS, and named by pkgPath in
+// errors and audit events.
+// The size of r is taken from its Size, Stat or Seek method.
+func ReadObjFrom(r io.ReaderAt, pkgPath string) (*Linker, error) {
+	return readObjFrom(r, pkgPath, pkgPath)
+}
+
+// ReadObjBytes is like ReadObjFrom, the object file is held in obj.
+func ReadObjBytes(obj []byte, pkgPath string) (*Linker, error) {
+	return readObjFrom(bytes.NewReader(obj), pkgPath, pkgPath)
+}
+
+func readObjFrom(r io.ReaderAt, name, pkgPath string) (linker *Linker, err error) {
+	defer func() { auditParse(linker, name, err) }()
+	linker = initLinker()
+	pkg, err := newPkg(r, name, pkgPath)
+	if err != nil {
+		return nil, err
+	}
+	if err := readObj(pkg, linker); err != nil {
 		return nil, err
 	}
+	linker.strtab = nil
+	linker.stripHostPackages()
 	if err := linker.addSymbols(); err != nil {
 		return nil, err
 	}
 	return linker, nil
 }
 
-func ReadObjs(files []string, pkgPath []string) (*Linker, error) {
-	linker := initLinker()
+func ReadObjs(files []string, pkgPath []string) (linker *Linker, err error) {
+	return ReadObjsWithOptions(files, pkgPath, ReadOptions{})
+}
+
+// ReadObjsWithOptions is like ReadObjs, options may bind packages to the host.
+func ReadObjsWithOptions(files []string, pkgPath []string, options ReadOptions) (linker *Linker, err error) {
+	defer func() { auditParse(linker, strings.Join(files, ","), err) }()
+	linker = initLinker()
+	linker.hostPkgs = options.HostPackages
+	if len(options.Provided) > 0 {
+		linker.provided = make(map[string]bool, len(options.Provided))
+		for _, name := range options.Provided {
+			linker.provided[name] = true
+		}
+	}
 	for i, file := range files {
 		f, err := os.Open(file)
 		if err != nil {
 			return nil, err
 		}
 		defer f.Close()
-		pkg := Pkg{Syms: make(map[string]*ObjSymbol, 0), f: f, PkgPath: pkgPath[i]}
-		if err := readObj(&pkg, linker); err != nil {
+		pkg, err := newPkg(f, file, pkgPath[i])
+		if err != nil {
+			return nil, err
+		}
+		if err := readObj(pkg, linker); err != nil {
 			return nil, err
 		}
 	}
+	linker.strtab = nil
+	linker.stripHostPackages()
+	linker.markSharedData(options.SharedData)
 	if err := linker.addSymbols(); err != nil {
 		return nil, err
 	}
+	if err := linker.checkSkipped(options); err != nil {
+		return nil, err
+	}
 	return linker, nil
 }
+
+func auditParse(linker *Linker, files string, err error) {
+	hash := EmptyString
+	if linker != nil {
+		hash = linker.Hash()
+	}
+	Audit(AuditParse, hash, files, err)
+}
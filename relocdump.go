@@ -0,0 +1,86 @@
+package goloader
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+)
+
+// RelocDump is a relocation whose target was out of range of its
+// instruction, recorded by Load when LoadOptions.DumpFarRelocs is set:
+// the instruction words before and after the relocation, e.g. the ADRP
+// pair rewritten to MOV/MOVK or the BL sent to a trampoline on arm64.
+type RelocDump struct {
+	Symbol     string // symbol holding the relocation
+	Offset     int    // of the relocation in Symbol
+	Target     string
+	Type       int
+	Addr       uintptr // address of the target
+	Before     []byte
+	After      []byte
+	Trampoline []byte // written for the relocation, if any
+}
+
+func (dump *RelocDump) String() string {
+	s := fmt.Sprintf("%s %s+0x%x -> %s (0x%x)\nbefore: %s\nafter:  %s", RelocTypeName(dump.Type),
+		dump.Symbol, dump.Offset, dump.Target, dump.Addr, hex.EncodeToString(dump.Before), hex.EncodeToString(dump.After))
+	if len(dump.Trampoline) > 0 {
+		s += "\ntrampoline: " + hex.EncodeToString(dump.Trampoline)
+	}
+	return s
+}
+
+// relocWindow returns the bytes of relocByte a relocation may rewrite, x86
+// PC-relative loads are rewritten from their opcode, s390x offsets are 2
+// bytes into their instruction.
+func relocWindow(loc Reloc, relocByte []byte) (int, int) {
+	start, end := loc.Offset, loc.Offset+loc.Size
+	if loc.Type == R_PCREL || loc.Type == R_PCRELDBL {
+		start -= 2
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end > len(relocByte) {
+		end = len(relocByte)
+	}
+	return start, end
+}
+
+// dumpFarReloc records a far relocation, before holds its bytes saved before
+// it was applied and trampoline the offset of the trampolines then.
+func (cm *CodeModule) dumpFarReloc(symbol *Sym, loc Reloc, addr uintptr, relocByte, before []byte, trampoline int) {
+	start, end := relocWindow(loc, relocByte)
+	dump := RelocDump{
+		Symbol: symbol.Name,
+		Offset: loc.Offset - symbol.Offset,
+		Target: loc.Sym.Name,
+		Type:   loc.Type,
+		Addr:   addr,
+		Before: before,
+		After:  append([]byte(nil), relocByte[start:end]...),
+	}
+	if cm.segment.offset > trampoline {
+		dump.Trampoline = append([]byte(nil), cm.segment.codeByte[trampoline:cm.segment.offset]...)
+	}
+	cm.relocDumps = append(cm.relocDumps, dump)
+	if cm.options.FarReloc != nil {
+		cm.options.FarReloc(dump)
+	}
+}
+
+// RelocDumps returns the far relocations recorded by Load, empty unless
+// LoadOptions.DumpFarRelocs is set.
+func (cm *CodeModule) RelocDumps() []RelocDump {
+	return cm.relocDumps
+}
+
+// WriteRelocDumps writes the far relocations recorded by Load to w.
+func (cm *CodeModule) WriteRelocDumps(w io.Writer) error {
+	for i := range cm.relocDumps {
+		if _, err := fmt.Fprintf(w, "%s\n", cm.relocDumps[i].String()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package goloader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+var relocTypeNames = map[int]string{
+	R_ADDR:           "R_ADDR",
+	R_ADDRARM64:      "R_ADDRARM64",
+	R_ADDROFF:        "R_ADDROFF",
+	R_WEAKADDROFF:    "R_WEAKADDROFF",
+	R_CALL:           "R_CALL",
+	R_CALLARM:        "R_CALLARM",
+	R_CALLARM64:      "R_CALLARM64",
+	R_CALLIND:        "R_CALLIND",
+	R_PCREL:          "R_PCREL",
+	R_GOTPCREL:       "R_GOTPCREL",
+	R_ARM64_GOTPCREL: "R_ARM64_GOTPCREL",
+	R_TLS_LE:         "R_TLS_LE",
+	R_METHODOFF:      "R_METHODOFF",
+	R_USEIFACE:       "R_USEIFACE",
+	R_USEIFACEMETHOD: "R_USEIFACEMETHOD",
+	R_USEFIELD:       "R_USEFIELD",
+	R_USETYPE:        "R_USETYPE",
+	R_KEEP:           "R_KEEP",
+	R_ADDRCUOFF:      "R_ADDRCUOFF",
+}
+
+// RelocTypeName returns the name of a relocation type, e.g. R_CALL.
+func RelocTypeName(relocType int) string {
+	if name, ok := relocTypeNames[relocType]; ok {
+		return name
+	}
+	return fmt.Sprintf("R_%d", relocType)
+}
+
+// RelocStat counts the relocations of one type.
+type RelocStat struct {
+	Count int // relocations applied
+	// Far is the number of relocations whose target was out of range of
+	// the instruction, they were rewritten (x86 MOV, arm64 ADRP) or sent
+	// through a trampoline.
+	Far int
+	// TrampolineBytes is the size of the trampolines written for them,
+	// TrampolineSpace the most they may need.
+	TrampolineBytes int
+	TrampolineSpace int
+}
+
+// RelocStats describes the relocations applied by Load.
+type RelocStats struct {
+	Types map[int]*RelocStat // by relocation type
+	// TrampolineBytes is the size of the trampolines written after the code,
+	// out of TrampolineSpace bytes mapped for them, which grow when they are
+	// full if the pages after them are free.
+	TrampolineBytes int
+	TrampolineSpace int
+}
+
+func (stats *RelocStats) stat(relocType int) *RelocStat {
+	if stats.Types == nil {
+		stats.Types = make(map[int]*RelocStat)
+	}
+	stat, ok := stats.Types[relocType]
+	if !ok {
+		stat = &RelocStat{}
+		stats.Types[relocType] = stat
+	}
+	return stat
+}
+
+func (stats *RelocStats) add(relocType int, far bool, trampolineBytes int) {
+	stat := stats.stat(relocType)
+	stat.Count++
+	if far {
+		stat.Far++
+	}
+	stat.TrampolineBytes += trampolineBytes
+}
+
+func (stats *RelocStats) String() string {
+	types := make([]int, 0, len(stats.Types))
+	for relocType := range stats.Types {
+		types = append(types, relocType)
+	}
+	sort.Ints(types)
+	lines := make([]string, 0, len(types)+1)
+	for _, relocType := range types {
+		stat := stats.Types[relocType]
+		line := fmt.Sprintf("%s: %d far: %d", RelocTypeName(relocType), stat.Count, stat.Far)
+		if stat.Count > 0 {
+			line += fmt.Sprintf(" (%.1f%%)", float64(stat.Far)*100/float64(stat.Count))
+		}
+		if stat.TrampolineSpace > 0 {
+			line += fmt.Sprintf(" trampoline: %d/%d bytes", stat.TrampolineBytes, stat.TrampolineSpace)
+		}
+		lines = append(lines, line)
+	}
+	lines = append(lines, fmt.Sprintf("trampoline: %d/%d bytes", stats.TrampolineBytes, stats.TrampolineSpace))
+	return strings.Join(lines, "\n")
+}
+
+// RelocStats returns the relocation statistics of the module.
+func (cm *CodeModule) RelocStats() RelocStats {
+	return cm.relocStats
+}
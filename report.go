@@ -0,0 +1,126 @@
+package goloader
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+)
+
+// reloc bytes dumped around a failing relocation
+const reportDumpSize = 32
+
+// FailedReloc is the relocation Load failed on.
+type FailedReloc struct {
+	Symbol string // symbol holding the relocation
+	Target string
+	Type   int
+	Offset int     // of the relocation in the segment
+	Addr   uintptr // address of the target
+	Dump   string  // hex dump of the bytes around the relocation
+}
+
+// LoadError is the error returned by Load, Report writes what is needed to
+// debug a failure on a platform the developer does not have.
+type LoadError struct {
+	Err         error
+	GoVersion   string
+	GOARCH      string
+	HostSymbols int      // symbols registered by the host
+	Unresolved  []string // external symbols the host does not register
+	RelocStats  RelocStats
+	Reloc       *FailedReloc
+	Link        *LinkError // all the unresolved symbols and failing relocations, if linking failed
+}
+
+func (e *LoadError) Error() string {
+	return e.Err.Error()
+}
+
+func newLoadError(err error, linker *Linker, symPtr map[string]uintptr, codeModule *CodeModule) error {
+	if _, ok := err.(*LoadError); ok {
+		return err
+	}
+	e := &LoadError{
+		Err:         err,
+		GoVersion:   runtime.Version(),
+		GOARCH:      runtime.GOARCH,
+		HostSymbols: len(symPtr),
+		RelocStats:  codeModule.relocStats,
+		Reloc:       codeModule.failedReloc,
+	}
+	e.Link, _ = err.(*LinkError)
+	for name, sym := range linker.symMap {
+		if sym.Offset == InvalidOffset && name != TLSNAME && !linker.sharedData[name] {
+			if _, ok := symPtr[name]; !ok {
+				e.Unresolved = append(e.Unresolved, name)
+			}
+		}
+	}
+	sort.Strings(e.Unresolved)
+	return e
+}
+
+// failReloc returns the failing relocation, the first is reported by Report.
+func (cm *CodeModule) failReloc(symbol *Sym, loc Reloc, addr uintptr, relocByte []byte) *FailedReloc {
+	start, end := loc.Offset-reportDumpSize/2, loc.Offset+reportDumpSize/2
+	if start < 0 {
+		start = 0
+	}
+	if end > len(relocByte) {
+		end = len(relocByte)
+	}
+	dump := EmptyString
+	if start < end {
+		dump = hex.Dump(relocByte[start:end])
+	}
+	failed := &FailedReloc{
+		Symbol: symbol.Name,
+		Target: loc.Sym.Name,
+		Type:   loc.Type,
+		Offset: loc.Offset,
+		Addr:   addr,
+		Dump:   dump,
+	}
+	if cm.failedReloc == nil {
+		cm.failedReloc = failed
+	}
+	return failed
+}
+
+// Report writes a diagnostic report of the failure to w, attach it to
+// bug reports.
+func (e *LoadError) Report(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "error: %v\ngo: %s %s\nhost symbols: %d\n", e.Err, e.GoVersion, e.GOARCH, e.HostSymbols); err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "unresolved symbols: %d\n", len(e.Unresolved)); err != nil {
+		return err
+	}
+	for _, name := range e.Unresolved {
+		if _, err := fmt.Fprintf(w, "\t%s\n", name); err != nil {
+			return err
+		}
+	}
+	if _, err := fmt.Fprintf(w, "relocations:\n%s\n", e.RelocStats.String()); err != nil {
+		return err
+	}
+	if e.Reloc != nil {
+		if _, err := fmt.Fprintf(w, "failed relocation: %s in %s at offset 0x%x to %s (0x%x)\n%s",
+			RelocTypeName(e.Reloc.Type), e.Reloc.Symbol, e.Reloc.Offset, e.Reloc.Target, e.Reloc.Addr, e.Reloc.Dump); err != nil {
+			return err
+		}
+	}
+	if e.Link != nil {
+		if _, err := fmt.Fprintf(w, "diagnostics: %d\n", len(e.Link.Diagnostics)); err != nil {
+			return err
+		}
+		for _, d := range e.Link.Diagnostics {
+			if _, err := fmt.Fprintf(w, "\t%s: %v\n", d.Symbol, d.Err); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
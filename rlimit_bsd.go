@@ -0,0 +1,5 @@
+// +build darwin dragonfly freebsd openbsd netbsd
+
+package goloader
+
+const rlimitMemlock = 6
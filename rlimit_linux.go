@@ -0,0 +1,6 @@
+// +build linux
+// +build !mips,!mipsle,!mips64,!mips64le
+
+package goloader
+
+const rlimitMemlock = 8
@@ -0,0 +1,65 @@
+// Package rpcmount registers values of a loaded module as net/rpc services.
+//
+// A service is a package-level variable of the module, e.g.
+//
+//	type Arith struct{}
+//
+//	func (t *Arith) Multiply(args *Args, reply *int) error
+//
+//	var Service = &Arith{}
+//
+// whose exported methods of the form net/rpc expects become the methods
+// of the service. The type of the variable is taken from the go type
+// information of the object file, no glue code is needed in the host.
+//
+// gRPC services are not supported, they need the descriptors protoc
+// generates, which the host links, see the README.
+package rpcmount
+
+import (
+	"fmt"
+	"net/rpc"
+	"reflect"
+
+	"github.com/pkujhd/goloader"
+)
+
+// Register registers the variable symbol of module with server as the
+// service name, if name is empty the name of the type is used as
+// net/rpc does. If server is nil rpc.DefaultServer is used.
+func Register(server *rpc.Server, module *goloader.CodeModule, name, symbol string) error {
+	if server == nil {
+		server = rpc.DefaultServer
+	}
+	rcvr, err := receiver(module, symbol)
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		return server.Register(rcvr)
+	}
+	return server.RegisterName(name, rcvr)
+}
+
+// receiver returns the value of the variable symbol, methods with pointer
+// receivers are kept by taking the address of a non-pointer variable.
+func receiver(module *goloader.CodeModule, symbol string) (interface{}, error) {
+	v, err := module.VarValue(symbol)
+	if err != nil {
+		return nil, err
+	}
+	switch v.Kind() {
+	case reflect.Ptr:
+		if v.IsNil() {
+			return nil, fmt.Errorf("rpcmount: %s is nil", symbol)
+		}
+		return v.Interface(), nil
+	case reflect.Interface:
+		if v.IsNil() {
+			return nil, fmt.Errorf("rpcmount: %s is nil", symbol)
+		}
+		return v.Elem().Interface(), nil
+	default:
+		return v.Addr().Interface(), nil
+	}
+}
@@ -0,0 +1,18 @@
+// Package runtimeload loads a goloader.Linker into the running process:
+// it maps the code and data, relocates them, registers the module with
+// the runtime and runs the package init functions.
+package runtimeload
+
+import (
+	"github.com/pkujhd/goloader"
+)
+
+// Load loads linker, relocations are resolved against symPtr.
+func Load(linker *goloader.Linker, symPtr map[string]uintptr, options goloader.LoadOptions) (*goloader.CodeModule, error) {
+	return goloader.LoadWithOptions(linker, symPtr, options)
+}
+
+// Unload removes module from the runtime and unmaps it.
+func Unload(module *goloader.CodeModule) {
+	module.Unload()
+}
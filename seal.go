@@ -0,0 +1,111 @@
+package goloader
+
+import (
+	"errors"
+	"os"
+)
+
+type memProt int
+
+const (
+	protRead memProt = iota
+	protReadExec
+	protReadWrite
+	protReadWriteExec
+)
+
+var errProtectUnsupported = errors.New("goloader: memory protection is not supported on this platform")
+
+// protect changes the protection of the pages of b lying entirely in
+// [start, end), b is the code or the data mapping of a module.
+func protect(b []byte, start, end int, prot memProt) error {
+	pageSize := os.Getpagesize()
+	start = alignof(start, pageSize)
+	if end > len(b) {
+		end = len(b)
+	}
+	end = end - end%pageSize
+	if start >= end {
+		return nil
+	}
+	return mprotect(b[start:end], prot)
+}
+
+// protectRodata makes the pages of the data segment holding only read-only
+// symbols (SRODATA, type metadata, strings) read only.
+func (linker *Linker) protectRodata(codeModule *CodeModule) error {
+	pageSize := os.Getpagesize()
+	dataEnd := codeModule.dataLen
+	writable := make([]bool, (dataEnd+pageSize-1)/pageSize)
+	mark := func(start, end int) {
+		for page := start / pageSize; page*pageSize < end; page++ {
+			writable[page] = true
+		}
+	}
+	//static_tmp is on linker.data[0]
+	mark(0, IntSize)
+	for name, sym := range linker.symMap {
+		if sym.Kind == STEXT || sym.Kind == SRODATA || sym.Offset == InvalidOffset {
+			continue
+		}
+		if objsym, ok := linker.objsymbolMap[name]; ok && len(objsym.Data) > 0 {
+			mark(sym.Offset, sym.Offset+len(objsym.Data))
+		}
+	}
+	for page := 0; page < len(writable); {
+		if writable[page] {
+			page++
+			continue
+		}
+		start := page
+		for page < len(writable) && !writable[page] {
+			page++
+		}
+		begin, end := start*pageSize, page*pageSize
+		if end > dataEnd {
+			end = dataEnd
+		}
+		if err := protect(codeModule.dataByte, begin, end, protRead); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Seal makes the code and the trampolines of the module read-execute only,
+// after which the module can not be patched any more.
+func (cm *CodeModule) Seal() error {
+	if err := cm.protectCode(protReadExec); err != nil {
+		return err
+	}
+	cm.sealed = true
+	return nil
+}
+
+// protectCode changes the protection of the code mapping, the code and
+// the trampolines.
+func (cm *CodeModule) protectCode(prot memProt) error {
+	return protect(cm.codeByte, 0, len(cm.codeByte), prot)
+}
+
+// writeCode makes the pages of the code and trampolines in [start, end)
+// read-write while write runs, with FeatureWXorX they are read-execute
+// otherwise. Goroutines running code on those pages must be stopped.
+func (cm *CodeModule) writeCode(start, end int, write func() error) error {
+	if cm.sealed {
+		return errors.New("goloader: module is sealed")
+	}
+	if !cm.enabled(FeatureWXorX) {
+		return write()
+	}
+	pageSize := os.Getpagesize()
+	start, end = start-start%pageSize, alignof(end, pageSize)
+	if err := protect(cm.codeByte, start, end, protReadWrite); err != nil {
+		return err
+	}
+	err := write()
+	if protErr := protect(cm.codeByte, start, end, protReadExec); err == nil {
+		err = protErr
+	}
+	return err
+}
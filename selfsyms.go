@@ -0,0 +1,51 @@
+package goloader
+
+import (
+	"os"
+	"reflect"
+	"strings"
+	"unsafe"
+)
+
+// symbolTypeName returns the name of t in symbol names, its string with the
+// package name replaced by the package path, e.g. *example.com/pkg.T.
+func symbolTypeName(t *_type) string {
+	name := t.string()
+	pkgpath := t.PkgPath()
+	if pkgpath == EmptyString && t.Kind() == reflect.Ptr {
+		if element := *(**_type)(add(unsafe.Pointer(t), unsafe.Sizeof(_type{}))); element != nil {
+			pkgpath = element.PkgPath()
+		}
+	}
+	if pkgpath == EmptyString {
+		return name
+	}
+	return strings.Replace(name, pkgname(pkgpath), pkgpath, 1)
+}
+
+// registerItabs adds the itabs of md, named as the go linker names them,
+// go.itab.<type>,<interface>.
+func registerItabs(md *moduledata, symPtr map[string]uintptr) {
+	for _, itab := range md.itablinks {
+		name := ItabPrefix + symbolTypeName(itab._type) + "," + symbolTypeName(&itab.inter.typ)
+		symPtr[name] = uintptr(unsafe.Pointer(itab))
+	}
+}
+
+// RegSymbolsFromSelf adds the symbols of the running program read from its
+// own tables, without the executable file: the functions from the
+// pclntable, the types from the typelinks and the itabs from the itablinks.
+// Package-level variables are not in these tables, they are added from the
+// symbol table of the executable if it can be read, os.Stdout always.
+func RegSymbolsFromSelf(symPtr map[string]uintptr) error {
+	md := firstmoduledata
+	typelinksinit(symPtr)
+	registerItabs(&md, symPtr)
+	symPtr[OsStdout] = uintptr(unsafe.Pointer(&os.Stdout))
+	if path, err := os.Executable(); err == nil {
+		if _, err := os.Stat(path); err == nil {
+			return regSymbol(symPtr, path)
+		}
+	}
+	return nil
+}
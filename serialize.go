@@ -0,0 +1,322 @@
+package goloader
+
+import (
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"hash/fnv"
+	"io"
+	"reflect"
+	"runtime"
+	"unsafe"
+)
+
+// layout of an encoded Linker:
+//
+//	magic | gob(encodeHeader) | gob(encodedLinker)
+//
+// The layout of _func and findfuncbucket depends on the go version, so
+// an encoded Linker is only accepted by the same go version and GOARCH.
+// gob zero-fills the fields a reader has and the encoding lacks, so the
+// header also holds a fingerprint of the fields of encodedLinker.
+const (
+	encodeMagic   = "goloader"
+	encodeVersion = 2
+)
+
+type encodeHeader struct {
+	Version   int
+	GOARCH    string
+	GoVersion string
+	Fields    uint32 // encodeFields
+}
+
+// encodeFields fingerprints the names and types of the fields of
+// encodedLinker and of the types it holds.
+var encodeFields = fieldsFingerprint(reflect.TypeOf(encodedLinker{}))
+
+func fieldsFingerprint(t reflect.Type) uint32 {
+	h := fnv.New32a()
+	seen := make(map[reflect.Type]bool)
+	var walk func(t reflect.Type)
+	walk = func(t reflect.Type) {
+		if seen[t] {
+			return
+		}
+		seen[t] = true
+		switch t.Kind() {
+		case reflect.Ptr, reflect.Slice, reflect.Array:
+			walk(t.Elem())
+		case reflect.Map:
+			walk(t.Key())
+			walk(t.Elem())
+		case reflect.Struct:
+			for i := 0; i < t.NumField(); i++ {
+				field := t.Field(i)
+				if field.PkgPath != EmptyString {
+					//unexported, gob skips it
+					continue
+				}
+				fmt.Fprintf(h, "%s.%s %s;", t, field.Name, field.Type)
+				walk(field.Type)
+			}
+		}
+	}
+	walk(t)
+	return h.Sum32()
+}
+
+type encodedReloc struct {
+	Offset    int
+	Size      int
+	Type      int
+	Add       int
+	Sym       string
+	SymKind   int
+	SymOffset int
+	Shared    bool // Sym is the symbol of linker.symMap
+}
+
+type encodedSym struct {
+	Name     string
+	Kind     int
+	Offset   int
+	HasFunc  bool
+	PCData   []uint32
+	FuncData []string // names of linker.stkmaps
+	Reloc    []encodedReloc
+}
+
+type encodedLinker struct {
+	Code       []byte
+	Data       []byte
+	Syms       []encodedSym
+	ObjSymbols map[string]*ObjSymbol
+	Stkmaps    map[string][]byte
+	Namemap    map[string]int
+	Filetab    []uint32
+	FileIndex  map[string]int32
+	Pclntable  []byte
+	Pcfunc     []byte
+	Funcs      []byte
+	InitFuncs  []string
+	Arch       string
+	HostPkgs   []string
+	Modules    []ModuleSum
+	Degraded   map[string][]string
+	Externs    []Extern
+	SharedData map[string]bool
+	Toolchain  Toolchain
+	Provided   map[string]bool
+}
+
+func sliceBytes(ptr unsafe.Pointer, size int) []byte {
+	b := make([]byte, size)
+	if size > 0 {
+		copy2Slice(b, uintptr(ptr), size)
+	}
+	return b
+}
+
+// Encode writes linker to w, DecodeLinker reads it back in a process
+// of the same go version and GOARCH.
+func (linker *Linker) Encode(w io.Writer) error {
+	e, err := linker.encoded()
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(w, encodeMagic); err != nil {
+		return err
+	}
+	enc := gob.NewEncoder(w)
+	header := newEncodeHeader()
+	if err := enc.Encode(&header); err != nil {
+		return err
+	}
+	return enc.Encode(e)
+}
+
+func newEncodeHeader() encodeHeader {
+	return encodeHeader{Version: encodeVersion, GOARCH: runtime.GOARCH, GoVersion: runtime.Version(), Fields: encodeFields}
+}
+
+func (header *encodeHeader) check() error {
+	if header.Version != encodeVersion || header.GOARCH != runtime.GOARCH || header.GoVersion != runtime.Version() {
+		return fmt.Errorf("goloader: encoded linker is format %d %s %s, want format %d %s %s",
+			header.Version, header.GOARCH, header.GoVersion, encodeVersion, runtime.GOARCH, runtime.Version())
+	}
+	if header.Fields != encodeFields {
+		return fmt.Errorf("goloader: encoded linker has fields %08x, want %08x, it was written by another goloader", header.Fields, encodeFields)
+	}
+	return nil
+}
+
+// encoded returns the encoded form of linker, its sections held in a
+// container are inflated first.
+func (linker *Linker) encoded() (*encodedLinker, error) {
+	if err := linker.inflate(); err != nil {
+		return nil, err
+	}
+	stkmapNames := make(map[uintptr]string)
+	for name, b := range linker.stkmaps {
+		if len(b) > 0 {
+			stkmapNames[uintptr(unsafe.Pointer(&b[0]))] = name
+		}
+	}
+	e := encodedLinker{
+		Code:       linker.code,
+		Data:       linker.data,
+		ObjSymbols: linker.objsymbolMap,
+		Stkmaps:    linker.stkmaps,
+		Namemap:    linker.namemap,
+		Filetab:    linker.filetab,
+		FileIndex:  linker.fileIndex,
+		Pclntable:  linker.pclntable,
+		InitFuncs:  linker.initFuncs,
+		Arch:       linker.Arch,
+		HostPkgs:   linker.hostPkgs,
+		Modules:    linker.modules,
+		Degraded:   linker.degraded,
+		Externs:    linker.externs,
+		SharedData: linker.sharedData,
+		Toolchain:  linker.toolchain,
+		Provided:   linker.provided,
+	}
+	if len(linker.pcfunc) > 0 {
+		e.Pcfunc = sliceBytes(unsafe.Pointer(&linker.pcfunc[0]), len(linker.pcfunc)*FindFuncBucketSize)
+	}
+	if len(linker._func) > 0 {
+		e.Funcs = sliceBytes(unsafe.Pointer(&linker._func[0]), len(linker._func)*int(unsafe.Sizeof(_func{})))
+	}
+	for name, sym := range linker.symMap {
+		if sym.Name != name {
+			continue
+		}
+		es := encodedSym{Name: sym.Name, Kind: sym.Kind, Offset: sym.Offset}
+		if sym.Func != nil {
+			es.HasFunc = true
+			es.PCData = sym.Func.PCData
+			for _, ptr := range sym.Func.FuncData {
+				if ptr == 0 {
+					es.FuncData = append(es.FuncData, EmptyString)
+				} else if name, ok := stkmapNames[ptr]; ok {
+					es.FuncData = append(es.FuncData, name)
+				} else {
+					return nil, fmt.Errorf("goloader: funcdata 0x%x of %s is not a stack map", ptr, sym.Name)
+				}
+			}
+		}
+		for _, loc := range sym.Reloc {
+			es.Reloc = append(es.Reloc, encodedReloc{
+				Offset:    loc.Offset,
+				Size:      loc.Size,
+				Type:      loc.Type,
+				Add:       loc.Add,
+				Sym:       loc.Sym.Name,
+				SymKind:   loc.Sym.Kind,
+				SymOffset: loc.Sym.Offset,
+				Shared:    linker.symMap[loc.Sym.Name] == loc.Sym,
+			})
+		}
+		e.Syms = append(e.Syms, es)
+	}
+	return &e, nil
+}
+
+// DecodeLinker reads a linker written by Linker.Encode. A linker encoded
+// by another format version, go version or GOARCH is rejected.
+func DecodeLinker(r io.Reader) (*Linker, error) {
+	magic := make([]byte, len(encodeMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != encodeMagic {
+		return nil, fmt.Errorf("goloader: not an encoded linker, magic %q", magic)
+	}
+	dec := gob.NewDecoder(r)
+	var header encodeHeader
+	if err := dec.Decode(&header); err != nil {
+		return nil, err
+	}
+	if err := header.check(); err != nil {
+		return nil, err
+	}
+	var e encodedLinker
+	if err := dec.Decode(&e); err != nil {
+		return nil, err
+	}
+	return e.linker()
+}
+
+// linker returns the linker encoded in e.
+func (e *encodedLinker) linker() (*Linker, error) {
+	linker := &Linker{
+		code:         e.Code,
+		data:         e.Data,
+		symMap:       make(map[string]*Sym),
+		objsymbolMap: e.ObjSymbols,
+		stkmaps:      e.Stkmaps,
+		namemap:      e.Namemap,
+		filetab:      e.Filetab,
+		fileIndex:    e.FileIndex,
+		pclntable:    e.Pclntable,
+		initFuncs:    e.InitFuncs,
+		Arch:         e.Arch,
+		hostPkgs:     e.HostPkgs,
+		modules:      e.Modules,
+		degraded:     e.Degraded,
+		externs:      e.Externs,
+		sharedData:   e.SharedData,
+		toolchain:    e.Toolchain,
+		provided:     e.Provided,
+	}
+	if linker.objsymbolMap == nil {
+		linker.objsymbolMap = make(map[string]*ObjSymbol)
+	}
+	if linker.stkmaps == nil {
+		linker.stkmaps = make(map[string][]byte)
+	}
+	if len(e.Pcfunc)%FindFuncBucketSize != 0 || len(e.Funcs)%int(unsafe.Sizeof(_func{})) != 0 {
+		return nil, errors.New("goloader: bad function table size of encoded linker")
+	}
+	linker.pcfunc = make([]findfuncbucket, len(e.Pcfunc)/FindFuncBucketSize)
+	if len(linker.pcfunc) > 0 {
+		copy(sliceOf(unsafe.Pointer(&linker.pcfunc[0]), len(e.Pcfunc)), e.Pcfunc)
+	}
+	linker._func = make([]_func, len(e.Funcs)/int(unsafe.Sizeof(_func{})))
+	if len(linker._func) > 0 {
+		copy(sliceOf(unsafe.Pointer(&linker._func[0]), len(e.Funcs)), e.Funcs)
+	}
+
+	for _, es := range e.Syms {
+		linker.symMap[es.Name] = &Sym{Name: es.Name, Kind: es.Kind, Offset: es.Offset}
+	}
+	for _, es := range e.Syms {
+		sym := linker.symMap[es.Name]
+		if es.HasFunc {
+			sym.Func = &Func{PCData: es.PCData}
+			for _, name := range es.FuncData {
+				if b := linker.stkmaps[name]; len(b) > 0 {
+					sym.Func.FuncData = append(sym.Func.FuncData, uintptr(unsafe.Pointer(&b[0])))
+				} else {
+					sym.Func.FuncData = append(sym.Func.FuncData, uintptr(0))
+				}
+			}
+		}
+		for _, er := range es.Reloc {
+			loc := Reloc{Offset: er.Offset, Size: er.Size, Type: er.Type, Add: er.Add}
+			if shared, ok := linker.symMap[er.Sym]; ok && er.Shared {
+				loc.Sym = shared
+			} else {
+				loc.Sym = &Sym{Name: er.Sym, Kind: er.SymKind, Offset: er.SymOffset}
+			}
+			sym.Reloc = append(sym.Reloc, loc)
+		}
+	}
+	return linker, nil
+}
+
+func sliceOf(ptr unsafe.Pointer, size int) []byte {
+	s := sliceHeader{Data: uintptr(ptr), Len: size, Cap: size}
+	return *(*[]byte)(unsafe.Pointer(&s))
+}
@@ -0,0 +1,42 @@
+package goloader
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestEncodeKeepsFileIndex(t *testing.T) {
+	linker := &Linker{symMap: make(map[string]*Sym), objsymbolMap: make(map[string]*ObjSymbol)}
+	files := []string{"main.go", FileSymPrefix + "util.go"}
+	for _, file := range files {
+		linker.addFile(file)
+	}
+	var buf bytes.Buffer
+	if err := linker.Encode(&buf); err != nil {
+		t.Fatal(err)
+	}
+	decoded, err := DecodeLinker(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	//R_DWARFFILEREF of a decoded linker resolves through fileIndex
+	for i, file := range []string{"main.go", "util.go"} {
+		if index, ok := decoded.fileIndex[file]; !ok || index != int32(i) {
+			t.Errorf("decoded fileIndex[%s] = %d, %v, want %d", file, index, ok, i)
+		}
+	}
+	if index := decoded.addFile("util.go"); index != 1 {
+		t.Errorf("addFile of a known file after decoding = %d, want 1", index)
+	}
+}
+
+func TestDecodeRejectsOtherFields(t *testing.T) {
+	header := newEncodeHeader()
+	if err := header.check(); err != nil {
+		t.Fatal(err)
+	}
+	header.Fields++
+	if err := header.check(); err == nil {
+		t.Error("header with other fields accepted")
+	}
+}
@@ -0,0 +1,132 @@
+package goloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+	"sync"
+	"unsafe"
+)
+
+// sharedTable is a read-only table mapped once for the modules referring
+// to it, see ReadOptions.SharedData.
+type sharedTable struct {
+	name string
+	size int
+	mem  []byte
+	refs int
+}
+
+var (
+	sharedTables     = make(map[string]*sharedTable)
+	sharedTablesLock sync.Mutex
+)
+
+// SharedTable describes a table mapped once for the modules referring to it.
+type SharedTable struct {
+	Name    string
+	Size    int
+	Modules int // number of loaded modules referring to it
+}
+
+// isShareable reports whether objsym may be shared between modules: a data
+// symbol with data and without relocations, its copy is the same in every
+// module loaded from the same build.
+func isShareable(objsym *ObjSymbol) bool {
+	return objsym.Kind != STEXT && len(objsym.Data) > 0 && len(objsym.Reloc) == 0 &&
+		!strings.HasPrefix(objsym.Name, TypePrefix)
+}
+
+// markSharedData marks the shareable symbols matching patterns, they are not
+// laid out in the data of the module.
+func (linker *Linker) markSharedData(patterns []string) {
+	if len(patterns) == 0 {
+		return
+	}
+	linker.sharedData = make(map[string]bool)
+	for name, objsym := range linker.objsymbolMap {
+		if matchAny(patterns, name) && isShareable(objsym) {
+			linker.sharedData[name] = true
+		}
+	}
+}
+
+// SharedData returns the sorted names of the symbols of linker shared
+// between modules.
+func (linker *Linker) SharedData() []string {
+	names := make([]string, 0, len(linker.sharedData))
+	for name := range linker.sharedData {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sharedTableKey(name string, data []byte) string {
+	sum := sha256.Sum256(data)
+	return name + "@" + hex.EncodeToString(sum[:])
+}
+
+// mapSharedData returns the address of the shared copy of the table name,
+// mapping it read only if no loaded module refers to a table of the same
+// name and content.
+func (cm *CodeModule) mapSharedData(name string, data []byte) (uintptr, error) {
+	if cm.dryRun {
+		//nothing is mapped in a dry run
+		return (*sliceHeader)(unsafe.Pointer(&data)).Data, nil
+	}
+	key := sharedTableKey(name, data)
+	sharedTablesLock.Lock()
+	defer sharedTablesLock.Unlock()
+	table, ok := sharedTables[key]
+	if !ok {
+		size := alignof(len(data), PageSize)
+		mem := cm.mmapNear(size, false, uintptr(cm.codeBase), uintptr(cm.codeBase+cm.maxLength))
+		if mem == nil {
+			var err error
+			if mem, err = MmapData(size); err != nil {
+				return 0, err
+			}
+		}
+		copy(mem, data)
+		if err := protect(mem, 0, len(mem), protRead); err != nil && err != errProtectUnsupported {
+			Munmap(mem)
+			return 0, err
+		}
+		table = &sharedTable{name: name, size: len(data), mem: mem}
+		sharedTables[key] = table
+	}
+	table.refs++
+	cm.sharedTables = append(cm.sharedTables, key)
+	return (*sliceHeader)(unsafe.Pointer(&table.mem)).Data, nil
+}
+
+// releaseSharedData drops the references of the module to its shared tables,
+// a table no module refers to is unmapped.
+func (cm *CodeModule) releaseSharedData() {
+	sharedTablesLock.Lock()
+	defer sharedTablesLock.Unlock()
+	for _, key := range cm.sharedTables {
+		if table, ok := sharedTables[key]; ok {
+			if table.refs--; table.refs == 0 {
+				Munmap(table.mem)
+				delete(sharedTables, key)
+			}
+		}
+	}
+	cm.sharedTables = nil
+}
+
+// SharedTables returns the tables shared between the loaded modules, sorted
+// by name.
+func SharedTables() []SharedTable {
+	sharedTablesLock.Lock()
+	defer sharedTablesLock.Unlock()
+	tables := make([]SharedTable, 0, len(sharedTables))
+	for _, table := range sharedTables {
+		tables = append(tables, SharedTable{Name: table.name, Size: table.size, Modules: table.refs})
+	}
+	sort.Slice(tables, func(i, j int) bool { return tables[i].Name < tables[j].Name })
+	return tables
+}
@@ -0,0 +1,119 @@
+package goloader
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"unsafe"
+)
+
+// SharedImage holds the relocated code of a module in a memfd, so worker
+// processes of a prefork server map the same physical pages for the module.
+// The first Load with an empty image writes the image, later loads of the
+// same linker in processes sharing Fd (e.g. forked or given the fd as an
+// extra file) map it at Base and the data of the module, which is private
+// to each process, at DataBase. The image is mapped copy-on-write, a process
+// whose host places the module elsewhere or resolves a symbol differently,
+// e.g. an exec'd worker whose host is randomized by ASLR, gets a private
+// copy of the pages relocated differently, the others stay shared.
+type SharedImage struct {
+	Fd       int
+	Base     uintptr // address of the image in the process which wrote it
+	DataBase uintptr // address of the data of the module in that process
+	Size     int     // 0 until the image is written
+	Hash     string  // Linker.Hash of the image
+	// HostBase is the text address of the host which wrote the image and
+	// HostSyms the addresses there of the host symbols the image refers to.
+	HostBase uintptr
+	HostSyms map[string]uintptr
+	// Moved and Refixed are set by a load mapping the written image: the
+	// host symbols at another address than in the writer and the number of
+	// pages of the image rewritten for the process.
+	Moved   []string
+	Refixed int
+}
+
+// NewSharedImage creates an empty image in a new memfd named name.
+func NewSharedImage(name string) (*SharedImage, error) {
+	fd, err := memfdCreate(name)
+	if err != nil {
+		return nil, err
+	}
+	return &SharedImage{Fd: fd}, nil
+}
+
+// mapShared maps the written image at its base, or where the kernel can.
+func (img *SharedImage) mapShared(size int, hash string) ([]byte, error) {
+	if img.Size != size || img.Hash != hash {
+		return nil, fmt.Errorf("goloader: shared image is %d bytes of %s, module is %d bytes of %s", img.Size, img.Hash, size, hash)
+	}
+	return mmapFile(img.Fd, size, img.Base, false)
+}
+
+// mapData maps the data of the module at the address it has in the process
+// which wrote the image, or where the kernel can, the code refers to it.
+func (img *SharedImage) mapData(size int) ([]byte, error) {
+	if img.DataBase == 0 {
+		return MmapData(size)
+	}
+	return mmapAnon(size, img.DataBase)
+}
+
+// hostSymbols returns the addresses of the host symbols the objects refer to.
+func (linker *Linker) hostSymbols(symPtr map[string]uintptr) map[string]uintptr {
+	hostSyms := make(map[string]uintptr)
+	for name, sym := range linker.symMap {
+		if sym.Offset == InvalidOffset {
+			if addr, ok := symPtr[name]; ok {
+				hostSyms[name] = addr
+			}
+		}
+	}
+	return hostSyms
+}
+
+// moved returns the sorted names of the host symbols of hostSyms at another
+// address than in the host which wrote the image.
+func (img *SharedImage) moved(hostSyms map[string]uintptr) []string {
+	moved := make([]string, 0)
+	for name, addr := range hostSyms {
+		if old, ok := img.HostSyms[name]; !ok || old != addr {
+			moved = append(moved, name)
+		}
+	}
+	sort.Strings(moved)
+	return moved
+}
+
+// publish replaces the mapping of the module by the shared image holding
+// image, the relocated module, hostSyms are the host symbols it refers to.
+// The first module writes the image, later modules rewrite the pages image
+// relocates differently, if the host moved or the module is elsewhere.
+func (img *SharedImage) publish(mapping, image []byte, dataBase uintptr, hash string, hostSyms map[string]uintptr) ([]byte, error) {
+	if img.Size == 0 {
+		if err := writeFile(img.Fd, image); err != nil {
+			return nil, err
+		}
+		base := (*sliceHeader)(unsafe.Pointer(&mapping)).Data
+		shared, err := mmapFile(img.Fd, len(image), base, true)
+		if err != nil {
+			return nil, err
+		}
+		img.Base, img.DataBase, img.Size, img.Hash = base, dataBase, len(image), hash
+		img.HostBase, img.HostSyms = firstmoduledata.text, hostSyms
+		return shared, nil
+	}
+	img.Moved, img.Refixed = img.moved(hostSyms), 0
+	//only the pages relocated differently are copied, the others stay shared
+	for off := 0; off < len(image); off += PageSize {
+		end := off + PageSize
+		if end > len(image) {
+			end = len(image)
+		}
+		if !bytes.Equal(mapping[off:end], image[off:end]) {
+			copy(mapping[off:end], image[off:end])
+			img.Refixed++
+		}
+	}
+	return mapping, nil
+}
@@ -0,0 +1,67 @@
+// +build linux,amd64 linux,arm64 linux,386 linux,arm
+
+package goloader
+
+import (
+	"os"
+	"syscall"
+	"unsafe"
+)
+
+const (
+	mfdCloexec = 0x1
+	mapFixed   = 0x10
+)
+
+func memfdCreate(name string) (int, error) {
+	p, err := syscall.BytePtrFromString(name)
+	if err != nil {
+		return -1, err
+	}
+	fd, _, errno := syscall.Syscall(sysMemfdCreate, uintptr(unsafe.Pointer(p)), mfdCloexec, 0)
+	if errno != 0 {
+		return -1, os.NewSyscallError("memfd_create", errno)
+	}
+	return int(fd), nil
+}
+
+func writeFile(fd int, b []byte) error {
+	if err := syscall.Ftruncate(fd, int64(len(b))); err != nil {
+		return os.NewSyscallError("syscall.Ftruncate", err)
+	}
+	for written := 0; written < len(b); {
+		n, err := syscall.Pwrite(fd, b[written:], int64(written))
+		if err != nil {
+			return os.NewSyscallError("syscall.Pwrite", err)
+		}
+		written += n
+	}
+	return nil
+}
+
+// mmapFile maps fd copy-on-write at addr, which is only a hint unless fixed.
+func mmapFile(fd int, size int, addr uintptr, fixed bool) ([]byte, error) {
+	flags := syscall.MAP_PRIVATE
+	if fixed {
+		flags |= mapFixed
+	}
+	ptr, _, errno := syscall.Syscall6(sysMmap, addr, uintptr(size),
+		syscall.PROT_READ|syscall.PROT_WRITE|syscall.PROT_EXEC, uintptr(flags), uintptr(fd), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("mmap", errno)
+	}
+	b := sliceHeader{Data: ptr, Len: size, Cap: size}
+	return *(*[]byte)(unsafe.Pointer(&b)), nil
+}
+
+// mmapAnon maps size bytes of private read-write memory at addr, which is
+// only a hint.
+func mmapAnon(size int, addr uintptr) ([]byte, error) {
+	ptr, _, errno := syscall.Syscall6(sysMmap, addr, uintptr(size),
+		syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON, ^uintptr(0), 0)
+	if errno != 0 {
+		return nil, os.NewSyscallError("mmap", errno)
+	}
+	b := sliceHeader{Data: ptr, Len: size, Cap: size}
+	return *(*[]byte)(unsafe.Pointer(&b)), nil
+}
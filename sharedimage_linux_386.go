@@ -0,0 +1,12 @@
+// +build linux,386
+
+package goloader
+
+import (
+	"syscall"
+)
+
+const (
+	sysMemfdCreate = 356
+	sysMmap        = syscall.SYS_MMAP2
+)
@@ -0,0 +1,12 @@
+// +build linux,amd64
+
+package goloader
+
+import (
+	"syscall"
+)
+
+const (
+	sysMemfdCreate = 319
+	sysMmap        = syscall.SYS_MMAP
+)
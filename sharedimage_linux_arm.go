@@ -0,0 +1,12 @@
+// +build linux,arm
+
+package goloader
+
+import (
+	"syscall"
+)
+
+const (
+	sysMemfdCreate = 385
+	sysMmap        = syscall.SYS_MMAP2
+)
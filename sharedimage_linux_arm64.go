@@ -0,0 +1,12 @@
+// +build linux,arm64
+
+package goloader
+
+import (
+	"syscall"
+)
+
+const (
+	sysMemfdCreate = 279
+	sysMmap        = syscall.SYS_MMAP
+)
@@ -0,0 +1,25 @@
+// +build !linux !amd64,!arm64,!386,!arm
+
+package goloader
+
+import (
+	"errors"
+)
+
+var errSharedImageUnsupported = errors.New("goloader: shared images are only supported on linux")
+
+func memfdCreate(name string) (int, error) {
+	return -1, errSharedImageUnsupported
+}
+
+func writeFile(fd int, b []byte) error {
+	return errSharedImageUnsupported
+}
+
+func mmapFile(fd int, size int, addr uintptr, fixed bool) ([]byte, error) {
+	return nil, errSharedImageUnsupported
+}
+
+func mmapAnon(size int, addr uintptr) ([]byte, error) {
+	return nil, errSharedImageUnsupported
+}
@@ -0,0 +1,118 @@
+package goloader
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// kindName returns the name of a symbol kind of the objects.
+func kindName(kind int) string {
+	switch kind {
+	case STEXT:
+		return "STEXT"
+	case SRODATA:
+		return "SRODATA"
+	case SNOPTRDATA:
+		return "SNOPTRDATA"
+	case SDATA:
+		return "SDATA"
+	case SBSS:
+		return "SBSS"
+	case SNOPTRBSS:
+		return "SNOPTRBSS"
+	case STLSBSS:
+		return "STLSBSS"
+	}
+	if _, ok := dwarfSections[kind]; ok {
+		return "DWARF"
+	}
+	return fmt.Sprintf("kind(%d)", kind)
+}
+
+// SkippedKinds counts the symbols of the objects a linker does not load,
+// by package and kind. Symbols of a skipped kind are not in the module,
+// e.g. CodeModule.Var does not find a variable nothing refers to.
+// Thread-local STLSBSS symbols are counted even if referenced, goloader
+// can not place them.
+type SkippedKinds map[string]map[string]int
+
+func (s SkippedKinds) add(pkg, kind string) {
+	if s[pkg] == nil {
+		s[pkg] = make(map[string]int)
+	}
+	s[pkg][kind]++
+}
+
+// Fatal returns the packages and kinds of s which ReadOptions.Strict
+// rejects: all but DWARF, which is only loaded with LoadOptions.KeepDWARF.
+func (s SkippedKinds) Fatal() SkippedKinds {
+	fatal := make(SkippedKinds)
+	for pkg, kinds := range s {
+		for kind, count := range kinds {
+			if kind != "DWARF" {
+				if fatal[pkg] == nil {
+					fatal[pkg] = make(map[string]int)
+				}
+				fatal[pkg][kind] = count
+			}
+		}
+	}
+	return fatal
+}
+
+// String returns one line per package, e.g. "main: SBSS 1, SDATA 2".
+func (s SkippedKinds) String() string {
+	pkgs := make([]string, 0, len(s))
+	for pkg := range s {
+		pkgs = append(pkgs, pkg)
+	}
+	sort.Strings(pkgs)
+	lines := make([]string, 0, len(pkgs))
+	for _, pkg := range pkgs {
+		kinds := make([]string, 0, len(s[pkg]))
+		for kind, count := range s[pkg] {
+			kinds = append(kinds, fmt.Sprintf("%s %d", kind, count))
+		}
+		sort.Strings(kinds)
+		lines = append(lines, fmt.Sprintf("%s: %s", pkg, strings.Join(kinds, ", ")))
+	}
+	return strings.Join(lines, "\n")
+}
+
+// skippedKinds inventories the symbols of the objects addSymbols left out.
+func (linker *Linker) skippedKinds() SkippedKinds {
+	skipped := make(SkippedKinds)
+	for name, objsym := range linker.objsymbolMap {
+		if name == EmptyString || linker.isHostSymbol(name) {
+			continue
+		}
+		//type descriptors, strings and other compiler generated or DupOK
+		//symbols are only needed if referenced
+		pkg := symbolPkg(name)
+		if pkg == EmptyString || objsym.DupOK {
+			continue
+		}
+		if sym, loaded := linker.symMap[name]; loaded && sym.Kind != STLSBSS {
+			continue
+		}
+		skipped.add(pkg, kindName(int(objsym.Kind)))
+	}
+	return skipped
+}
+
+// checkSkipped reports the skipped symbols to ReadOptions.Skipped and fails
+// in ReadOptions.Strict if a symbol other than DWARF is skipped.
+func (linker *Linker) checkSkipped(options ReadOptions) error {
+	if !options.Strict && options.Skipped == nil {
+		return nil
+	}
+	skipped := linker.skippedKinds()
+	if options.Skipped != nil && len(skipped) > 0 {
+		options.Skipped(skipped)
+	}
+	if fatal := skipped.Fatal(); options.Strict && len(fatal) > 0 {
+		return fmt.Errorf("goloader: symbols of the objects not loaded:\n%s", fatal)
+	}
+	return nil
+}
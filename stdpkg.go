@@ -0,0 +1,110 @@
+package goloader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// packages whose state belongs to the runtime of the host: the cpu feature
+// flags, the netpoller, the vdso and the runtime itself. A module linked
+// against an archive of net or crypto carries a copy of them, which would
+// run with uninitialized state, so their symbols are always resolved by the
+// host.
+var hostPkgs = []string{
+	"runtime",
+	"runtime/internal/...",
+	"internal/cpu",
+	"internal/bytealg",
+	"internal/poll",
+	"internal/syscall/unix",
+	"internal/syscall/windows",
+	"syscall",
+	"vendor/golang.org/x/sys/cpu",
+	"golang.org/x/sys/cpu",
+}
+
+// symbolPkg returns the package path of a symbol, or "" for the symbols
+// generated by the compiler and the linker.
+func symbolPkg(name string) string {
+	if strings.HasPrefix(name, TypePrefix) || strings.HasPrefix(name, "go.") || strings.HasPrefix(name, FileSymPrefix) {
+		return EmptyString
+	}
+	slash := strings.LastIndex(name, "/")
+	dot := strings.Index(name[slash+1:], ".")
+	if dot < 0 {
+		return EmptyString
+	}
+	return name[:slash+1+dot]
+}
+
+// SymbolPackage returns the package path of the symbol name, or "" for the
+// symbols generated by the compiler and the linker.
+func SymbolPackage(name string) string {
+	return symbolPkg(name)
+}
+
+// matchPkg reports whether pkg is pattern, or is below it if pattern
+// ends with "/...".
+func matchPkg(pkg, pattern string) bool {
+	if strings.HasSuffix(pattern, "/...") {
+		return pkg == strings.TrimSuffix(pattern, "/...") || strings.HasPrefix(pkg, strings.TrimSuffix(pattern, "..."))
+	}
+	return pkg == pattern
+}
+
+// isHostSymbol reports whether the symbol belongs to a package bound to
+// the host, by default or by ReadOptions.HostPackages. The DUPOK symbols
+// the compiler generates in every object, e.g. the runtime.gcbits masks of
+// the types, are not, the host may not have them or only as read-only
+// data, which RegSymbol does not register.
+func (linker *Linker) isHostSymbol(name string) bool {
+	pkg := symbolPkg(name)
+	if pkg == EmptyString || strings.HasPrefix(name, GCBitsPrefix) {
+		return false
+	}
+	if objSym, ok := linker.objsymbolMap[name]; ok && objSym.DupOK {
+		return false
+	}
+	for _, hostPkg := range hostPkgs {
+		if matchPkg(pkg, hostPkg) {
+			return true
+		}
+	}
+	for _, hostPkg := range linker.hostPkgs {
+		if matchPkg(pkg, hostPkg) {
+			return true
+		}
+	}
+	return false
+}
+
+// stripHostPackages drops the init tasks of the packages bound to the
+// host, the host already ran them.
+func (linker *Linker) stripHostPackages() {
+	initFuncs := linker.initFuncs[:0]
+	for _, name := range linker.initFuncs {
+		if !linker.isHostSymbol(name) {
+			initFuncs = append(initFuncs, name)
+		}
+	}
+	linker.initFuncs = initFuncs
+}
+
+// unresolvedError explains a missing external symbol. The assembly
+// functions of net, crypto and math/big are not in the object file of go
+// tool compile, they are in the archive of go build or the host.
+func (linker *Linker) unresolvedError(name string) error {
+	pkg := symbolPkg(name)
+	if linker.isHostSymbol(name) {
+		return fmt.Errorf("unresolve external:%s, package %s is shared with the host, which does not link it", name, pkg)
+	}
+	if strings.HasPrefix(name, CgoSymPrefix) || strings.HasPrefix(name, "x"+CgoSymPrefix) {
+		return fmt.Errorf("unresolve external:%s, a C symbol of a cgo package, resolve it with LoadOptions.ResolveSymbol", name)
+	}
+	for _, initFunc := range linker.initFuncs {
+		if pkg != EmptyString && initFunc == getInitFuncName(pkg) {
+			return fmt.Errorf("unresolve external:%s, it may be an assembly function of package %s, load the archive of %s built by go build or import it in the host", name, pkg, pkg)
+		}
+	}
+	return fmt.Errorf("unresolve external:%s", name)
+}
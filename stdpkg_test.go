@@ -0,0 +1,78 @@
+package goloader
+
+import (
+	"testing"
+)
+
+func TestSymbolPkg(t *testing.T) {
+	tests := []struct {
+		name string
+		pkg  string
+	}{
+		{"main.main", "main"},
+		{"net/http.(*Client).Do", "net/http"},
+		{"github.com/a/b.c.T.M", "github.com/a/b"},
+		{"vendor/golang.org/x/sys/cpu.X86", "vendor/golang.org/x/sys/cpu"},
+		{"runtime.gcbits.01", "runtime"},
+		{"type.*main.T", ""},
+		{"go.itab.*main.T,main.I", ""},
+		{"gofile../a/b.go", ""},
+		{"nodot", ""},
+	}
+	for _, test := range tests {
+		if pkg := symbolPkg(test.name); pkg != test.pkg {
+			t.Errorf("symbolPkg(%q) = %q, want %q", test.name, pkg, test.pkg)
+		}
+	}
+}
+
+func TestMatchPkg(t *testing.T) {
+	tests := []struct {
+		pkg     string
+		pattern string
+		match   bool
+	}{
+		{"runtime", "runtime", true},
+		{"runtime/internal/atomic", "runtime", false},
+		{"runtime/internal/atomic", "runtime/internal/...", true},
+		{"runtime/internal", "runtime/internal/...", true},
+		{"runtime/internalx", "runtime/internal/...", false},
+		{"syscall", "internal/syscall/unix", false},
+	}
+	for _, test := range tests {
+		if match := matchPkg(test.pkg, test.pattern); match != test.match {
+			t.Errorf("matchPkg(%q, %q) = %v, want %v", test.pkg, test.pattern, match, test.match)
+		}
+	}
+}
+
+func TestIsHostSymbol(t *testing.T) {
+	linker := &Linker{
+		objsymbolMap: map[string]*ObjSymbol{
+			"runtime.gcbits.01":        {Name: "runtime.gcbits.01", Kind: SRODATA, DupOK: true},
+			"runtime.memequal64·f":     {Name: "runtime.memequal64·f", Kind: SRODATA, DupOK: true},
+			"internal/cpu.Initialize":  {Name: "internal/cpu.Initialize", Kind: STEXT},
+			"example.com/shared.State": {Name: "example.com/shared.State", Kind: SNOPTRBSS},
+		},
+		hostPkgs: []string{"example.com/shared"},
+	}
+	tests := []struct {
+		name string
+		host bool
+	}{
+		{"runtime.newobject", true},
+		{"runtime.gcbits.01", false},
+		{"runtime.gcbits.0f", false},
+		{"runtime.memequal64·f", false},
+		{"internal/cpu.Initialize", true},
+		{"internal/poll.(*FD).Read", true},
+		{"example.com/shared.State", true},
+		{"main.main", false},
+		{"type.*main.T", false},
+	}
+	for _, test := range tests {
+		if host := linker.isHostSymbol(test.name); host != test.host {
+			t.Errorf("isHostSymbol(%q) = %v, want %v", test.name, host, test.host)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package goloader
+
+import (
+	"fmt"
+	"reflect"
+	"sync"
+)
+
+// entry is an entry point of a module handed to the host, the wrapper of
+// Func or of a Callback. The wrapper calls the function the entry points
+// to while holding a reference to its module, Swap points it to another
+// module.
+type entry struct {
+	lock    sync.RWMutex
+	cm      *CodeModule
+	name    string
+	fn      reflect.Value
+	wrapper reflect.Value
+}
+
+// newEntry returns an entry point to fn, the function name of the module.
+func (cm *CodeModule) newEntry(name string, fn reflect.Value) *entry {
+	e := &entry{cm: cm, name: name, fn: fn}
+	fnType := fn.Type()
+	e.wrapper = reflect.MakeFunc(fnType, func(in []reflect.Value) []reflect.Value {
+		cm, fn := e.acquire()
+		if cm == nil {
+			panic(fmt.Errorf("goloader: %s called after its module is unloaded", e.name))
+		}
+		defer cm.calls.release()
+		if fnType.IsVariadic() {
+			return fn.CallSlice(in)
+		}
+		return fn.Call(in)
+	})
+	cm.entryLock.Lock()
+	cm.entries = append(cm.entries, e)
+	cm.entryLock.Unlock()
+	return e
+}
+
+func (e *entry) target() (*CodeModule, reflect.Value) {
+	e.lock.RLock()
+	defer e.lock.RUnlock()
+	return e.cm, e.fn
+}
+
+// acquire takes a reference to the module the entry points to, following
+// the entry if it is swapped meanwhile, or returns nil if the module is
+// unloaded.
+func (e *entry) acquire() (*CodeModule, reflect.Value) {
+	for {
+		cm, fn := e.target()
+		if cm.calls.acquire() {
+			return cm, fn
+		}
+		if moved, _ := e.target(); moved == cm {
+			return nil, fn
+		}
+	}
+}
+
+// checkFuncType checks that name, a function of cm, has the signature
+// fnType, from the type information of cm, see FuncType. A name without
+// type information fails because its signature can not be verified.
+func (cm *CodeModule) checkFuncType(name string, fnType reflect.Type) error {
+	t, err := cm.FuncType(name)
+	if err != nil {
+		return fmt.Errorf("no type information for %s to check it is %s", name, fnType)
+	}
+	//the types of the modules and of the host are distinct, compare their
+	//spelling, a variadic parameter is passed as the slice the DWARF names
+	if t.NumIn() != fnType.NumIn() || t.NumOut() != fnType.NumOut() {
+		return fmt.Errorf("%s is %s, want %s", name, t, fnType)
+	}
+	for i := 0; i < t.NumIn(); i++ {
+		if t.In(i).String() != fnType.In(i).String() {
+			return fmt.Errorf("%s is %s, want %s", name, t, fnType)
+		}
+	}
+	for i := 0; i < t.NumOut(); i++ {
+		if t.Out(i).String() != fnType.Out(i).String() {
+			return fmt.Errorf("%s is %s, want %s", name, t, fnType)
+		}
+	}
+	return nil
+}
+
+// Swap replaces old by new: the entry points of old handed to the host,
+// the wrappers of Func and the callbacks of Bind, are pointed to the
+// functions of the same name in new, or of the name symbolMap maps their
+// name to. Then the calls running in old are drained and old is unloaded.
+// Swap fails without changing anything if new lacks a function, has no
+// type information to check its signature, see checkFuncType, or has it
+// with another signature. Calls made after Swap returns run in new, a call
+// made during Swap runs in either; raw func values of old are not
+// redirected and must not be used after Swap.
+func Swap(old, new *CodeModule, symbolMap map[string]string) error {
+	old.entryLock.Lock()
+	entries := old.entries
+	names := make([]string, len(entries))
+	targets := make([]reflect.Value, len(entries))
+	for i, e := range entries {
+		_, fn := e.target()
+		names[i] = e.name
+		if name, ok := symbolMap[e.name]; ok {
+			names[i] = name
+		}
+		if err := new.checkFuncType(names[i], fn.Type()); err != nil {
+			old.entryLock.Unlock()
+			return fmt.Errorf("goloader: swap %s: %v", e.name, err)
+		}
+		var err error
+		if targets[i], err = new.funcValue(names[i], fn.Type()); err != nil {
+			old.entryLock.Unlock()
+			return fmt.Errorf("goloader: swap %s: %v", e.name, err)
+		}
+	}
+	for _, e := range entries {
+		e.lock.Lock()
+	}
+	for i, e := range entries {
+		e.cm, e.name, e.fn = new, names[i], targets[i]
+	}
+	for _, e := range entries {
+		e.lock.Unlock()
+	}
+	old.entries = nil
+	old.entryLock.Unlock()
+	new.entryLock.Lock()
+	new.entries = append(new.entries, entries...)
+	new.entryLock.Unlock()
+
+	//the callbacks stay registered, they now call into new
+	old.callbackLock.Lock()
+	callbacks := old.callbacks
+	old.callbacks = nil
+	old.callbackLock.Unlock()
+	new.callbackLock.Lock()
+	if new.callbacks == nil && len(callbacks) > 0 {
+		new.callbacks = make(map[*Callback]bool)
+	}
+	for cb := range callbacks {
+		new.callbacks[cb] = true
+	}
+	new.callbackLock.Unlock()
+
+	old.Unload()
+	return nil
+}
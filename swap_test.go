@@ -0,0 +1,108 @@
+package goloader
+
+import (
+	"sync"
+	"testing"
+)
+
+var (
+	swapOld = func(x int) int { return x + 1 }
+	swapNew = func(x int) int { return x + 2 }
+	swapBad = func(x string) int { return len(x) }
+)
+
+func TestSwapUnderConcurrentCalls(t *testing.T) {
+	old, new := newTestModule(t), newTestModule(t)
+	addTestFunc(old, "main.f", &swapOld)
+	addTestFunc(new, "main.f", &swapNew)
+	wrapper, err := old.Func("main.f", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := wrapper.(func(int) int)
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				if r := f(1); r != 2 && r != 3 {
+					t.Errorf("call during swap returned %d", r)
+					return
+				}
+			}
+		}()
+	}
+	if err = Swap(old, new, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r := f(1); r != 3 {
+		t.Errorf("call after swap returned %d, want 3", r)
+	}
+	close(stop)
+	wg.Wait()
+	if old.Acquire() {
+		t.Error("old module is not unloaded")
+	}
+	if !new.Acquire() {
+		t.Error("new module is unloaded")
+	}
+	new.Release()
+}
+
+func TestSwapChecksSignatures(t *testing.T) {
+	old := newTestModule(t)
+	addTestFunc(old, "main.f", &swapOld)
+	wrapper, err := old.Func("main.f", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f := wrapper.(func(int) int)
+
+	//neither a variable nor a function with DWARF
+	untyped := newTestModule(t)
+	addTestFunc(untyped, "main.f", &swapNew)
+	delete(untyped.types, "main.f")
+	mismatched := newTestModule(t)
+	addTestFunc(mismatched, "main.f", &swapBad)
+	missing := newTestModule(t)
+
+	for name, new := range map[string]*CodeModule{"untyped": untyped, "mismatched": mismatched, "missing": missing} {
+		if err := Swap(old, new, nil); err == nil {
+			t.Errorf("swap with %s function succeeded", name)
+		}
+		if r := f(1); r != 2 {
+			t.Errorf("call after failed swap with %s function returned %d, want 2", name, r)
+		}
+	}
+	if !old.Acquire() {
+		t.Fatal("old module is unloaded by a failed swap")
+	}
+	old.Release()
+}
+
+func TestSwapPlainFunction(t *testing.T) {
+	old, new := newTestModule(t), newTestModule(t)
+	addTestFunc(old, "main.f", &swapOld)
+	addTestFunc(new, "main.f", &swapNew)
+	//a plain function of new, its signature is recovered from the DWARF
+	new.funcSigs = map[string]*funcSig{"main.f": {funcType: new.types["main.f"]}}
+	delete(new.types, "main.f")
+	wrapper, err := old.Func("main.f", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Swap(old, new, nil); err != nil {
+		t.Fatal(err)
+	}
+	if r := wrapper.(func(int) int)(1); r != 3 {
+		t.Errorf("call after swap returned %d, want 3", r)
+	}
+}
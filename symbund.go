@@ -0,0 +1,138 @@
+package goloader
+
+import (
+	"compress/gzip"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+)
+
+// layout of a symbol bundle:
+//
+//	magic | gzip(gob(SymbolBundle))
+const symbundMagic = "symbund1"
+
+// SymbolBundle holds what is needed to symbolize the frames of a loaded
+// module, the names, address ranges and line tables of its functions,
+// without its code, e.g. for a symbolication service of crash reports and
+// profiles.
+type SymbolBundle struct {
+	Hash      string // see Linker.Hash
+	GoVersion string
+	GOARCH    string
+	Base      uintptr // address of the code of the module when it was written
+	Files     []string
+	Funcs     []SymbolFunc // sorted by Start
+}
+
+// SymbolFunc is a function of a SymbolBundle, Start and End are offsets
+// from the base of the module.
+type SymbolFunc struct {
+	Name  string
+	Start uintptr
+	End   uintptr
+	Lines []LineRow // sorted by PC
+}
+
+// LineRow is the source position of the instructions of a function from PC,
+// an offset from the start of the function, to the PC of the next row.
+type LineRow struct {
+	PC   uintptr
+	File int32 // index in SymbolBundle.Files
+	Line int32
+}
+
+// SymbolBundle returns the symbol bundle of the module.
+func (cm *CodeModule) SymbolBundle() *SymbolBundle {
+	bundle := &SymbolBundle{
+		Hash:      cm.hash,
+		GoVersion: runtime.Version(),
+		GOARCH:    runtime.GOARCH,
+		Base:      uintptr(cm.codeBase),
+	}
+	files := make(map[string]int32)
+	//the first entry of ftab is the start, the last one the end of the module
+	for i := 1; i+1 < len(cm.module.ftab); i++ {
+		entry, end := cm.module.ftab[i].entry, cm.module.ftab[i+1].entry
+		f := runtime.FuncForPC(entry)
+		if f == nil {
+			continue
+		}
+		fn := SymbolFunc{Name: f.Name(), Start: entry - bundle.Base, End: end - bundle.Base}
+		info := findfunc(entry)
+		if info._func != nil {
+			p := cm.module.lineTable(info._func)
+			pc, line := entry, int32(-1)
+			for ok := p != nil; ok; {
+				start := pc
+				if p, ok = step(p, &pc, &line, pc == entry); !ok {
+					break
+				}
+				file, _ := f.FileLine(start)
+				index, seen := files[file]
+				if !seen {
+					index = int32(len(bundle.Files))
+					files[file] = index
+					bundle.Files = append(bundle.Files, file)
+				}
+				fn.Lines = append(fn.Lines, LineRow{PC: start - entry, File: index, Line: line})
+			}
+		}
+		bundle.Funcs = append(bundle.Funcs, fn)
+	}
+	sort.Slice(bundle.Funcs, func(i, j int) bool { return bundle.Funcs[i].Start < bundle.Funcs[j].Start })
+	return bundle
+}
+
+// Encode writes the bundle in its compressed format.
+func (bundle *SymbolBundle) Encode(w io.Writer) error {
+	if _, err := io.WriteString(w, symbundMagic); err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(w)
+	if err := gob.NewEncoder(zw).Encode(bundle); err != nil {
+		return err
+	}
+	return zw.Close()
+}
+
+// DecodeSymbolBundle reads a bundle written by SymbolBundle.Encode.
+func DecodeSymbolBundle(r io.Reader) (*SymbolBundle, error) {
+	magic := make([]byte, len(symbundMagic))
+	if _, err := io.ReadFull(r, magic); err != nil {
+		return nil, err
+	}
+	if string(magic) != symbundMagic {
+		return nil, errors.New("goloader: not a symbol bundle")
+	}
+	zr, err := gzip.NewReader(r)
+	if err != nil {
+		return nil, err
+	}
+	bundle := &SymbolBundle{}
+	if err = gob.NewDecoder(zr).Decode(bundle); err != nil {
+		return nil, fmt.Errorf("goloader: decoding symbol bundle: %v", err)
+	}
+	return bundle, nil
+}
+
+// Lookup returns the function, the file and the line of offset, an offset
+// from the base of the module, e.g. a pc of a frame minus the base of the
+// module in the process which crashed. ok is false if no function holds it,
+// file is empty and line 0 if its function has no line table.
+func (bundle *SymbolBundle) Lookup(offset uintptr) (name, file string, line int, ok bool) {
+	i := sort.Search(len(bundle.Funcs), func(i int) bool { return bundle.Funcs[i].End > offset })
+	if i == len(bundle.Funcs) || offset < bundle.Funcs[i].Start {
+		return EmptyString, EmptyString, 0, false
+	}
+	fn := &bundle.Funcs[i]
+	pc := offset - fn.Start
+	j := sort.Search(len(fn.Lines), func(j int) bool { return fn.Lines[j].PC > pc }) - 1
+	if j < 0 {
+		return fn.Name, EmptyString, 0, true
+	}
+	return fn.Name, bundle.Files[fn.Lines[j].File], int(fn.Lines[j].Line), true
+}
@@ -0,0 +1,73 @@
+package goloader
+
+import (
+	"sort"
+	"strings"
+)
+
+// symbolIndex is a compact read-only index of the functions of a module,
+// names sorted for binary search with their addresses in a parallel slice.
+type symbolIndex struct {
+	names []string
+	addrs []uintptr
+}
+
+func newSymbolIndex(syms map[string]uintptr) *symbolIndex {
+	idx := &symbolIndex{names: make([]string, 0, len(syms))}
+	for name := range syms {
+		idx.names = append(idx.names, name)
+	}
+	sort.Strings(idx.names)
+	idx.addrs = make([]uintptr, len(idx.names))
+	for i, name := range idx.names {
+		idx.addrs[i] = syms[name]
+	}
+	return idx
+}
+
+func (idx *symbolIndex) lookup(name string) (uintptr, bool) {
+	i := sort.SearchStrings(idx.names, name)
+	if i < len(idx.names) && idx.names[i] == name {
+		return idx.addrs[i], true
+	}
+	return 0, false
+}
+
+func (idx *symbolIndex) prefix(prefix string) []string {
+	lo := sort.SearchStrings(idx.names, prefix)
+	hi := lo
+	for hi < len(idx.names) && strings.HasPrefix(idx.names[hi], prefix) {
+		hi++
+	}
+	return idx.names[lo:hi:hi]
+}
+
+func (cm *CodeModule) index() *symbolIndex {
+	cm.indexOnce.Do(func() {
+		if cm.symIndex == nil {
+			cm.symIndex = newSymbolIndex(cm.Syms)
+		}
+	})
+	return cm.symIndex
+}
+
+// Lookup returns the address of the function name of the module.
+func (cm *CodeModule) Lookup(name string) (uintptr, bool) {
+	if cm.Syms != nil {
+		ptr, ok := cm.Syms[name]
+		return ptr, ok
+	}
+	return cm.index().lookup(name)
+}
+
+// Symbols returns the sorted names of the functions of the module,
+// the slice must not be modified.
+func (cm *CodeModule) Symbols() []string {
+	return cm.index().names
+}
+
+// LookupPrefix returns the sorted names of the functions of the module
+// starting with prefix, e.g. a package path and a dot.
+func (cm *CodeModule) LookupPrefix(prefix string) []string {
+	return cm.index().prefix(prefix)
+}
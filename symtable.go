@@ -0,0 +1,130 @@
+package goloader
+
+import (
+	"sync"
+)
+
+// SymbolTable holds the symbols of the host modules are linked against,
+// it is safe for concurrent use, e.g. to register types lazily while
+// modules are loaded. A Snapshot is never written: the first registration
+// after it copies the symbols.
+type SymbolTable struct {
+	lock   sync.Mutex
+	syms   map[string]uintptr
+	shared bool // syms is referenced by a snapshot
+}
+
+// NewSymbolTable returns an empty table.
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{syms: make(map[string]uintptr)}
+}
+
+// HostSymbolTable returns a table holding the symbols of the running
+// executable, see RegSymbol.
+func HostSymbolTable() (*SymbolTable, error) {
+	table := NewSymbolTable()
+	if err := table.RegSymbol(); err != nil {
+		return nil, err
+	}
+	return table, nil
+}
+
+// update runs fn with the symbols of table, copied first if a snapshot
+// refers to them.
+func (table *SymbolTable) update(fn func(symPtr map[string]uintptr)) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	if table.shared {
+		syms := make(map[string]uintptr, len(table.syms))
+		for name, addr := range table.syms {
+			syms[name] = addr
+		}
+		table.syms, table.shared = syms, false
+	}
+	fn(table.syms)
+}
+
+// RegSymbol adds the symbols of the running executable. They are read
+// without holding the table, loads are not blocked meanwhile.
+func (table *SymbolTable) RegSymbol() error {
+	symPtr := make(map[string]uintptr)
+	if err := RegSymbol(symPtr); err != nil {
+		return err
+	}
+	table.Merge(symPtr)
+	return nil
+}
+
+// RegSymbolsFromSelf adds the symbols of the running program read from its
+// own tables, see RegSymbolsFromSelf.
+func (table *SymbolTable) RegSymbolsFromSelf() error {
+	symPtr := make(map[string]uintptr)
+	if err := RegSymbolsFromSelf(symPtr); err != nil {
+		return err
+	}
+	table.Merge(symPtr)
+	return nil
+}
+
+// RegSymbolWithSo adds the symbols of the executable or shared object at path.
+func (table *SymbolTable) RegSymbolWithSo(path string) error {
+	symPtr := make(map[string]uintptr)
+	if err := RegSymbolWithSo(symPtr, path); err != nil {
+		return err
+	}
+	table.Merge(symPtr)
+	return nil
+}
+
+// RegTypes adds the types and functions of interfaces, see RegTypes.
+func (table *SymbolTable) RegTypes(interfaces ...interface{}) {
+	table.update(func(symPtr map[string]uintptr) {
+		RegTypes(symPtr, interfaces...)
+	})
+}
+
+// Add adds the symbol name at addr, replacing a symbol of the same name.
+func (table *SymbolTable) Add(name string, addr uintptr) {
+	table.update(func(symPtr map[string]uintptr) {
+		symPtr[name] = addr
+	})
+}
+
+// Merge adds the symbols of symPtr, replacing symbols of the same names.
+func (table *SymbolTable) Merge(symPtr map[string]uintptr) {
+	table.update(func(syms map[string]uintptr) {
+		for name, addr := range symPtr {
+			syms[name] = addr
+		}
+	})
+}
+
+// Lookup returns the address of the symbol name.
+func (table *SymbolTable) Lookup(name string) (uintptr, bool) {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	addr, ok := table.syms[name]
+	return addr, ok
+}
+
+// Len returns the number of symbols in the table.
+func (table *SymbolTable) Len() int {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	return len(table.syms)
+}
+
+// Snapshot returns the symbols of the table as they are now, later
+// registrations do not change it. It must not be written, pass it to
+// Load or to the functions taking the symbols of the host.
+func (table *SymbolTable) Snapshot() map[string]uintptr {
+	table.lock.Lock()
+	defer table.lock.Unlock()
+	table.shared = true
+	return table.syms
+}
+
+// LoadWithTable loads linker against a snapshot of the symbols of table.
+func LoadWithTable(linker *Linker, table *SymbolTable, options LoadOptions) (*CodeModule, error) {
+	return LoadWithOptions(linker, table.Snapshot(), options)
+}
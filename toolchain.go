@@ -0,0 +1,75 @@
+package goloader
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+	"unsafe"
+)
+
+// Toolchain is the go toolchain which built the objects of a linker, and
+// the layout of the runtime structures goloader wrote them with. The
+// objects of another go release, or a linker laid out for other _func and
+// moduledata, would corrupt the memory of the host silently once loaded.
+type Toolchain struct {
+	GoVersion string // of the compiler, from the header of the objects
+	Layout    string // sizes of the runtime structures, see layoutFingerprint
+}
+
+// layoutFingerprint returns the sizes of the runtime structures a linker
+// is laid out with, they change between go releases.
+func layoutFingerprint() string {
+	return fmt.Sprintf("_func:%d functab:%d findfuncbucket:%d moduledata:%d _type:%d itab:%d",
+		unsafe.Sizeof(_func{}), unsafe.Sizeof(functab{}), FindFuncBucketSize,
+		unsafe.Sizeof(moduledata{}), unsafe.Sizeof(_type{}), unsafe.Sizeof(itab{}))
+}
+
+// goRelease returns the release of a go version, e.g. go1.16 of go1.16.5
+// and go1.16rc1. A development version is its own release.
+func goRelease(version string) string {
+	if !strings.HasPrefix(version, "go1.") {
+		return version
+	}
+	end := len("go1.")
+	for end < len(version) && version[end] >= '0' && version[end] <= '9' {
+		end++
+	}
+	return version[:end]
+}
+
+// Toolchain returns the toolchain which built the objects of linker.
+func (linker *Linker) Toolchain() Toolchain {
+	return linker.toolchain
+}
+
+// addToolchain records the go version of the objects of pkg, all the
+// objects of a linker must be built by the same release.
+func (linker *Linker) addToolchain(pkg *Pkg) error {
+	if linker.toolchain.Layout == EmptyString {
+		linker.toolchain.Layout = layoutFingerprint()
+	}
+	if pkg.goVersion == EmptyString {
+		return nil
+	}
+	if linker.toolchain.GoVersion != EmptyString && goRelease(linker.toolchain.GoVersion) != goRelease(pkg.goVersion) {
+		return fmt.Errorf("read obj error: %s built by %s, other objects by %s", pkg.name, pkg.goVersion, linker.toolchain.GoVersion)
+	}
+	if linker.toolchain.GoVersion == EmptyString {
+		linker.toolchain.GoVersion = pkg.goVersion
+	}
+	return nil
+}
+
+// checkToolchain fails if the objects of linker were built by another go
+// release than the host, or laid out for other runtime structures.
+func (linker *Linker) checkToolchain() error {
+	toolchain := linker.toolchain
+	if toolchain.GoVersion != EmptyString && goRelease(toolchain.GoVersion) != goRelease(runtime.Version()) {
+		return fmt.Errorf("goloader: objects built by %s, the host runs %s, rebuild them with the go release of the host",
+			toolchain.GoVersion, runtime.Version())
+	}
+	if layout := layoutFingerprint(); toolchain.Layout != EmptyString && toolchain.Layout != layout {
+		return fmt.Errorf("goloader: linker laid out for %s, the host has %s", toolchain.Layout, layout)
+	}
+	return nil
+}
@@ -0,0 +1,25 @@
+package goloader
+
+import (
+	"testing"
+)
+
+func TestGoRelease(t *testing.T) {
+	tests := []struct {
+		version string
+		release string
+	}{
+		{"go1.16", "go1.16"},
+		{"go1.16.5", "go1.16"},
+		{"go1.8rc1", "go1.8"},
+		{"go1.14beta1", "go1.14"},
+		{"go1.", "go1."},
+		{"devel +b7a85e0003", "devel +b7a85e0003"},
+		{"", ""},
+	}
+	for _, test := range tests {
+		if release := goRelease(test.version); release != test.release {
+			t.Errorf("goRelease(%q) = %q, want %q", test.version, release, test.release)
+		}
+	}
+}
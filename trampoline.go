@@ -0,0 +1,108 @@
+package goloader
+
+import (
+	"fmt"
+	"unsafe"
+)
+
+// trampolineSize returns the most bytes of trampoline a relocation of
+// relocType may need on arch, the alignment of the trampoline included,
+// 0 if it is never rewritten through one.
+func trampolineSize(arch string, relocType int) int {
+	switch relocType {
+	case R_CALL:
+		if arch == archS390X {
+			return PtrSize - 1 + len(s390xLGRLcode) + len(s390xBRcode) + PtrSize
+		}
+		return len(x86amd64JMPLcode) + PtrSize
+	case R_PCREL:
+		return 3*PtrSize + len(x86amd64replaceCMPLcode)
+	case R_ADDRARM64:
+		//LDR, B back and the literal, aligned to PtrSize
+		return PtrSize - 1 + 2*Uint32Size + PtrSize
+	case R_CALLARM:
+		return PtrSize - 1 + len(armcode) + PtrSize
+	case R_CALLARM64:
+		return PtrSize - 1 + len(arm64code) + PtrSize
+	case R_RISCV_PCREL_ITYPE, R_RISCV_PCREL_STYPE:
+		//AUIPC, LD, the instruction, a jump back with AUIPC, JALR and a NOP
+		//aligning the address after them to PtrSize
+		return PtrSize - 1 + 6*Uint32Size + PtrSize
+	case R_CALLMIPS, R_JMPMIPS:
+		return 8 * Uint32Size
+	case R_GOTPCREL, R_ARM64_GOTPCREL:
+		//a GOT entry
+		return PtrSize - 1 + PtrSize
+	}
+	return 0
+}
+
+// trampolineSpace returns the trampoline bytes the code of the objects may
+// need, the worst case of every relocation, so the trampolines written by
+// relocate always fit after the code, and its breakdown by relocation type.
+func (linker *Linker) trampolineSpace() (int, map[int]int) {
+	space, byType := 0, make(map[int]int)
+	for _, symbol := range linker.symMap {
+		if symbol.Kind != STEXT {
+			continue
+		}
+		for _, loc := range symbol.Reloc {
+			if size := trampolineSize(linker.Arch, loc.Type); size > 0 {
+				space += size
+				byType[loc.Type] += size
+			}
+		}
+	}
+	return space, byType
+}
+
+// reserveTrampolines records the trampoline space of the objects in the
+// statistics of codeModule and returns it.
+func (linker *Linker) reserveTrampolines(codeModule *CodeModule) int {
+	space, byType := linker.trampolineSpace()
+	for relocType, size := range byType {
+		codeModule.relocStats.stat(relocType).TrampolineSpace = size
+	}
+	return space
+}
+
+// growCode maps at least size more bytes of trampolines right after the code
+// mapping and extends codeByte over them. The mappings are contiguous, so the
+// trampolines keep their offsets from the code and Unload unmaps them with
+// the code. The trampolines grow by half of the mapping at least. If the
+// pages after the code are taken, LoadWithOptions loads the module again
+// with the trampolines reserved for the worst case.
+func (cm *CodeModule) growCode(size int) error {
+	size = alignof(size, PageSize)
+	if half := alignof(len(cm.codeByte)/2, PageSize); size < half {
+		size = half
+	}
+	if limit := cm.options.MaxCodeBytes; limit > 0 && len(cm.codeByte)+size > limit {
+		//grow by what is needed, up to the limit
+		if size = (limit - len(cm.codeByte)) &^ (PageSize - 1); size <= 0 {
+			return fmt.Errorf("goloader: code of %d bytes can not grow, limit %d", len(cm.codeByte), limit)
+		}
+	}
+	end := uintptr(cm.codeBase + len(cm.codeByte))
+	b, err := mmapAt(size, end, true)
+	if err != nil {
+		cm.growFailed = true
+		return fmt.Errorf("goloader: can not grow the trampolines by %d bytes: %v", size, err)
+	}
+	if uintptr(unsafe.Pointer(&b[0])) != end {
+		Munmap(b)
+		cm.growFailed = true
+		return fmt.Errorf("goloader: can not grow the trampolines by %d bytes, the pages after the code at 0x%x are in use", size, end)
+	}
+	if cm.options.NUMA != nil {
+		if err = mbind(b, cm.options.NUMA); err != nil {
+			Munmap(b)
+			return err
+		}
+	}
+	header := (*sliceHeader)(unsafe.Pointer(&cm.codeByte))
+	header.Len += size
+	header.Cap += size
+	cm.maxLength = len(cm.codeByte)
+	return nil
+}
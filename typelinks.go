@@ -0,0 +1,54 @@
+package goloader
+
+import (
+	"sort"
+	"strings"
+)
+
+//go:linkname (*_type).string runtime.(*_type).string
+func (t *_type) string() string
+
+// prefixes of the unnamed composite types, the compiler marks them for
+// typelinks, reflect.PtrTo, SliceOf, MapOf and FuncOf look them up there.
+var typelinkPrefixes = []string{"*", "[", "map[", "func(", "chan ", "chan<- ", "<-chan ", "struct {"}
+
+func isTypelink(typeName string) bool {
+	for _, prefix := range typelinkPrefixes {
+		if strings.HasPrefix(typeName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// buildTypelinks lists the unnamed composite types laid out in the module
+// as offsets from module.types, sorted by their string as the go linker
+// does. The module must be linked to the runtime, which resolves the names
+// of its types.
+func (linker *Linker) buildTypelinks(codeModule *CodeModule, symbolMap map[string]uintptr) {
+	segment := &codeModule.segment
+	module := codeModule.module
+	type typelink struct {
+		off int32
+		str string
+	}
+	links := make([]typelink, 0)
+	for name, sym := range linker.symMap {
+		if sym.Offset == InvalidOffset || sym.Kind == STEXT || strings.HasPrefix(name, TypeDoubleDotPrefix) ||
+			!strings.HasPrefix(name, TypePrefix) || !isTypelink(name[len(TypePrefix):]) {
+			continue
+		}
+		addr := symbolMap[name]
+		if addr < uintptr(segment.dataBase) || addr >= uintptr(segment.dataBase+segment.dataLen) {
+			continue
+		}
+		t := (*_type)(adduintptr(addr, 0))
+		links = append(links, typelink{off: int32(addr - module.types), str: t.string()})
+	}
+	sort.Slice(links, func(i, j int) bool { return links[i].str < links[j].str })
+	typelinks := make([]int32, len(links))
+	for i, link := range links {
+		typelinks[i] = link.off
+	}
+	module.typelinks = typelinks
+}
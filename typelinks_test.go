@@ -0,0 +1,27 @@
+package goloader
+
+import (
+	"testing"
+)
+
+func TestIsTypelink(t *testing.T) {
+	for typeName, want := range map[string]bool{
+		"*main.T":             true,
+		"[]int":               true,
+		"[4]string":           true,
+		"map[string]int":      true,
+		"func(int) error":     true,
+		"chan int":            true,
+		"<-chan int":          true,
+		"struct { X int }":    true,
+		"main.T":              false,
+		"int":                 false,
+		"interface { M() }":   false,
+		"chan<- int":          true,
+		"noalg.map.bucket[a]": false,
+	} {
+		if got := isTypelink(typeName); got != want {
+			t.Errorf("isTypelink(%q) = %v, want %v", typeName, got, want)
+		}
+	}
+}
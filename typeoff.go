@@ -0,0 +1,26 @@
+package goloader
+
+// hostTypeOff returns the typeOff of a host type referenced by a type of the
+// module. The runtime resolves the typeOff of a type relative to the module
+// holding it, an offset to the host is out of range of the module and may
+// overflow int32, so the host type is put in module.typemap under an offset
+// past the end of the types of the module, its data, where reflect and
+// the runtime find it.
+func (cm *CodeModule) hostTypeOff(addr uintptr) int {
+	if cm.hostTypes == nil {
+		cm.hostTypes = make(map[uintptr]int)
+	}
+	if off, ok := cm.hostTypes[addr]; ok {
+		return off
+	}
+	off := alignof(cm.dataLen, PtrSize) + len(cm.hostTypes)*PtrSize
+	for {
+		if _, ok := cm.module.typemap[typeOff(off)]; !ok {
+			break
+		}
+		off += PtrSize
+	}
+	cm.module.typemap[typeOff(off)] = addr
+	cm.hostTypes[addr] = off
+	return off
+}
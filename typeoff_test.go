@@ -0,0 +1,30 @@
+package goloader
+
+import (
+	"testing"
+)
+
+func TestHostTypeOff(t *testing.T) {
+	cm := &CodeModule{module: &moduledata{typemap: make(map[typeOff]uintptr)}}
+	cm.dataLen = 10
+	first := alignof(cm.dataLen, PtrSize)
+	//the offset of a type of the module is skipped
+	cm.module.typemap[typeOff(first)] = 0x1000
+
+	off := cm.hostTypeOff(0x2000)
+	if off <= first || off%PtrSize != 0 {
+		t.Errorf("hostTypeOff = %d, want an aligned offset past %d", off, first)
+	}
+	if again := cm.hostTypeOff(0x2000); again != off {
+		t.Errorf("hostTypeOff of the same type = %d, want %d", again, off)
+	}
+	other := cm.hostTypeOff(0x3000)
+	if other == off || other == first {
+		t.Errorf("hostTypeOff of another type = %d, taken", other)
+	}
+	for o, addr := range map[int]uintptr{first: 0x1000, off: 0x2000, other: 0x3000} {
+		if got := cm.module.typemap[typeOff(o)]; got != addr {
+			t.Errorf("typemap[%d] = 0x%x, want 0x%x", o, got, addr)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package goloader
+
+import (
+	"fmt"
+	"reflect"
+	"runtime"
+	"sort"
+	"unsafe"
+)
+
+// depth of the values walked by UnloadCheck, deeper references are missed
+const unloadCheckDepth = 64
+
+// ModuleFrame is a frame of a goroutine running the code of a module.
+type ModuleFrame struct {
+	Func string
+	PC   uintptr
+}
+
+// ModulePointer is a reference into a module found in a root given to
+// UnloadCheck, Path is the path of the value in the root, e.g. "[1].Handler".
+type ModulePointer struct {
+	Root int
+	Path string
+	Kind string // func, type or data
+	Addr uintptr
+}
+
+// UnloadReport lists what still refers to a module, unloading it while the
+// report is not Safe crashes the host sooner or later.
+type UnloadReport struct {
+	Goroutines [][]ModuleFrame // module frames of each goroutine running module code
+	Pointers   []ModulePointer
+	References int      // running calls of wrappers and references taken by Acquire
+	Callbacks  []string // callbacks still registered, sorted
+}
+
+// Safe reports whether nothing refers to the module.
+func (r *UnloadReport) Safe() bool {
+	return len(r.Goroutines) == 0 && len(r.Pointers) == 0 && r.References == 0 && len(r.Callbacks) == 0
+}
+
+func (r *UnloadReport) String() string {
+	return fmt.Sprintf("%d goroutines in module code, %d pointers into the module, %d references, %d callbacks",
+		len(r.Goroutines), len(r.Pointers), r.References, len(r.Callbacks))
+}
+
+// UnloadCheck reports what refers to the module: the goroutines with
+// frames in its code, the references counted by Acquire and the wrappers,
+// the registered callbacks, and the pointers into the module held by roots,
+// e.g. the values the module returned to the host. Roots are walked through
+// pointers, interfaces, structs, slices, arrays and maps; a func value or a
+// type descriptor of the module is a pointer into it too. It is a
+// diagnostic: the goroutines are sampled once, with their 32 innermost
+// frames, and the memory of the host not given as a root is not scanned.
+func (cm *CodeModule) UnloadCheck(roots ...interface{}) *UnloadReport {
+	report := &UnloadReport{}
+	report.Goroutines = cm.moduleGoroutines()
+	cm.calls.lock.Lock()
+	report.References = cm.calls.count
+	cm.calls.lock.Unlock()
+	cm.callbackLock.Lock()
+	for cb := range cm.callbacks {
+		report.Callbacks = append(report.Callbacks, cb.name)
+	}
+	cm.callbackLock.Unlock()
+	sort.Strings(report.Callbacks)
+	for i, root := range roots {
+		w := &pointerWalk{cm: cm, root: i, seen: make(map[uintptr]bool)}
+		if root != nil {
+			w.check(EmptyString, "type", typeAddr(root))
+		}
+		w.walk(reflect.ValueOf(root), EmptyString, 0)
+		report.Pointers = append(report.Pointers, w.found...)
+	}
+	return report
+}
+
+// moduleGoroutines returns the module frames of the goroutines running
+// module code.
+func (cm *CodeModule) moduleGoroutines() [][]ModuleFrame {
+	records := make([]runtime.StackRecord, runtime.NumGoroutine()+16)
+	n, ok := runtime.GoroutineProfile(records)
+	for !ok {
+		records = make([]runtime.StackRecord, n+16)
+		n, ok = runtime.GoroutineProfile(records)
+	}
+	goroutines := make([][]ModuleFrame, 0)
+	for _, record := range records[:n] {
+		frames := make([]ModuleFrame, 0)
+		for _, pc := range record.Stack() {
+			if !cm.Contains(pc) {
+				continue
+			}
+			frame := ModuleFrame{PC: pc}
+			if f := runtime.FuncForPC(pc); f != nil {
+				frame.Func = f.Name()
+			}
+			frames = append(frames, frame)
+		}
+		if len(frames) > 0 {
+			goroutines = append(goroutines, frames)
+		}
+	}
+	return goroutines
+}
+
+type pointerWalk struct {
+	cm    *CodeModule
+	root  int
+	seen  map[uintptr]bool
+	found []ModulePointer
+}
+
+func (w *pointerWalk) check(path, kind string, addr uintptr) {
+	if addr != 0 && w.cm.Contains(addr) {
+		w.found = append(w.found, ModulePointer{Root: w.root, Path: path, Kind: kind, Addr: addr})
+	}
+}
+
+func (w *pointerWalk) walk(v reflect.Value, path string, depth int) {
+	if !v.IsValid() || depth > unloadCheckDepth {
+		return
+	}
+	switch v.Kind() {
+	case reflect.Func:
+		if !v.IsNil() {
+			w.check(path, "func", v.Pointer())
+		}
+	case reflect.Interface:
+		if !v.IsNil() {
+			//the dynamic type may be a type descriptor of the module
+			if v.CanInterface() {
+				w.check(path, "type", typeAddr(v.Interface()))
+			}
+			w.walk(v.Elem(), path, depth+1)
+		}
+	case reflect.Ptr, reflect.UnsafePointer:
+		addr := v.Pointer()
+		if addr == 0 || w.seen[addr] {
+			return
+		}
+		w.seen[addr] = true
+		w.check(path, "data", addr)
+		if v.Kind() == reflect.Ptr && !w.cm.Contains(addr) {
+			w.walk(v.Elem(), path, depth+1)
+		}
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			w.walk(v.Field(i), path+"."+v.Type().Field(i).Name, depth+1)
+		}
+	case reflect.Slice:
+		if v.IsNil() {
+			return
+		}
+		w.check(path, "data", v.Pointer())
+		if w.cm.Contains(v.Pointer()) {
+			return
+		}
+		fallthrough
+	case reflect.Array:
+		if kind := v.Type().Elem().Kind(); kind <= reflect.Complex128 || kind == reflect.String {
+			return
+		}
+		for i := 0; i < v.Len(); i++ {
+			w.walk(v.Index(i), fmt.Sprintf("%s[%d]", path, i), depth+1)
+		}
+	case reflect.Map:
+		if v.IsNil() || w.seen[v.Pointer()] {
+			return
+		}
+		w.seen[v.Pointer()] = true
+		for _, key := range v.MapKeys() {
+			w.walk(key, fmt.Sprintf("%s[%v]", path, key), depth+1)
+			w.walk(v.MapIndex(key), fmt.Sprintf("%s[%v]", path, key), depth+1)
+		}
+	}
+}
+
+// typeAddr returns the address of the type descriptor of i.
+func typeAddr(i interface{}) uintptr {
+	return uintptr((*emptyInterface)(unsafe.Pointer(&i)).typ)
+}
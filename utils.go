@@ -1,7 +1,6 @@
 package goloader
 
 import (
-	"encoding/binary"
 	"fmt"
 	"strconv"
 	"unsafe"
@@ -36,21 +35,33 @@ func bytearrayAlign(b *[]byte, align int) {
 
 func putAddressAddOffset(b []byte, offset *int, addr uint64) {
 	if PtrSize == Uint32Size {
-		binary.LittleEndian.PutUint32(b[*offset:], uint32(addr))
+		byteOrder.PutUint32(b[*offset:], uint32(addr))
 	} else {
-		binary.LittleEndian.PutUint64(b[*offset:], uint64(addr))
+		byteOrder.PutUint64(b[*offset:], uint64(addr))
 	}
 	*offset = *offset + PtrSize
 }
 
 func putAddress(b []byte, addr uint64) {
 	if PtrSize == Uint32Size {
-		binary.LittleEndian.PutUint32(b, uint32(addr))
+		byteOrder.PutUint32(b, uint32(addr))
 	} else {
-		binary.LittleEndian.PutUint64(b, uint64(addr))
+		byteOrder.PutUint64(b, uint64(addr))
 	}
 }
 
+// funcFile returns the file of the function entry, assembly wrappers may have none.
+func funcFile(symbol *ObjSymbol) string {
+	if symbol.Func == nil || len(symbol.Func.File) == 0 {
+		return EmptyString
+	}
+	return symbol.Func.File[0]
+}
+
+func isOverflowInt32(offset int) bool {
+	return offset > 0x7FFFFFFF || offset < -0x80000000
+}
+
 // sign extend a 24-bit integer
 func signext24(x int64) int32 {
 	return (int32(x) << 8) >> 8
@@ -74,9 +85,10 @@ func append2Slice(dst *[]byte, src uintptr, size int) {
 	*dst = append(*dst, *(*[]byte)(unsafe.Pointer(&s))...)
 }
 
+// see runtime.internal.atomic.Loadp
+//
 //go:nosplit
 //go:noinline
-//see runtime.internal.atomic.Loadp
 func loadp(ptr unsafe.Pointer) unsafe.Pointer {
 	return *(*unsafe.Pointer)(ptr)
 }
@@ -87,7 +99,7 @@ func grow(bytes *[]byte, size int) {
 	}
 }
 
-//see $GOROOT/src/cmd/internal/loader/loader.go:preprocess
+// see $GOROOT/src/cmd/internal/loader/loader.go:preprocess
 func ispreprocesssymbol(name string) bool {
 	if len(name) > 5 {
 		switch name[:5] {
@@ -109,10 +121,10 @@ func preprocesssymbol(name string, bytes []byte) error {
 		if uint64(uint32(val)) != val {
 			return fmt.Errorf("$-symbol %s too large: %d", name, val)
 		}
-		binary.LittleEndian.PutUint32(bytes, uint32(val))
+		byteOrder.PutUint32(bytes, uint32(val))
 		bytes = bytes[:4]
 	case "$f64.", "$i64.":
-		binary.LittleEndian.PutUint64(bytes, val)
+		byteOrder.PutUint64(bytes, val)
 	default:
 		return fmt.Errorf("unrecognized $-symbol: %s", name)
 	}
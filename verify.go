@@ -0,0 +1,183 @@
+package goloader
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+	"strings"
+)
+
+// Verifier checks a linker before Load maps anything, e.g. its hash, a
+// signature, a policy on the symbols of the host it refers to or the result
+// of a static analysis. Verifiers are chained in LoadOptions.Verifiers.
+type Verifier interface {
+	Verify(linker *Linker) error
+}
+
+// VerifierFunc is a Verifier calling itself.
+type VerifierFunc func(linker *Linker) error
+
+func (f VerifierFunc) Verify(linker *Linker) error {
+	return f(linker)
+}
+
+// Verify runs verifiers in order on linker and returns the error of the
+// first failing.
+func Verify(linker *Linker, verifiers ...Verifier) error {
+	for _, verifier := range verifiers {
+		if err := verifier.Verify(linker); err != nil {
+			return fmt.Errorf("goloader: verification failed: %v", err)
+		}
+	}
+	return nil
+}
+
+// HashVerifier accepts the linkers whose Hash is one of Hashes.
+type HashVerifier struct {
+	Hashes []string
+}
+
+func (v *HashVerifier) Verify(linker *Linker) error {
+	hash := linker.Hash()
+	for _, h := range v.Hashes {
+		if h == hash {
+			return nil
+		}
+	}
+	return fmt.Errorf("hash %s is not allowed", hash)
+}
+
+// SignLinker signs the hash of linker with key, the signature is the r and
+// s of ECDSA, each padded to the size of the curve.
+func SignLinker(linker *Linker, key *ecdsa.PrivateKey) ([]byte, error) {
+	digest, err := hex.DecodeString(linker.Hash())
+	if err != nil {
+		return nil, err
+	}
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest)
+	if err != nil {
+		return nil, err
+	}
+	size := (key.Curve.Params().BitSize + 7) / 8
+	signature := make([]byte, 2*size)
+	rb, sb := r.Bytes(), s.Bytes()
+	copy(signature[size-len(rb):size], rb)
+	copy(signature[2*size-len(sb):], sb)
+	return signature, nil
+}
+
+// SignatureVerifier accepts the linkers whose hash Signature, written by
+// SignLinker, is signed with one of Keys. The signature is detached, it is
+// shipped next to the bundle.
+type SignatureVerifier struct {
+	Keys      []*ecdsa.PublicKey
+	Signature []byte
+}
+
+func (v *SignatureVerifier) Verify(linker *Linker) error {
+	digest, err := hex.DecodeString(linker.Hash())
+	if err != nil {
+		return err
+	}
+	for _, key := range v.Keys {
+		size := (key.Curve.Params().BitSize + 7) / 8
+		if len(v.Signature) != 2*size {
+			continue
+		}
+		r := new(big.Int).SetBytes(v.Signature[:size])
+		s := new(big.Int).SetBytes(v.Signature[size:])
+		if ecdsa.Verify(key, digest, r, s) {
+			return nil
+		}
+	}
+	return errors.New("signature does not match any key")
+}
+
+// SymbolPolicy accepts the linkers whose objects refer only to symbols of
+// the host matching a pattern of Allow, if it is not empty, and none of
+// Deny. A pattern is a symbol name, a package path matching the symbols of
+// the package, e.g. "os/exec", or a prefix ending in *, e.g. "syscall.*".
+type SymbolPolicy struct {
+	Allow []string
+	Deny  []string
+}
+
+// matchSymbol reports whether the symbol name matches pattern, see SymbolPolicy.
+func matchSymbol(pattern, name string) bool {
+	if strings.HasSuffix(pattern, "*") {
+		return strings.HasPrefix(name, pattern[:len(pattern)-1])
+	}
+	return name == pattern || strings.HasPrefix(name, pattern+".")
+}
+
+func matchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if matchSymbol(pattern, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// Denied returns the sorted symbols of the host the objects of linker refer
+// to and the policy does not allow.
+func (policy *SymbolPolicy) Denied(linker *Linker) []string {
+	denied := make([]string, 0)
+	for _, name := range linker.references() {
+		if matchAny(policy.Deny, name) || (len(policy.Allow) > 0 && !matchAny(policy.Allow, name)) {
+			denied = append(denied, name)
+		}
+	}
+	return denied
+}
+
+func (policy *SymbolPolicy) Verify(linker *Linker) error {
+	if denied := policy.Denied(linker); len(denied) > 0 {
+		return fmt.Errorf("symbols not allowed: %s", strings.Join(denied, ", "))
+	}
+	return nil
+}
+
+// SizeVerifier accepts the linkers whose code, data and symbols fit the
+// limits, a limit of 0 is no limit.
+type SizeVerifier struct {
+	MaxCodeBytes int
+	MaxDataBytes int
+	MaxSymbols   int
+}
+
+func (v *SizeVerifier) Verify(linker *Linker) error {
+	if v.MaxCodeBytes > 0 && linker.codeSize() > v.MaxCodeBytes {
+		return fmt.Errorf("code is %d bytes, limit %d", linker.codeSize(), v.MaxCodeBytes)
+	}
+	if v.MaxDataBytes > 0 && linker.dataSize() > v.MaxDataBytes {
+		return fmt.Errorf("data is %d bytes, limit %d", linker.dataSize(), v.MaxDataBytes)
+	}
+	if v.MaxSymbols > 0 && len(linker.symMap) > v.MaxSymbols {
+		return fmt.Errorf("%d symbols, limit %d", len(linker.symMap), v.MaxSymbols)
+	}
+	return nil
+}
+
+// references returns the sorted names of the symbols the objects refer to
+// and do not define, the symbols of the host.
+func (linker *Linker) references() []string {
+	refs := make(map[string]bool)
+	for _, objsym := range linker.objsymbolMap {
+		for _, loc := range objsym.Reloc {
+			if _, ok := linker.objsymbolMap[loc.Sym.Name]; !ok {
+				refs[loc.Sym.Name] = true
+			}
+		}
+	}
+	names := make([]string, 0, len(refs))
+	for name := range refs {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}